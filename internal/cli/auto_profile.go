@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/SuzumiyaAoba/via/internal/config"
+	"github.com/SuzumiyaAoba/via/internal/logger"
+)
+
+// targetDir returns the directory profiles and project files are looked up from:
+// the opened file's directory, or the working directory when no local file is opened
+func targetDir(args []string) string {
+	if len(args) > 0 && !isURL(args[0]) {
+		if info, err := os.Stat(args[0]); err == nil {
+			path := absPath(args[0])
+			if info.IsDir() {
+				return path
+			}
+			return filepath.Dir(path)
+		}
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return "."
+	}
+	return dir
+}
+
+// autoProfile returns the profile selected by the auto rules of the main config, or ""
+func autoProfile(args []string) string {
+	cfg, err := config.LoadConfig("")
+	if err != nil || cfg.Profiles == nil {
+		return ""
+	}
+	return config.MatchAutoProfile(cfg.Profiles.Auto, targetDir(args))
+}
+
+// applyProjectConfigs layers the .via.yml files above the target over cfg when enabled
+func applyProjectConfigs(cfg *config.Config, args []string) (*config.Config, error) {
+	if cfg.Profiles == nil || !cfg.Profiles.ProjectFiles {
+		return cfg, nil
+	}
+	result, paths, err := config.ApplyProjectConfigs(cfg, targetDir(args))
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range paths {
+		logger.Debug("Applied project config %s", p)
+	}
+	return result, nil
+}
+
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
@@ -0,0 +1,248 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/SuzumiyaAoba/via/internal/config"
+	"github.com/SuzumiyaAoba/via/internal/logger"
+	"github.com/SuzumiyaAoba/via/internal/sync"
+	"github.com/spf13/cobra"
+)
+
+// autoSyncTimeout bounds how long opening a file waits for the remote, can be changed for testing
+var autoSyncTimeout = 3 * time.Second
+
+// autoSyncNow returns the current time, can be swapped for testing
+var autoSyncNow = time.Now
+
+// autoFetch is what a background remote check brings back
+type autoFetch struct {
+	status *sync.Status
+	remote *config.Config // nil when the remote revision did not change
+	files  *fileSet
+	err    error
+}
+
+// autoPullOnStart checks the remote before a file is opened when sync.auto
+// contains pull-on-start. The remote is checked at most once per interval and
+// only fetched when its revision changed. Remote changes are applied only if
+// they do not conflict with local ones. Any failure, including a slow or
+// unreachable remote, is logged and the local config is used as is.
+// It returns the config to open the file with.
+func autoPullOnStart(cmd *cobra.Command, cfg *config.Config) *config.Config {
+	if cfg.Sync == nil || !cfg.Sync.AutoEnabled(sync.AutoPullOnStart) {
+		return cfg
+	}
+
+	pulled, err := autoPull(cmd)
+	if err != nil {
+		logger.Warn("Automatic sync skipped: %v", err)
+		return cfg
+	}
+	if !pulled {
+		return cfg
+	}
+
+	reloaded, err := config.LoadResolvedConfig(cfgFile)
+	if err != nil {
+		logger.Warn("Failed to reload config after automatic sync: %v", err)
+		return cfg
+	}
+	return reloaded
+}
+
+// autoPull runs the rate limited remote check and reports whether the local config changed
+func autoPull(cmd *cobra.Command) (bool, error) {
+	s, err := openSyncSession(false)
+	if err != nil {
+		return false, err
+	}
+	if s.state == nil {
+		// The first sync may need a decision, so it is left to 'vv :config sync pull'
+		return false, errors.New("not synced yet. Run 'vv :config sync pull' once")
+	}
+
+	autoState, err := sync.LoadAutoState(s.configPath)
+	if err != nil {
+		return false, err
+	}
+	checkedAt := autoSyncNow()
+	if !autoState.Due(sync.AutoInterval(s.local.Sync), checkedAt) {
+		logger.Debug("Automatic sync: remote checked at %s, skipping", autoState.CheckedAt)
+		return false, nil
+	}
+
+	// Record the check up front so an unreachable remote is not retried on every start
+	autoState.CheckedAt = checkedAt
+	if err := sync.SaveAutoState(s.configPath, autoState); err != nil {
+		return false, err
+	}
+
+	// Only fetch in the background; changes are applied here so an abandoned
+	// check never leaves a half written config behind.
+	done := make(chan autoFetch, 1)
+	known := autoState.Revision
+	go func() {
+		done <- s.fetch(known)
+	}()
+
+	var fetched autoFetch
+	select {
+	case fetched = <-done:
+	case <-time.After(autoSyncTimeout):
+		return false, fmt.Errorf("%s did not respond within %s", s.backend.Name(), autoSyncTimeout)
+	}
+	if fetched.err != nil {
+		return false, fetched.err
+	}
+	if fetched.remote == nil {
+		return false, nil
+	}
+
+	pulled, err := s.applyAutoPull(cmd, fetched)
+	if err != nil {
+		return false, err
+	}
+
+	autoState.Revision = fetched.status.Revision
+	if err := sync.SaveAutoState(s.configPath, autoState); err != nil {
+		return false, err
+	}
+	return pulled, nil
+}
+
+// fetch checks the remote revision and downloads the config and extra files if it changed
+func (s *syncSession) fetch(knownRevision string) autoFetch {
+	status, err := s.backend.Status()
+	if err != nil {
+		return autoFetch{err: err}
+	}
+	if !status.Exists {
+		return autoFetch{status: status}
+	}
+	if status.Revision != "" && (status.Revision == knownRevision || status.Revision == s.state.Revision) {
+		logger.Debug("Automatic sync: remote revision %s unchanged", status.Revision)
+		return autoFetch{status: status}
+	}
+
+	_, remote, err := s.pullRemote()
+	if err != nil {
+		return autoFetch{err: err}
+	}
+	files, err := s.collectFiles()
+	if err != nil {
+		return autoFetch{err: err}
+	}
+	return autoFetch{status: status, remote: remote, files: files}
+}
+
+// applyAutoPull applies fetched remote changes that need no decision and reports whether anything changed
+func (s *syncSession) applyAutoPull(cmd *cobra.Command, fetched autoFetch) (bool, error) {
+	base, err := s.state.BaseConfig()
+	if err != nil {
+		return false, err
+	}
+
+	relation, err := sync.Compare(base, s.shared, fetched.remote)
+	if err != nil {
+		return false, err
+	}
+	if relation == sync.Diverged {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Configuration changed both locally and in %s. Run 'vv :config sync pull' to merge\n", s.backend.Name())
+		return false, nil
+	}
+
+	files := fetched.files
+	if files != nil {
+		files.plan, err = sync.PlanFiles(s.state.Files, files.local, files.remote, sync.DirectionPull, nil)
+		var conflictErr *sync.ConflictError
+		if errors.As(err, &conflictErr) {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Files changed both locally and in %s. Run 'vv :config sync pull' to resolve\n", s.backend.Name())
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+
+	pulled := relation == sync.Behind
+	if pulled || files != nil && len(files.plan.Local) > 0 {
+		if err := s.snapshot("automatic pull", files); err != nil {
+			return false, err
+		}
+	}
+	if pulled {
+		if err := s.save(sync.WithHostSections(fetched.remote, s.local)); err != nil {
+			return false, err
+		}
+	}
+
+	synced, err := s.applyFiles(files)
+	if err != nil {
+		return false, err
+	}
+
+	// Local changes that are not pushed yet keep the previous base
+	stateBase := fetched.remote
+	if relation == sync.Ahead {
+		stateBase = base
+	}
+	state, err := sync.NewState(stateBase, fetched.status.Revision)
+	if err != nil {
+		return false, err
+	}
+	state.Files = s.state.Files
+	if files != nil {
+		state.Files = files.plan.Base
+	}
+	if err := sync.SaveState(s.configPath, state); err != nil {
+		return false, err
+	}
+
+	if pulled || synced > 0 {
+		logger.Info("Automatic sync: pulled remote changes from %s", s.backend.Name())
+	}
+	return pulled || synced > 0, nil
+}
+
+// autoPushOnChange pushes the config after a command changed it when sync.auto
+// contains push-on-change. Failures are reported as warnings and never fail the command.
+func autoPushOnChange(cmd *cobra.Command, cfg *config.Config) {
+	if cfg.Sync == nil || !cfg.Sync.AutoEnabled(sync.AutoPushOnChange) {
+		return
+	}
+
+	s, err := openSyncSession(true)
+	if err == nil {
+		err = s.autoPush(cmd)
+	}
+	if err != nil {
+		logger.Warn("Automatic push failed: %v", err)
+		// Conflict errors carry a full diff, the summary line is enough here
+		summary, _, _ := strings.Cut(err.Error(), "\n")
+		fmt.Fprintf(cmd.ErrOrStderr(), "Warning: automatic sync failed: %s\n", summary)
+	}
+}
+
+// autoPush pushes in the background like autoPullOnStart fetches, so an unresponsive
+// remote delays the command by at most autoSyncTimeout
+func (s *syncSession) autoPush(cmd *cobra.Command) error {
+	var out bytes.Buffer
+	done := make(chan error, 1)
+	go func() {
+		// Conflicts are never resolved implicitly, they are left for 'vv :config sync push'
+		done <- s.push(&out, nil)
+	}()
+
+	select {
+	case err := <-done:
+		fmt.Fprint(cmd.OutOrStdout(), out.String())
+		return err
+	case <-time.After(autoSyncTimeout):
+		return fmt.Errorf("%s did not respond within %s", s.backend.Name(), autoSyncTimeout)
+	}
+}
@@ -0,0 +1,192 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"time"
+
+	"github.com/SuzumiyaAoba/via/internal/config"
+	"github.com/SuzumiyaAoba/via/internal/sync"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// slowBackend never answers a status or pull request in time
+type slowBackend struct {
+	sync.Backend
+	release chan struct{}
+}
+
+func (b *slowBackend) Name() string { return "slow" }
+
+func (b *slowBackend) Status() (*sync.Status, error) {
+	<-b.release
+	return nil, errors.New("offline")
+}
+
+func (b *slowBackend) Pull() (*config.Config, error) {
+	<-b.release
+	return nil, errors.New("offline")
+}
+
+var _ = Describe("Automatic sync", func() {
+	var (
+		tmpDir    string
+		remoteDir string
+		outBuf    bytes.Buffer
+		clock     time.Time
+	)
+
+	saveRemote := func(rules ...config.Rule) {
+		remote := &config.Config{Version: "1", Rules: rules}
+		Expect(config.SaveConfig(filepath.Join(remoteDir, "config.yml"), remote)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		resetGlobals()
+		tmpDir = GinkgoT().TempDir()
+		remoteDir = filepath.Join(tmpDir, "shared")
+		cfgFile = filepath.Join(tmpDir, "config.yml")
+
+		cfg := &config.Config{
+			Version: "1",
+			Rules:   []config.Rule{{Name: "Local", Command: "cat {{.File}}"}},
+			Sync: &config.SyncConfig{
+				Backend: "dir",
+				Path:    remoteDir,
+				Auto:    "pull-on-start, push-on-change",
+			},
+		}
+		Expect(config.SaveConfig(cfgFile, cfg)).To(Succeed())
+
+		outBuf.Reset()
+		rootCmd.SetOut(&outBuf)
+		rootCmd.SetErr(&outBuf)
+
+		clock = time.Now()
+		originalNow := autoSyncNow
+		autoSyncNow = func() time.Time { return clock }
+		DeferCleanup(func() { autoSyncNow = originalNow })
+
+		rootCmd.SetArgs([]string{"--config", cfgFile, ":config", "sync", "push"})
+		Expect(rootCmd.Execute()).To(Succeed())
+		outBuf.Reset()
+	})
+
+	loadLocal := func() *config.Config {
+		cfg, err := config.LoadConfig(cfgFile)
+		Expect(err).NotTo(HaveOccurred())
+		return cfg
+	}
+
+	Describe("pull-on-start", func() {
+		It("should pull remote changes at most once per interval", func() {
+			saveRemote(config.Rule{Name: "Remote", Command: "less {{.File}}"})
+
+			cfg := autoPullOnStart(rootCmd, loadLocal())
+			Expect(cfg.Rules[0].Name).To(Equal("Remote"))
+			Expect(cfg.Sync.Path).To(Equal(remoteDir))
+
+			autoState, err := sync.LoadAutoState(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(autoState.Revision).NotTo(BeEmpty())
+
+			saveRemote(config.Rule{Name: "Later", Command: "less {{.File}}"})
+			clock = clock.Add(time.Minute)
+			Expect(autoPullOnStart(rootCmd, loadLocal()).Rules[0].Name).To(Equal("Remote"))
+
+			clock = clock.Add(sync.DefaultAutoInterval)
+			Expect(autoPullOnStart(rootCmd, loadLocal()).Rules[0].Name).To(Equal("Later"))
+		})
+
+		It("should keep diverged local changes", func() {
+			saveRemote(config.Rule{Name: "Remote", Command: "less {{.File}}"})
+			cfg := loadLocal()
+			cfg.Rules[0].Command = "vim {{.File}}"
+			Expect(config.SaveConfig(cfgFile, cfg)).To(Succeed())
+
+			cfg = autoPullOnStart(rootCmd, loadLocal())
+			Expect(cfg.Rules[0].Command).To(Equal("vim {{.File}}"))
+			Expect(outBuf.String()).To(ContainSubstring("vv :config sync pull"))
+		})
+
+		It("should not wait for an unresponsive remote", func() {
+			backend := &slowBackend{release: make(chan struct{})}
+			defer close(backend.release)
+
+			originalBackend, originalTimeout := newSyncBackend, autoSyncTimeout
+			newSyncBackend = func(cfg *config.SyncConfig) (sync.Backend, error) { return backend, nil }
+			autoSyncTimeout = 10 * time.Millisecond
+			defer func() { newSyncBackend, autoSyncTimeout = originalBackend, originalTimeout }()
+
+			cfg := autoPullOnStart(rootCmd, loadLocal())
+			Expect(cfg.Rules[0].Name).To(Equal("Local"))
+
+			autoState, err := sync.LoadAutoState(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(autoState.CheckedAt).To(BeTemporally("~", clock, time.Second))
+		})
+	})
+
+	Describe("push-on-change", func() {
+		It("should push after a rule is added", func() {
+			configAddCmd.Flags().Set("ext", "md")
+			configAddCmd.Flags().Set("cmd", "glow {{.File}}")
+			configAddCmd.SetOut(&outBuf)
+			configAddCmd.SetErr(&outBuf)
+			DeferCleanup(func() {
+				configAddCmd.Flags().Set("ext", "")
+				configAddCmd.Flags().Set("cmd", "")
+				configAddCmd.SetOut(nil)
+				configAddCmd.SetErr(nil)
+			})
+
+			Expect(runConfigAdd(configAddCmd, []string{})).To(Succeed())
+			Expect(outBuf.String()).To(ContainSubstring("Configuration pushed to directory"))
+
+			remote, err := config.LoadConfig(filepath.Join(remoteDir, "config.yml"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(remote.Rules).To(HaveLen(2))
+		})
+
+		It("should only warn when the push fails", func() {
+			// Removing a rule changed remotely is a conflict
+			saveRemote(config.Rule{Name: "Local", Command: "less {{.File}}"})
+
+			configRemoveCmd.SetOut(&outBuf)
+			configRemoveCmd.SetErr(&outBuf)
+			DeferCleanup(func() {
+				configRemoveCmd.SetOut(nil)
+				configRemoveCmd.SetErr(nil)
+			})
+
+			Expect(runConfigRemove(configRemoveCmd, "1")).To(Succeed())
+			Expect(outBuf.String()).To(ContainSubstring("Rule removed successfully"))
+			Expect(outBuf.String()).To(ContainSubstring("Warning: automatic sync failed"))
+			Expect(loadLocal().Rules).To(BeEmpty())
+		})
+
+		It("should not wait for an unresponsive remote", func() {
+			backend := &slowBackend{release: make(chan struct{})}
+			defer close(backend.release)
+
+			originalBackend, originalTimeout := newSyncBackend, autoSyncTimeout
+			newSyncBackend = func(cfg *config.SyncConfig) (sync.Backend, error) { return backend, nil }
+			autoSyncTimeout = 10 * time.Millisecond
+			defer func() { newSyncBackend, autoSyncTimeout = originalBackend, originalTimeout }()
+
+			autoPushOnChange(rootCmd, loadLocal())
+			Expect(outBuf.String()).To(ContainSubstring("Warning: automatic sync failed: slow did not respond within 10ms"))
+		})
+	})
+
+	It("should reject unknown modes", func() {
+		cfg := loadLocal()
+		cfg.Sync.Auto = "always"
+		Expect(config.SaveConfig(cfgFile, cfg)).To(Succeed())
+
+		rootCmd.SetArgs([]string{"--config", cfgFile, ":config", "sync", "status"})
+		Expect(rootCmd.Execute()).To(MatchError(ContainSubstring("unknown automatic sync mode: always")))
+	})
+})
@@ -47,24 +47,21 @@ func init() {
 }
 
 func runConfigAliasAdd(cmd *cobra.Command, name, command string) error {
-	cfg, err := config.LoadConfig(cfgFile)
-	if err != nil {
-		// If config doesn't exist, create a new one
-		cfg = &config.Config{Version: "1"}
-	}
-
-	if cfg.Aliases == nil {
-		cfg.Aliases = make(map[string]string)
-	}
+	// If config doesn't exist, a new one is created
+	err := config.UpdateConfig(cfgFile, func(cfg *config.Config) error {
+		if cfg.Aliases == nil {
+			cfg.Aliases = make(map[string]string)
+		}
 
-	if _, exists := cfg.Aliases[name]; exists {
-		return fmt.Errorf("alias '%s' already exists", name)
-	}
-
-	cfg.Aliases[name] = command
+		if _, exists := cfg.Aliases[name]; exists {
+			return fmt.Errorf("alias '%s' already exists", name)
+		}
 
-	if err := config.SaveConfig(cfgFile, cfg); err != nil {
-		return fmt.Errorf("failed to save config: %w", err)
+		cfg.Aliases[name] = command
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	fmt.Fprintf(cmd.OutOrStdout(), "Alias '%s' added successfully\n", name)
@@ -72,25 +69,18 @@ func runConfigAliasAdd(cmd *cobra.Command, name, command string) error {
 }
 
 func runConfigAliasRemove(cmd *cobra.Command, name string) error {
-	cfg, err := config.LoadConfig(cfgFile)
+	err := config.UpdateConfig(cfgFile, func(cfg *config.Config) error {
+		if _, exists := cfg.Aliases[name]; !exists {
+			return fmt.Errorf("alias '%s' not found", name)
+		}
+
+		delete(cfg.Aliases, name)
+		return nil
+	})
 	if err != nil {
 		return err
 	}
 
-	if cfg.Aliases == nil {
-		return fmt.Errorf("alias '%s' not found", name)
-	}
-
-	if _, exists := cfg.Aliases[name]; !exists {
-		return fmt.Errorf("alias '%s' not found", name)
-	}
-
-	delete(cfg.Aliases, name)
-
-	if err := config.SaveConfig(cfgFile, cfg); err != nil {
-		return fmt.Errorf("failed to save config: %w", err)
-	}
-
 	fmt.Fprintf(cmd.OutOrStdout(), "Alias '%s' removed successfully\n", name)
 	return nil
 }
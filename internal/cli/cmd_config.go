@@ -32,6 +32,16 @@ var configListCmd = &cobra.Command{
 	},
 }
 
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the configuration file",
+	Long:  `Show the configuration file. With --resolved, show the effective configuration after layering the configs listed in extends.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		resolved, _ := cmd.Flags().GetBool("resolved")
+		return runConfigShow(cmd, resolved)
+	},
+}
+
 var configOpenCmd = &cobra.Command{
 	Use:   "open",
 	Short: "Open configuration file in editor",
@@ -50,6 +60,7 @@ var configAddCmd = &cobra.Command{
 
 func init() {
 	configCmd.AddCommand(configListCmd)
+	configCmd.AddCommand(configShowCmd)
 	configCmd.AddCommand(configOpenCmd)
 	configCmd.AddCommand(configAddCmd)
 
@@ -67,17 +78,24 @@ func init() {
 	configAddCmd.Flags().String("script", "", "JavaScript condition/command")
 	configAddCmd.MarkFlagRequired("cmd")
 
+	configShowCmd.Flags().Bool("resolved", false, "Show the effective configuration including inherited rules")
+
 	configCmd.AddCommand(configInitCmd)
 	configCmd.AddCommand(configCheckCmd)
+	configCmd.AddCommand(configTestCmd)
 	configCmd.AddCommand(configRemoveCmd)
 	configCmd.AddCommand(configSetDefaultCmd)
 	configCmd.AddCommand(configEditCmd)
 	configCmd.AddCommand(configProfileListCmd)
 	configCmd.AddCommand(configProfileCopyCmd)
+	configCmd.AddCommand(configProfileCmd)
+	configCmd.AddCommand(configMigrateCmd)
+	configCmd.AddCommand(configConvertCmd)
 	configCmd.AddCommand(configAliasCmd)
 	configCmd.AddCommand(configMoveCmd)
 	configCmd.AddCommand(configExportCmd)
 	configCmd.AddCommand(configImportCmd)
+	configCmd.AddCommand(configRestoreCmd)
 	configCmd.AddCommand(configSyncCmd)
 }
 
@@ -96,6 +114,25 @@ func runConfigList(cmd *cobra.Command) error {
 	return nil
 }
 
+func runConfigShow(cmd *cobra.Command, resolved bool) error {
+	load := config.LoadConfig
+	if resolved {
+		load = config.LoadResolvedConfig
+	}
+	cfg, err := load(cfgFile)
+	if err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	fmt.Fprint(cmd.OutOrStdout(), string(data))
+	return nil
+}
+
 func runConfigOpen(cmd *cobra.Command) error {
 	configPath, err := config.GetConfigPath(cfgFile)
 	if err != nil {
@@ -103,7 +140,7 @@ func runConfigOpen(cmd *cobra.Command) error {
 	}
 
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		cfg := &config.Config{Version: "1"}
+		cfg := &config.Config{Version: config.CurrentVersion}
 		if err := config.SaveConfig(cfgFile, cfg); err != nil {
 			return fmt.Errorf("failed to create default config: %w", err)
 		}
@@ -145,11 +182,6 @@ func runConfigAdd(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	cfg, err := config.LoadConfig(cfgFile)
-	if err != nil {
-		cfg = &config.Config{Version: "1"}
-	}
-
 	rule := config.Rule{
 		Name:        name,
 		Command:     command,
@@ -168,13 +200,18 @@ func runConfigAdd(cmd *cobra.Command, args []string) error {
 		rule.Extensions = utils.SplitAndTrim(ext)
 	}
 
-	cfg.Rules = append(cfg.Rules, rule)
-
-	if err := config.SaveConfig(cfgFile, cfg); err != nil {
+	var cfg *config.Config
+	err := config.UpdateConfig(cfgFile, func(c *config.Config) error {
+		c.Rules = append(c.Rules, rule)
+		cfg = c
+		return nil
+	})
+	if err != nil {
 		return err
 	}
 
 	fmt.Fprintln(cmd.OutOrStdout(), "Rule added successfully")
+	autoPushOnChange(cmd, cfg)
 	return nil
 }
 
@@ -189,7 +226,7 @@ func runConfigInit(cmd *cobra.Command) error {
 	}
 
 	cfg := &config.Config{
-		Version: "1",
+		Version: config.CurrentVersion,
 		DefaultCommand: "vim {{.File}}",
 		Rules: []config.Rule{
 			{
@@ -214,72 +251,102 @@ func runConfigCheck(cmd *cobra.Command) error {
 	if err != nil {
 		return err
 	}
+	problems := config.Lint(cfg)
 
-	if err := config.ValidateConfig(cfg); err != nil {
-		return err
+	// Inherited and included configs must exist and layer cleanly too
+	if len(cfg.Extends) > 0 || len(cfg.Include) > 0 {
+		resolved, err := config.LoadResolvedConfig(cfgFile)
+		if err != nil {
+			return err
+		}
+		// The file's own rules are part of the resolved config, report them once
+		seen := make(map[string]bool)
+		for _, p := range problems {
+			seen[p.String()] = true
+		}
+		// Lint expands commands itself, so it gets the values as written
+		for _, p := range config.Lint(resolved.Raw) {
+			if !seen[p.String()] {
+				problems = append(problems, p)
+			}
+		}
 	}
 
+	var errorCount, warningCount int
+	for _, p := range problems {
+		fmt.Fprintln(cmd.OutOrStdout(), p)
+		if p.Severity == config.SeverityError {
+			errorCount++
+		} else {
+			warningCount++
+		}
+	}
+
+	if errorCount > 0 {
+		return fmt.Errorf("validation failed: %s, %s", countOf(errorCount, "error"), countOf(warningCount, "warning"))
+	}
+	if warningCount > 0 {
+		fmt.Fprintf(cmd.OutOrStdout(), "Configuration is valid, %s\n", countOf(warningCount, "warning"))
+		return nil
+	}
 	fmt.Fprintln(cmd.OutOrStdout(), "Configuration is valid")
 	return nil
 }
 
+// countOf formats a count with the singular or plural of a noun
+func countOf(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
 func runConfigRemove(cmd *cobra.Command, indexStr string) error {
 	var index int
 	if _, err := fmt.Sscanf(indexStr, "%d", &index); err != nil {
 		return fmt.Errorf("invalid index: %s", indexStr)
 	}
 
-	cfg, err := config.LoadConfig(cfgFile)
-	if err != nil {
-		return err
-	}
-
-	if index < 1 || index > len(cfg.Rules) {
-		return fmt.Errorf("index out of range: %d", index)
-	}
-
-	// Remove rule (1-based index)
-	cfg.Rules = append(cfg.Rules[:index-1], cfg.Rules[index:]...)
+	var cfg *config.Config
+	err := config.UpdateConfig(cfgFile, func(c *config.Config) error {
+		if index < 1 || index > len(c.Rules) {
+			return fmt.Errorf("index out of range: %d", index)
+		}
 
-	if err := config.SaveConfig(cfgFile, cfg); err != nil {
+		// Remove rule (1-based index)
+		c.Rules = append(c.Rules[:index-1], c.Rules[index:]...)
+		cfg = c
+		return nil
+	})
+	if err != nil {
 		return err
 	}
 
 	fmt.Fprintln(cmd.OutOrStdout(), "Rule removed successfully")
+	autoPushOnChange(cmd, cfg)
 	return nil
 }
 
 func runConfigSetDefault(cmd *cobra.Command, command string) error {
-	// Check if config exists first
-	configPath, err := config.GetConfigPath(cfgFile)
+	// A missing config is created
+	err := config.UpdateConfig(cfgFile, func(cfg *config.Config) error {
+		cfg.DefaultCommand = command
+		// Clear alias if present to avoid confusion
+		cfg.Default = ""
+		return nil
+	})
 	if err != nil {
 		return err
 	}
 
-	var cfg *config.Config
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		cfg = &config.Config{Version: "1"}
-	} else {
-		cfg, err = config.LoadConfig(cfgFile)
-		if err != nil {
-			return err
-		}
-	}
-
-	cfg.DefaultCommand = command
-	// Clear alias if present to avoid confusion
-	cfg.Default = ""
-
-	if err := config.SaveConfig(cfgFile, cfg); err != nil {
-		return err
-	}
-
 	fmt.Fprintln(cmd.OutOrStdout(), "Default command updated successfully")
 	return nil
 }
 
 func runConfigEdit(cmd *cobra.Command) error {
-	cfg, err := config.LoadConfig(cfgFile)
+	// The forms can stay open for a while, so the file is not locked in the
+	// meantime; saving fails instead if it changed
+	cfg, loaded, err := config.LoadConfigFingerprint(cfgFile)
 	if err != nil {
 		return err
 	}
@@ -434,11 +501,12 @@ func runConfigEdit(cmd *cobra.Command) error {
 	rule.Script = script
 
 	// Save config
-	if err := config.SaveConfig(cfgFile, cfg); err != nil {
+	if _, err := config.SaveConfigFingerprint(cfgFile, cfg, loaded); err != nil {
 		return err
 	}
 
 	fmt.Fprintln(cmd.OutOrStdout(), "Rule updated successfully")
+	autoPushOnChange(cmd, cfg)
 	return nil
 }
 
@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/SuzumiyaAoba/via/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var configConvertCmd = &cobra.Command{
+	Use:   "convert --to <format>",
+	Short: "Convert the config file to another format",
+	Long: `Convert the config file to YAML, TOML, JSON or JSONC.
+
+The converted file replaces the original next to it (config.yml becomes
+config.toml) and the original is kept in the backups directory. With --output
+the converted config is written there and the original stays in use.
+Comments are not carried over; every setting is, and the conversion is
+refused if reading the result back would not give the same config.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		to, _ := cmd.Flags().GetString("to")
+		output, _ := cmd.Flags().GetString("output")
+		return runConfigConvert(cmd, to, output)
+	},
+}
+
+func init() {
+	configConvertCmd.Flags().String("to", "", "Target format: yaml, toml, json or jsonc")
+	configConvertCmd.Flags().StringP("output", "o", "", "Write the converted config to this file and keep the original")
+	configConvertCmd.MarkFlagRequired("to")
+}
+
+func runConfigConvert(cmd *cobra.Command, to, output string) error {
+	configPath, err := config.GetConfigPath(cfgFile)
+	if err != nil {
+		return err
+	}
+	format, err := config.ParseFormat(to)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	if output == "" && config.FormatOf(configPath) == format {
+		fmt.Fprintf(out, "Configuration is already in %s format\n", format)
+		return nil
+	}
+
+	dest := output
+	if dest == "" {
+		dest = strings.TrimSuffix(configPath, filepath.Ext(configPath)) + format.Ext()
+	}
+	if fileExists(dest) {
+		return fmt.Errorf("%s already exists", dest)
+	}
+
+	data, err := convertConfig(configPath, format)
+	if err != nil {
+		return err
+	}
+	if err := config.WriteConfigFile(dest, data); err != nil {
+		return err
+	}
+
+	if output == "" {
+		backup, err := config.SaveBackup(configPath)
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(configPath); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", configPath, err)
+		}
+		if backup != "" {
+			fmt.Fprintf(out, "Previous file saved to %s\n", backup)
+		}
+	}
+	fmt.Fprintf(out, "Configuration converted to %s: %s\n", format, dest)
+	return nil
+}
+
+// convertConfig renders a config file in another format, making sure that reading
+// the result back gives the same config
+func convertConfig(path string, format config.Format) ([]byte, error) {
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := config.Encode(cfg, format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert config to %s: %w", format, err)
+	}
+
+	converted, err := config.Decode(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read back the converted config: %w", err)
+	}
+	want, err := config.Encode(cfg, config.FormatYAML)
+	if err != nil {
+		return nil, err
+	}
+	got, err := config.Encode(converted, config.FormatYAML)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(want, got) {
+		return nil, fmt.Errorf("converting %s to %s would lose settings", path, format)
+	}
+	return data, nil
+}
@@ -0,0 +1,316 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/SuzumiyaAoba/via/internal/config"
+	"github.com/SuzumiyaAoba/via/internal/daemon"
+	"github.com/SuzumiyaAoba/via/internal/executor"
+	"github.com/SuzumiyaAoba/via/internal/logger"
+	"github.com/spf13/cobra"
+)
+
+var daemonCmd = &cobra.Command{
+	Use:   ":daemon",
+	Short: "Keep configs loaded in a background process that vv hands its work to",
+	Long: `Listen on a Unix socket and answer open, --explain and :match requests with
+configs that stay loaded between runs. A config is reloaded when its file, or
+a file its rules come from, changes.
+
+While the daemon is running, vv sends it the work and only runs the resulting
+commands itself, in its own terminal. Set VIA_NO_DAEMON=1 to bypass it.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runDaemon(cmd)
+	},
+}
+
+var daemonStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether the daemon is running",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runDaemonStatus(cmd)
+	},
+}
+
+var daemonStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop the running daemon",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runDaemonStop(cmd)
+	},
+}
+
+func init() {
+	daemonCmd.AddCommand(daemonStatusCmd)
+	daemonCmd.AddCommand(daemonStopCmd)
+}
+
+// Operations the daemon serves for the CLI
+const (
+	daemonOpOpen    = "open"
+	daemonOpExplain = "explain"
+	daemonOpMatch   = "match"
+)
+
+// daemonSocketPath is a variable to allow changing the socket in tests
+var daemonSocketPath = daemon.SocketPath
+
+// daemonParams are the parameters of every daemon operation
+type daemonParams struct {
+	Config string       `json:"config"` // --config as resolved from the profile, "" for the default
+	Args   []string     `json:"args"`
+	DryRun bool         `json:"dry_run,omitempty"`
+	Match  matchOptions `json:"match"`
+}
+
+// openResult is what the daemon answers to an open request: the commands the
+// CLI runs itself, so that they get its terminal
+type openResult struct {
+	Plan           []executor.Invocation `json:"plan"`
+	Fallback       []executor.Invocation `json:"fallback,omitempty"` // Run instead when the plan fails, as handleOpen does
+	HistoryExclude []string              `json:"history_exclude,omitempty"`
+	Output         string                `json:"output,omitempty"` // Messages of the automatic sync
+}
+
+// matchResult is what the daemon answers to a :match request
+type matchResult struct {
+	Output  string `json:"output"`
+	NoMatch bool   `json:"no_match,omitempty"`
+}
+
+func runDaemon(cmd *cobra.Command) error {
+	path := daemonSocketPath()
+	ln, err := daemon.Listen(path)
+	if err != nil {
+		return err
+	}
+
+	handler := &daemonHandler{configs: newConfigCache()}
+	server := daemon.NewServer(handler.handle)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		if _, ok := <-signals; ok {
+			server.Close()
+		}
+	}()
+
+	fmt.Fprintf(cmd.OutOrStdout(), "Daemon listening on %s\n", path)
+	logger.Info("Daemon listening on %s", path)
+	if err := server.Serve(ln); err != nil {
+		return fmt.Errorf("daemon failed: %w", err)
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), "Daemon stopped")
+	return nil
+}
+
+func runDaemonStatus(cmd *cobra.Command) error {
+	path := daemonSocketPath()
+	var status daemon.Status
+	if err := daemon.Call(path, &daemon.Request{Op: daemon.OpPing}, &status); err != nil {
+		if errors.Is(err, daemon.ErrUnavailable) {
+			return fmt.Errorf("no daemon running on %s", path)
+		}
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Daemon running on %s\n", path)
+	fmt.Fprintf(cmd.OutOrStdout(), "  PID:     %d\n", status.PID)
+	fmt.Fprintf(cmd.OutOrStdout(), "  Uptime:  %s\n", time.Since(status.Started).Round(time.Second))
+	fmt.Fprintf(cmd.OutOrStdout(), "  Served:  %s\n", countOf(status.Served, "request"))
+	return nil
+}
+
+func runDaemonStop(cmd *cobra.Command) error {
+	path := daemonSocketPath()
+	if err := daemon.Call(path, &daemon.Request{Op: daemon.OpStop}, nil); err != nil {
+		if errors.Is(err, daemon.ErrUnavailable) {
+			return fmt.Errorf("no daemon running on %s", path)
+		}
+		return err
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), "Daemon stopped")
+	return nil
+}
+
+// daemonHandler answers CLI requests from cached configs. Requests are handled
+// one at a time, in the working directory and environment of the caller.
+type daemonHandler struct {
+	configs *configCache
+}
+
+func (h *daemonHandler) handle(req *daemon.Request) (any, error) {
+	var params daemonParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+
+	path, err := config.GetConfigPath(params.Config)
+	if err != nil {
+		return nil, err
+	}
+	path = absPath(path)
+
+	// Automatic sync reloads the config from cfgFile
+	defer func(saved string) { cfgFile = saved }(cfgFile)
+	cfgFile = path
+
+	cfg, err := h.configs.load(path)
+
+	switch req.Op {
+	case daemonOpMatch:
+		if err != nil {
+			return nil, err
+		}
+		return daemonMatch(cfg, params)
+	case daemonOpOpen, daemonOpExplain:
+		if err != nil {
+			return nil, fmt.Errorf("error loading config: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown operation %q", req.Op)
+	}
+
+	var out bytes.Buffer
+	if !params.DryRun {
+		syncCmd := &cobra.Command{}
+		syncCmd.SetOut(&out)
+		syncCmd.SetErr(&out)
+		cfg = autoPullOnStart(syncCmd, cfg)
+	}
+	if cfg, err = applyProjectConfigs(cfg, params.Args); err != nil {
+		return nil, fmt.Errorf("error loading config: %w", err)
+	}
+
+	if req.Op == daemonOpExplain {
+		return explainMatch(cfg, params.Args[0]), nil
+	}
+
+	result := openResult{Output: out.String()}
+	if cfg.History != nil {
+		result.HistoryExclude = cfg.History.Exclude
+	}
+	// Recorded commands cannot fail, so a file is planned as a command too, for
+	// the client to run when the commands of its rules fail
+	plan := &result.Plan
+	exec := &executor.Executor{Record: func(inv executor.Invocation) {
+		*plan = append(*plan, inv)
+	}}
+	if len(params.Args) == 1 {
+		if err := handleFileExecution(cfg, exec, params.Args[0]); err == nil {
+			plan = &result.Fallback
+		} else {
+			result.Plan = nil
+		}
+	}
+	if err := handleCommandExecution(cfg, exec, params.Args); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// daemonMatch runs :match for a single input and captures what it prints
+func daemonMatch(cfg *config.Config, params daemonParams) (matchResult, error) {
+	var out bytes.Buffer
+	cmd := &cobra.Command{}
+	cmd.SetOut(&out)
+
+	err := matchInput(cmd, cfg, params.Args[0], params.Match, "")
+	if errors.Is(err, errNoMatch) {
+		return matchResult{Output: out.String(), NoMatch: true}, nil
+	}
+	if err != nil {
+		return matchResult{}, err
+	}
+	return matchResult{Output: out.String()}, nil
+}
+
+// callDaemon sends an operation to the running daemon. It returns an error
+// wrapping daemon.ErrUnavailable when there is none, or when VIA_NO_DAEMON is set.
+func callDaemon(op string, params daemonParams, result any) error {
+	if isTruthy(os.Getenv("VIA_NO_DAEMON")) {
+		return daemon.ErrUnavailable
+	}
+	req, err := daemon.NewRequest(op, params)
+	if err != nil {
+		return fmt.Errorf("%w: %v", daemon.ErrUnavailable, err)
+	}
+	err = daemon.Call(daemonSocketPath(), req, result)
+	if errors.Is(err, daemon.ErrUnavailable) {
+		logger.Debug("Not using the daemon: %v", err)
+	}
+	return err
+}
+
+// openWithDaemon opens args through the running daemon, the way runRoot does
+// locally. It reports false if there is no daemon to use.
+func openWithDaemon(cmd *cobra.Command, args []string) (bool, error) {
+	params := daemonParams{Config: cfgFile, Args: args, DryRun: dryRun}
+
+	if explain && len(args) == 1 {
+		var e explanation
+		if err := callDaemon(daemonOpExplain, params, &e); errors.Is(err, daemon.ErrUnavailable) {
+			return false, nil
+		} else if err != nil {
+			return true, err
+		}
+		return true, printExplanation(cmd, e)
+	}
+
+	var result openResult
+	if err := callDaemon(daemonOpOpen, params, &result); errors.Is(err, daemon.ErrUnavailable) {
+		return false, nil
+	} else if err != nil {
+		return true, err
+	}
+	logger.Debug("Daemon planned %d commands for %v", len(result.Plan), args)
+
+	fmt.Fprint(cmd.OutOrStdout(), result.Output)
+	configureHistory(&config.Config{History: &config.HistoryConfig{Exclude: result.HistoryExclude}})
+	exec := executor.NewExecutor(cmd.OutOrStdout(), dryRun)
+	err := replay(exec, result.Plan)
+	if err != nil && result.Fallback != nil {
+		logger.Debug("Running %v as a command: %v", args, err)
+		err = replay(exec, result.Fallback)
+	}
+	return true, err
+}
+
+// replay runs the invocations of a plan in order, stopping at the first that fails
+func replay(exec *executor.Executor, plan []executor.Invocation) error {
+	for _, inv := range plan {
+		if err := exec.Replay(inv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchWithDaemon runs :match for a single input through the running daemon.
+// It reports false if there is no daemon to use.
+func matchWithDaemon(cmd *cobra.Command, filename string, opts matchOptions) (bool, error) {
+	var result matchResult
+	params := daemonParams{Config: cfgFile, Args: []string{filename}, Match: opts}
+	if err := callDaemon(daemonOpMatch, params, &result); errors.Is(err, daemon.ErrUnavailable) {
+		return false, nil
+	} else if err != nil {
+		return true, err
+	}
+
+	fmt.Fprint(cmd.OutOrStdout(), result.Output)
+	if result.NoMatch {
+		return true, errNoMatch
+	}
+	return true, nil
+}
@@ -30,7 +30,7 @@ func runDashboard(cmd *cobra.Command) error {
 		return err
 	}
 
-	cfg, err := config.LoadConfig(cfgFile)
+	cfg, loaded, err := config.LoadConfigFingerprint(cfgFile)
 	if err != nil {
 		// Only ignore if file does not exist, but for now let's return error
 		// because swallowing parsing errors is bad.
@@ -42,6 +42,15 @@ func runDashboard(cmd *cobra.Command) error {
 	if err != nil {
 		return err
 	}
+	model.Loaded = loaded
+	if profile != "" {
+		model.Profile = profile
+	}
+	profiles, err := config.ListProfiles()
+	if err != nil {
+		return err
+	}
+	model.Profiles = append([]string{config.DefaultProfile}, profiles...)
 
 	if _, err := runTeaProgram(model, tea.WithAltScreen()); err != nil {
 		return fmt.Errorf("error running dashboard: %w", err)
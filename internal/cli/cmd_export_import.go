@@ -2,7 +2,6 @@ package cli
 
 import (
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 
@@ -46,6 +45,13 @@ func runConfigExport(cmd *cobra.Command, destPath string) error {
 		return fmt.Errorf("failed to read config file: %w", err)
 	}
 
+	// Convert when the destination has another format's extension
+	if format := config.FormatOf(absDest); format != config.FormatOf(configPath) {
+		if data, err = convertConfig(configPath, format); err != nil {
+			return err
+		}
+	}
+
 	// Write destination
 	if err := os.WriteFile(absDest, data, 0644); err != nil {
 		return fmt.Errorf("failed to write export file: %w", err)
@@ -72,21 +78,17 @@ func runConfigImport(cmd *cobra.Command, srcPath string) error {
 		return fmt.Errorf("invalid configuration content: %w", err)
 	}
 
-	// Copy file
-	srcFile, err := os.Open(srcPath)
+	data, err := os.ReadFile(srcPath)
 	if err != nil {
-		return fmt.Errorf("failed to open source file: %w", err)
+		return fmt.Errorf("failed to read source file: %w", err)
 	}
-	defer srcFile.Close()
-
-	dstFile, err := os.Create(configPath)
-	if err != nil {
-		return fmt.Errorf("failed to create config file: %w", err)
+	if format := config.FormatOf(configPath); format != config.FormatOf(srcPath) {
+		if data, err = convertConfig(srcPath, format); err != nil {
+			return err
+		}
 	}
-	defer dstFile.Close()
-
-	if _, err := io.Copy(dstFile, srcFile); err != nil {
-		return fmt.Errorf("failed to copy config: %w", err)
+	if err := config.WriteConfigFile(configPath, data); err != nil {
+		return err
 	}
 
 	fmt.Fprintf(cmd.OutOrStdout(), "Configuration imported from %s\n", srcPath)
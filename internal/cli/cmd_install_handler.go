@@ -0,0 +1,204 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/SuzumiyaAoba/via/internal/config"
+	"github.com/SuzumiyaAoba/via/internal/desktop"
+	"github.com/SuzumiyaAoba/via/internal/logger"
+	"github.com/SuzumiyaAoba/via/internal/sync"
+	"github.com/spf13/cobra"
+)
+
+var installHandlerCmd = &cobra.Command{
+	Use:   ":install-handler [scheme|mime-type...]",
+	Short: "Register vv as the default application on Linux",
+	Long: `Register vv as the default application for URL schemes and MIME types, so
+that xdg-open and the desktop hand them to vv and its rules.
+
+This writes a desktop entry to $XDG_DATA_HOME/applications/via.desktop and
+makes it the default in $XDG_CONFIG_HOME/mimeapps.list. A bare scheme such as
+https stands for x-scheme-handler/https. Without arguments, http and https are
+registered. With --uninstall, the given types, or all of them, are removed
+again and the previous defaults take over.`,
+	Example: `  vv :install-handler
+  vv :install-handler https mailto application/pdf
+  vv :install-handler --dry-run text/plain
+  vv :install-handler --uninstall`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		uninstall, _ := cmd.Flags().GetBool("uninstall")
+		return runInstallHandler(cmd, args, uninstall)
+	},
+}
+
+func init() {
+	installHandlerCmd.Flags().Bool("uninstall", false, "Remove vv as the default application")
+	installHandlerCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show the file changes without writing them")
+}
+
+// Variables to allow mocking in tests
+var (
+	handlerExecutable     = os.Executable
+	updateDesktopDatabase = func(dir string) error {
+		if _, err := exec.LookPath("update-desktop-database"); err != nil {
+			return nil
+		}
+		return exec.Command("update-desktop-database", dir).Run()
+	}
+)
+
+// defaultHandlerTypes are registered when :install-handler is given none
+var defaultHandlerTypes = []string{"x-scheme-handler/http", "x-scheme-handler/https"}
+
+func runInstallHandler(cmd *cobra.Command, args []string, uninstall bool) error {
+	if runtime.GOOS == "darwin" || runtime.GOOS == "windows" {
+		return fmt.Errorf(":install-handler is not supported on %s", runtime.GOOS)
+	}
+
+	var types []string
+	for _, arg := range args {
+		t, err := desktop.NormalizeType(arg)
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(types, t) {
+			types = append(types, t)
+		}
+	}
+
+	entryPath, err := desktop.EntryPath()
+	if err != nil {
+		return err
+	}
+	mimeAppsPath, err := desktop.MimeAppsPath()
+	if err != nil {
+		return err
+	}
+	before, err := readHandlerFiles(entryPath, mimeAppsPath)
+	if err != nil {
+		return err
+	}
+	registered := desktop.EntryTypes(string(before[entryPath]))
+
+	exe, err := handlerExecutable()
+	if err != nil {
+		return fmt.Errorf("failed to find the vv executable: %w", err)
+	}
+
+	after := map[string][]byte{}
+	var entryTypes []string
+	if uninstall {
+		// Without arguments, remove vv from every type, including ones set by hand
+		if len(types) > 0 {
+			entryTypes = slices.DeleteFunc(slices.Clone(registered), func(t string) bool { return slices.Contains(types, t) })
+		}
+		after[mimeAppsPath] = []byte(desktop.RemoveDefaults(string(before[mimeAppsPath]), desktop.EntryID, types))
+	} else {
+		if len(types) == 0 {
+			types = defaultHandlerTypes
+		}
+		entryTypes = slices.Clone(registered)
+		for _, t := range types {
+			if !slices.Contains(entryTypes, t) {
+				entryTypes = append(entryTypes, t)
+			}
+		}
+		after[mimeAppsPath] = []byte(desktop.AddDefaults(string(before[mimeAppsPath]), desktop.EntryID, types))
+	}
+	if len(entryTypes) > 0 {
+		after[entryPath] = []byte(desktop.Entry(exe, entryTypes))
+	}
+	if len(after[mimeAppsPath]) == 0 && before[mimeAppsPath] == nil {
+		delete(after, mimeAppsPath)
+	}
+
+	out := cmd.OutOrStdout()
+	changes := sync.DiffFiles(before, after)
+	if len(changes) == 0 {
+		if uninstall {
+			fmt.Fprintln(out, "vv is not registered as a default application")
+		} else {
+			fmt.Fprintln(out, "vv is already the default application for "+describeTypes(types))
+		}
+		return nil
+	}
+
+	fmt.Fprintln(out, "Changes:")
+	for _, c := range changes {
+		fmt.Fprint(out, c.String())
+	}
+	if dryRun {
+		fmt.Fprintln(out, "Dry run: nothing was written")
+		return nil
+	}
+
+	for _, c := range changes {
+		if err := writeHandlerFile(c.Key, after[c.Key]); err != nil {
+			return err
+		}
+	}
+	if err := updateDesktopDatabase(filepath.Dir(entryPath)); err != nil {
+		logger.Debug("update-desktop-database failed: %v", err)
+	}
+
+	if uninstall {
+		if len(entryTypes) == 0 {
+			fmt.Fprintln(out, "vv is no longer a default application")
+		} else {
+			fmt.Fprintln(out, "vv is no longer the default application for "+describeTypes(types))
+		}
+	} else {
+		fmt.Fprintln(out, "vv is now the default application for "+describeTypes(types))
+	}
+	return nil
+}
+
+// readHandlerFiles reads the files :install-handler edits, leaving out missing ones
+func readHandlerFiles(paths ...string) (map[string][]byte, error) {
+	files := map[string][]byte{}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		files[path] = data
+	}
+	return files, nil
+}
+
+// writeHandlerFile replaces a file, or removes it when data is nil
+func writeHandlerFile(path string, data []byte) error {
+	if data == nil {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := config.WriteFileAtomic(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// describeTypes lists types the way they were given, schemes without their prefix
+func describeTypes(types []string) string {
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = strings.TrimPrefix(t, "x-scheme-handler/")
+	}
+	return strings.Join(names, ", ")
+}
@@ -1,43 +1,174 @@
 package cli
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/SuzumiyaAoba/via/internal/config"
+	"github.com/SuzumiyaAoba/via/internal/executor"
 	"github.com/SuzumiyaAoba/via/internal/matcher"
 	"github.com/spf13/cobra"
 )
 
 var matchCmd = &cobra.Command{
-	Use:   ":match <file>",
+	Use:   ":match <file|->",
 	Short: "Check if a file matches any rule",
-	Args:  cobra.ExactArgs(1),
+	Long: `Check which rule handles a file or URL without running anything.
+
+With - as the argument, paths are read from stdin, one per line, and every
+result line starts with the path and a tab.`,
+	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runMatch(cmd, args[0])
+		var opts matchOptions
+		opts.All, _ = cmd.Flags().GetBool("all")
+		opts.Command, _ = cmd.Flags().GetBool("command")
+		opts.JSON, _ = cmd.Flags().GetBool("json")
+		return runMatch(cmd, args[0], opts)
 	},
 }
 
-func runMatch(cmd *cobra.Command, filename string) error {
-	cfg, err := config.LoadConfig(cfgFile)
+func init() {
+	matchCmd.Flags().Bool("all", false, "Show every matching rule, not only the ones that would run")
+	matchCmd.Flags().Bool("command", false, "Print the rendered commands that would run instead of rule names")
+	matchCmd.Flags().Bool("json", false, "Print the file information, rule evaluation and action as JSON")
+}
+
+type matchOptions struct {
+	All     bool // Use every matching rule instead of stopping at the first without fallthrough
+	Command bool // Print the rendered commands instead of rule names
+	JSON    bool
+}
+
+// errNoMatch is returned for an input that no rule matches
+var errNoMatch = errors.New("no match found")
+
+func runMatch(cmd *cobra.Command, filename string, opts matchOptions) error {
+	if opts.JSON && (opts.All || opts.Command) {
+		return fmt.Errorf("--json cannot be combined with --all or --command")
+	}
+
+	if filename != "-" {
+		if handled, err := matchWithDaemon(cmd, filename, opts); handled {
+			return err
+		}
+	}
+
+	cfg, err := config.LoadResolvedConfig(cfgFile)
 	if err != nil {
 		return err
 	}
 
-	matches, err := matcher.Match(cfg.Rules, filename)
+	if filename != "-" {
+		return matchInput(cmd, cfg, filename, opts, "")
+	}
+
+	// Batch mode: one path per line, results prefixed with the path
+	var total, missed int
+	scanner := bufio.NewScanner(cmd.InOrStdin())
+	for scanner.Scan() {
+		path := strings.TrimSpace(scanner.Text())
+		if path == "" {
+			continue
+		}
+		total++
+		if err := matchInput(cmd, cfg, path, opts, path+"\t"); errors.Is(err, errNoMatch) {
+			missed++
+		} else if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read paths from stdin: %w", err)
+	}
+	if missed > 0 {
+		return fmt.Errorf("no match found for %d of %d paths", missed, total)
+	}
+	return nil
+}
+
+// matchInput prints the result for one input, starting every line with prefix.
+// It returns errNoMatch if no rule matches, after printing what would happen instead.
+func matchInput(cmd *cobra.Command, cfg *config.Config, filename string, opts matchOptions, prefix string) error {
+	if opts.JSON {
+		e := explainMatch(cfg, filename)
+		// JSON Lines in batch mode
+		if prefix != "" {
+			data, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), string(data))
+		} else if err := printJSON(cmd, e); err != nil {
+			return err
+		}
+		if !e.Matched {
+			return errNoMatch
+		}
+		return nil
+	}
+
+	match := matcher.Match
+	if opts.All {
+		match = matcher.MatchAll
+	}
+	matches, err := match(cfg.Rules, filename)
 	if err != nil {
 		return err
 	}
 
-	if len(matches) > 0 {
-		rule := matches[0]
-		name := rule.Name
-		if name == "" {
-			name = rule.Command
+	var lines []string
+	switch {
+	case opts.Command:
+		// Render through a dry run, so scripts, fallthrough and the default command apply
+		var buf bytes.Buffer
+		exec := executor.NewExecutor(&buf, true)
+		switch {
+		case len(matches) == 0:
+			err = executeWithDefault(cfg, exec, filename)
+		case opts.All:
+			for _, rule := range matches {
+				if _, err = executeRule(exec, rule, filename); err != nil {
+					break
+				}
+			}
+		default:
+			err = executeRules(exec, matches, filename)
 		}
-		fmt.Fprintln(cmd.OutOrStdout(), name)
-		return nil
+		if err != nil {
+			return err
+		}
+		if buf.Len() > 0 {
+			lines = strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+		}
+	case opts.All:
+		for _, rule := range matches {
+			lines = append(lines, matchName(rule))
+		}
+	case len(matches) > 0:
+		lines = []string{matchName(matches[0])}
+	}
+
+	if len(lines) == 0 && prefix != "" {
+		// Keep one line per path in batch mode
+		lines = []string{""}
+	}
+	for _, line := range lines {
+		fmt.Fprintln(cmd.OutOrStdout(), prefix+line)
 	}
 
-	// No match found
-	return fmt.Errorf("no match found")
+	if len(matches) == 0 {
+		return errNoMatch
+	}
+	return nil
+}
+
+func matchName(rule *config.Rule) string {
+	if rule.Name == "" {
+		return rule.Command
+	}
+	return rule.Name
 }
@@ -0,0 +1,142 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/SuzumiyaAoba/via/internal/config"
+	"github.com/SuzumiyaAoba/via/internal/history"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var configMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Upgrade the config file to the current schema version",
+	Long: `Upgrade the config file to the current schema version.
+
+Older configs are migrated in memory whenever they are loaded; this command
+writes the result back, keeping comments and key order. The previous file is
+kept in the backups directory next to the config. When no config exists yet,
+the config of the old ~/.config/entry location is migrated instead.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runConfigMigrate(cmd)
+	},
+}
+
+func init() {
+	configMigrateCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show the changes without writing them")
+}
+
+func runConfigMigrate(cmd *cobra.Command) error {
+	configPath, err := config.GetConfigPath(cfgFile)
+	if err != nil {
+		return err
+	}
+
+	// Pick up a config left in the directory used before the rename
+	source := configPath
+	if cfgFile == "" && !fileExists(configPath) {
+		if legacy, err := config.LegacyConfigPath(); err == nil && fileExists(legacy) {
+			source = legacy
+		}
+	}
+
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	format := config.FormatOf(source)
+	converted, err := config.ToYAML(data, format)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", source, err)
+	}
+	root, changes, err := config.MigrateDocument(converted)
+	if err != nil {
+		return fmt.Errorf("failed to migrate %s: %w", source, err)
+	}
+	if source != configPath {
+		changes = append([]string{fmt.Sprintf("copy %s -> %s", source, configPath)}, changes...)
+	}
+	historyFrom, historyTo := legacyHistoryMove()
+	if historyFrom != "" {
+		changes = append(changes, fmt.Sprintf("move %s -> %s", historyFrom, historyTo))
+	}
+
+	out := cmd.OutOrStdout()
+	if len(changes) == 0 {
+		fmt.Fprintf(out, "Configuration is already at version %s\n", config.CurrentVersion)
+		return nil
+	}
+
+	fmt.Fprintf(out, "Changes to %s:\n", configPath)
+	for _, c := range changes {
+		fmt.Fprintf(out, "  %s\n", c)
+	}
+	if dryRun {
+		fmt.Fprintln(out, "Dry run: nothing was written")
+		return nil
+	}
+
+	backup, err := config.BackupOutdated(configPath)
+	if err != nil {
+		return err
+	}
+
+	migrated, err := encodeMigrated(root, format, data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+	if err := config.WriteConfigFile(configPath, migrated); err != nil {
+		return err
+	}
+
+	if historyFrom != "" {
+		if err := os.MkdirAll(filepath.Dir(historyTo), 0755); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := os.Rename(historyFrom, historyTo); err != nil {
+			return fmt.Errorf("failed to move history: %w", err)
+		}
+	}
+
+	if backup != "" {
+		fmt.Fprintf(out, "Previous version saved to %s\n", backup)
+	}
+	fmt.Fprintf(out, "Configuration migrated to version %s\n", config.CurrentVersion)
+	return nil
+}
+
+// legacyHistoryMove returns the history left in the directory used before the
+// rename and where it belongs, or empty strings if there is nothing to move.
+// Only the default config takes the history along.
+func legacyHistoryMove() (from, to string) {
+	if cfgFile != "" {
+		return "", ""
+	}
+	legacy, err := history.LegacyHistoryPath()
+	if err != nil || !fileExists(legacy) {
+		return "", ""
+	}
+	current, err := history.DefaultHistoryPath()
+	if err != nil {
+		return "", ""
+	}
+	if fileExists(current) {
+		return "", ""
+	}
+	return legacy, current
+}
+
+// encodeMigrated writes a migrated document back in the format it was read from
+func encodeMigrated(root *yaml.Node, format config.Format, original []byte) ([]byte, error) {
+	if format == config.FormatYAML {
+		return config.EncodeDocument(root, config.DetectIndent(original))
+	}
+	var cfg config.Config
+	if err := root.Decode(&cfg); err != nil {
+		return nil, err
+	}
+	return config.Encode(&cfg, format)
+}
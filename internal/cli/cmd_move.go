@@ -27,45 +27,48 @@ var configMoveCmd = &cobra.Command{
 }
 
 func runConfigMove(cmd *cobra.Command, from, to int) error {
-	cfg, err := config.LoadConfig(cfgFile)
+	var cfg *config.Config
+	err := config.UpdateConfig(cfgFile, func(c *config.Config) error {
+		if from < 1 || from > len(c.Rules) {
+			return fmt.Errorf("from_index out of range: %d", from)
+		}
+		if to < 1 || to > len(c.Rules) {
+			return fmt.Errorf("to_index out of range: %d", to)
+		}
+		if from == to {
+			return nil
+		}
+
+		// Adjust to 0-based index
+		fromIdx := from - 1
+		toIdx := to - 1
+
+		// Move the element
+		rule := c.Rules[fromIdx]
+
+		// Remove from source
+		c.Rules = append(c.Rules[:fromIdx], c.Rules[fromIdx+1:]...)
+
+		// Insert at destination
+		// If toIdx is now greater than length (because we removed one), it means append
+		if toIdx >= len(c.Rules) {
+			c.Rules = append(c.Rules, rule)
+		} else {
+			c.Rules = append(c.Rules[:toIdx], append([]config.Rule{rule}, c.Rules[toIdx:]...)...)
+		}
+		cfg = c
+		return nil
+	})
 	if err != nil {
 		return err
 	}
 
-	if from < 1 || from > len(cfg.Rules) {
-		return fmt.Errorf("from_index out of range: %d", from)
-	}
-	if to < 1 || to > len(cfg.Rules) {
-		return fmt.Errorf("to_index out of range: %d", to)
-	}
-
 	if from == to {
 		fmt.Fprintln(cmd.OutOrStdout(), "Source and destination are the same")
 		return nil
 	}
 
-	// Adjust to 0-based index
-	fromIdx := from - 1
-	toIdx := to - 1
-
-	// Move the element
-	rule := cfg.Rules[fromIdx]
-	
-	// Remove from source
-	cfg.Rules = append(cfg.Rules[:fromIdx], cfg.Rules[fromIdx+1:]...)
-	
-	// Insert at destination
-	// If toIdx is now greater than length (because we removed one), it means append
-	if toIdx >= len(cfg.Rules) {
-		cfg.Rules = append(cfg.Rules, rule)
-	} else {
-		cfg.Rules = append(cfg.Rules[:toIdx], append([]config.Rule{rule}, cfg.Rules[toIdx:]...)...)
-	}
-
-	if err := config.SaveConfig(cfgFile, cfg); err != nil {
-		return fmt.Errorf("failed to save config: %w", err)
-	}
-
 	fmt.Fprintf(cmd.OutOrStdout(), "Rule moved from %d to %d\n", from, to)
+	autoPushOnChange(cmd, cfg)
 	return nil
 }
@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	"github.com/SuzumiyaAoba/via/internal/config"
+	"github.com/charmbracelet/huh"
+	"github.com/spf13/cobra"
+)
+
+var configRestoreCmd = &cobra.Command{
+	Use:   "restore [backup]",
+	Short: "Restore the config file from a backup",
+	Long: `Restore the config file from a backup.
+
+Every change to the config keeps the previous version in the backups directory
+next to it, up to the last 10. The backup can be given by its number in
+--list (1 is the newest) or its file name; without one you are asked to pick.
+The current config is backed up before it is replaced, so a restore can be undone.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		list, _ := cmd.Flags().GetBool("list")
+		if list {
+			return runConfigRestoreList(cmd)
+		}
+		return runConfigRestore(cmd, args)
+	},
+}
+
+func init() {
+	configRestoreCmd.Flags().Bool("list", false, "List the backups without restoring")
+}
+
+// selectBackup asks the user which backup to restore
+var selectBackup = func(backups []config.Backup) (config.Backup, error) {
+	options := make([]huh.Option[config.Backup], len(backups))
+	for i, b := range backups {
+		options[i] = huh.NewOption(fmt.Sprintf("%d. %s", i+1, formatBackupTime(b)), b)
+	}
+
+	var selected config.Backup
+	err := huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[config.Backup]().
+				Title("Select a backup to restore").
+				Options(options...).
+				Value(&selected),
+		),
+	).Run()
+	return selected, err
+}
+
+func formatBackupTime(b config.Backup) string {
+	return b.TakenAt.Local().Format("2006-01-02 15:04:05")
+}
+
+func runConfigRestoreList(cmd *cobra.Command) error {
+	configPath, err := config.GetConfigPath(cfgFile)
+	if err != nil {
+		return err
+	}
+	backups, err := config.ListBackups(configPath)
+	if err != nil {
+		return err
+	}
+	if len(backups) == 0 {
+		fmt.Fprintf(cmd.OutOrStdout(), "No backups of %s\n", configPath)
+		return nil
+	}
+
+	rows := make([][]string, len(backups))
+	for i, b := range backups {
+		rows[i] = []string{strconv.Itoa(i + 1), formatBackupTime(b), filepath.Base(b.Path)}
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), createStyledTable([]string{"#", "Saved", "File"}, rows))
+	return nil
+}
+
+func runConfigRestore(cmd *cobra.Command, args []string) error {
+	configPath, err := config.GetConfigPath(cfgFile)
+	if err != nil {
+		return err
+	}
+	backups, err := config.ListBackups(configPath)
+	if err != nil {
+		return err
+	}
+	if len(backups) == 0 {
+		return fmt.Errorf("no backups of %s", configPath)
+	}
+
+	var backup config.Backup
+	if len(args) == 0 {
+		if backup, err = selectBackup(backups); err != nil {
+			return err
+		}
+	} else if backup, err = findBackup(backups, args[0]); err != nil {
+		return err
+	}
+
+	if err := config.RestoreBackup(configPath, backup.Path); err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Configuration restored from %s (%s)\n", filepath.Base(backup.Path), formatBackupTime(backup))
+	return nil
+}
+
+// findBackup looks a backup up by its number in the list or its file name
+func findBackup(backups []config.Backup, ref string) (config.Backup, error) {
+	if n, err := strconv.Atoi(ref); err == nil {
+		if n < 1 || n > len(backups) {
+			return config.Backup{}, fmt.Errorf("backup number %d out of range (1-%d)", n, len(backups))
+		}
+		return backups[n-1], nil
+	}
+	for _, b := range backups {
+		if filepath.Base(b.Path) == filepath.Base(ref) {
+			return b, nil
+		}
+	}
+	return config.Backup{}, fmt.Errorf("backup %q not found, see :config restore --list", ref)
+}
@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/SuzumiyaAoba/via/internal/config"
+	"github.com/SuzumiyaAoba/via/internal/matcher"
+	"github.com/spf13/cobra"
+)
+
+var configTestCmd = &cobra.Command{
+	Use:   "test",
+	Short: "Run the test cases declared in rules",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runConfigTest(cmd)
+	},
+}
+
+func runConfigTest(cmd *cobra.Command) error {
+	cfg, err := config.LoadResolvedConfig(cfgFile)
+	if err != nil {
+		return err
+	}
+
+	results := matcher.RunTests(cfg.Rules)
+	if len(results) == 0 {
+		fmt.Fprintln(cmd.OutOrStdout(), "No rule tests found")
+		return nil
+	}
+
+	failed := 0
+	for _, r := range results {
+		if r.Passed() {
+			fmt.Fprintf(cmd.OutOrStdout(), "PASS  %s: %s\n", r.Rule.Label(), r.Test.Input)
+			continue
+		}
+		failed++
+		if r.Err != nil {
+			fmt.Fprintf(cmd.OutOrStdout(), "FAIL  %s: %s: %v\n", r.Rule.Label(), r.Test.Input, r.Err)
+			continue
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "FAIL  %s: %s: expected %s, matched %s\n",
+			r.Rule.Label(), r.Test.Input, expectedRule(r), matchedRules(r.Matched))
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "\n%d passed, %d failed\n", len(results)-failed, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d rule tests failed", failed, len(results))
+	}
+	return nil
+}
+
+func expectedRule(r matcher.TestResult) string {
+	switch {
+	case r.Test.ExpectNone:
+		return "no rule"
+	case r.Test.Expect == "":
+		return testRuleName(r.Rule)
+	}
+	return fmt.Sprintf("%q", r.Test.Expect)
+}
+
+func matchedRules(rules []*config.Rule) string {
+	if len(rules) == 0 {
+		return "no rule"
+	}
+	names := make([]string, len(rules))
+	for i, rule := range rules {
+		names[i] = testRuleName(rule)
+	}
+	return strings.Join(names, ", ")
+}
+
+// testRuleName names a rule the way :match prints it
+func testRuleName(rule *config.Rule) string {
+	if rule.Name == "" {
+		return fmt.Sprintf("%q", rule.Command)
+	}
+	return fmt.Sprintf("%q", rule.Name)
+}
@@ -0,0 +1,368 @@
+package cli
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/SuzumiyaAoba/via/internal/config"
+	"github.com/SuzumiyaAoba/via/internal/executor"
+	"github.com/SuzumiyaAoba/via/internal/history"
+	"github.com/SuzumiyaAoba/via/internal/logger"
+	"github.com/spf13/cobra"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   ":serve",
+	Short: "Serve matching and opening over HTTP on localhost",
+	Long: `Serve an HTTP API on 127.0.0.1 so browser extensions and other tools can
+route links and files through the rules:
+
+  POST /open      {"target": "<url or absolute path>", "dry_run": false}
+  GET  /match     ?target=<url or absolute path>
+  GET  /rules
+  GET  /history   ?limit=<n>
+
+POST /open answers as soon as the commands are started, without waiting
+for them to finish.
+
+Every request needs an "Authorization: Bearer <token>" header. The token is
+read from --token-file, then VIA_SERVE_TOKEN, and is generated and printed
+otherwise. It is not taken as a flag, which would show it in the process list
+and shell history.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		port, _ := cmd.Flags().GetInt("port")
+		tokenFile, _ := cmd.Flags().GetString("token-file")
+		return runServe(cmd, port, tokenFile)
+	},
+}
+
+func init() {
+	serveCmd.Flags().Int("port", 7777, "Port to listen on")
+	serveCmd.Flags().String("token-file", "", "File holding the token clients must send (default $VIA_SERVE_TOKEN, or a generated one)")
+}
+
+// serveShutdownTimeout bounds how long running requests may take when the server stops
+var serveShutdownTimeout = 5 * time.Second
+
+func runServe(cmd *cobra.Command, port int, tokenFile string) error {
+	token, generated, err := serveToken(tokenFile)
+	if err != nil {
+		return err
+	}
+
+	path, err := config.GetConfigPath(cfgFile)
+	if err != nil {
+		return err
+	}
+
+	ln, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+	server := &http.Server{
+		Handler:           newServeHandler(absPath(path), token),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		if _, ok := <-signals; ok {
+			ctx, cancel := context.WithTimeout(context.Background(), serveShutdownTimeout)
+			defer cancel()
+			server.Shutdown(ctx)
+		}
+	}()
+
+	fmt.Fprintf(cmd.OutOrStdout(), "Serving on http://%s\n", ln.Addr())
+	if generated {
+		fmt.Fprintf(cmd.OutOrStdout(), "Token: %s\n", token)
+	}
+	logger.Info("Serving on http://%s", ln.Addr())
+
+	if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("server failed: %w", err)
+	}
+	return nil
+}
+
+// serveToken returns the token from tokenFile or VIA_SERVE_TOKEN, or a new random
+// one, and whether it was generated
+func serveToken(tokenFile string) (string, bool, error) {
+	if tokenFile != "" {
+		data, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return "", false, fmt.Errorf("failed to read token: %w", err)
+		}
+		token := strings.TrimSpace(string(data))
+		if token == "" {
+			return "", false, fmt.Errorf("token file %s is empty", tokenFile)
+		}
+		return token, false, nil
+	}
+	if env := os.Getenv("VIA_SERVE_TOKEN"); env != "" {
+		return env, false, nil
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", false, fmt.Errorf("failed to generate token: %w", err)
+	}
+	return hex.EncodeToString(b), true, nil
+}
+
+// serveHandler implements the HTTP API of :serve
+type serveHandler struct {
+	configPath string
+	token      string
+	configs    *configCache
+
+	// Held while a target is opened: history settings are global and commands
+	// are run the way vv runs them, one after the other
+	openMu sync.Mutex
+}
+
+// newServeHandler returns the API for the config at configPath, requiring token
+func newServeHandler(configPath, token string) http.Handler {
+	s := &serveHandler{configPath: configPath, token: token, configs: newConfigCache()}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /open", s.open)
+	mux.HandleFunc("GET /match", s.match)
+	mux.HandleFunc("GET /rules", s.rules)
+	mux.HandleFunc("GET /history", s.history)
+	return s.authorize(mux)
+}
+
+// authorize rejects requests without the token
+func (s *serveHandler) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, errors.New("missing or invalid token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// openRequest is the body of POST /open
+type openRequest struct {
+	Target string `json:"target"`
+	DryRun bool   `json:"dry_run"`
+}
+
+// openResponse describes what POST /open runs
+type openResponse struct {
+	Target   string   `json:"target"`
+	Commands []string `json:"commands"` // The command lines, as --dry-run prints them
+	DryRun   bool     `json:"dry_run"`
+}
+
+func (s *serveHandler) open(w http.ResponseWriter, r *http.Request) {
+	var req openRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	if err := checkTarget(req.Target); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	cfg, err := s.config(req.Target)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	rules, err := matchRules(cfg, req.Target)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("error matching rule: %w", err))
+		return
+	}
+	if len(rules) == 0 && !isFileOrURL(req.Target) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("%s does not exist and no rule matches it", req.Target))
+		return
+	}
+
+	// Record what would run first, so the response can list it
+	var plan []executor.Invocation
+	recorder := &executor.Executor{Record: func(inv executor.Invocation) { plan = append(plan, inv) }}
+	if len(rules) > 0 {
+		err = executeRules(recorder, rules, req.Target)
+	} else {
+		err = executeWithDefault(cfg, recorder, req.Target)
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	resp := openResponse{Target: req.Target, Commands: []string{}, DryRun: req.DryRun}
+	for _, inv := range plan {
+		if line := renderDryRun(func(exec *executor.Executor) error { return exec.Replay(inv) }); line != "" {
+			resp.Commands = append(resp.Commands, line)
+		}
+	}
+
+	if req.DryRun {
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+	// Commands may run as long as the application they open, so they are run
+	// after answering, and their errors only go to the log
+	go s.run(cfg, req.Target, plan)
+	writeJSON(w, http.StatusAccepted, resp)
+}
+
+// run runs the commands planned for a target, one target at a time
+func (s *serveHandler) run(cfg *config.Config, target string, plan []executor.Invocation) {
+	s.openMu.Lock()
+	defer s.openMu.Unlock()
+
+	configureHistory(cfg)
+	exec := executor.NewExecutor(io.Discard, false)
+	for _, inv := range plan {
+		if err := exec.Replay(inv); err != nil {
+			logger.Error("Failed to open %s through the HTTP API: %v", target, err)
+			return
+		}
+	}
+	logger.Info("Opened %s through the HTTP API", target)
+}
+
+// match answers with the same JSON as :match --json
+func (s *serveHandler) match(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("target")
+	if err := checkTarget(target); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	cfg, err := s.config(target)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, explainMatch(cfg, target))
+}
+
+// serveRule is a rule as listed by GET /rules
+type serveRule struct {
+	Index       int               `json:"index"` // 1-based, as in :config list
+	Name        string            `json:"name,omitempty"`
+	Extensions  []string          `json:"extensions,omitempty"`
+	Regex       string            `json:"regex,omitempty"`
+	Mime        string            `json:"mime,omitempty"`
+	Scheme      string            `json:"scheme,omitempty"`
+	OS          []string          `json:"os,omitempty"`
+	Script      string            `json:"script,omitempty"`
+	Command     string            `json:"command"`
+	Env         map[string]string `json:"env,omitempty"`
+	Background  bool              `json:"background,omitempty"`
+	Terminal    bool              `json:"terminal,omitempty"`
+	Fallthrough bool              `json:"fallthrough,omitempty"`
+	Source      string            `json:"source,omitempty"`
+}
+
+func (s *serveHandler) rules(w http.ResponseWriter, r *http.Request) {
+	cfg, err := s.configs.load(s.configPath)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("error loading config: %w", err))
+		return
+	}
+
+	rules := make([]serveRule, len(cfg.Rules))
+	for i, rule := range cfg.Rules {
+		rules[i] = serveRule{
+			Index:       i + 1,
+			Name:        rule.Name,
+			Extensions:  rule.Extensions,
+			Regex:       rule.Regex,
+			Mime:        rule.Mime,
+			Scheme:      rule.Scheme,
+			OS:          rule.OS,
+			Script:      rule.Script,
+			Command:     rule.Command,
+			Env:         rule.Env,
+			Background:  rule.Background,
+			Terminal:    rule.Terminal,
+			Fallthrough: rule.Fallthrough,
+		}
+		if rule.Source != nil {
+			rules[i].Source = rule.Source.String()
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"rules": rules, "default_command": cfg.DefaultCommand})
+}
+
+// history lists the history, newest first
+func (s *serveHandler) history(w http.ResponseWriter, r *http.Request) {
+	entries, err := history.LoadHistory()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to load history: %w", err))
+		return
+	}
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid limit %q", value))
+			return
+		}
+		entries = entries[:min(limit, len(entries))]
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"entries": entries})
+}
+
+// checkTarget rejects targets the API does not take. Targets are never run as commands
+// or aliases, and relative paths would depend on where the server was started.
+func checkTarget(target string) error {
+	switch {
+	case target == "":
+		return errors.New("target is required")
+	case !isURL(target) && !filepath.IsAbs(target):
+		return errors.New("target must be a URL or an absolute path")
+	}
+	return nil
+}
+
+// config returns the config for a target, with its project files applied
+func (s *serveHandler) config(target string) (*config.Config, error) {
+	cfg, err := s.configs.load(s.configPath)
+	if err != nil {
+		return nil, fmt.Errorf("error loading config: %w", err)
+	}
+	if cfg, err = applyProjectConfigs(cfg, []string{target}); err != nil {
+		return nil, fmt.Errorf("error loading config: %w", err)
+	}
+	return cfg, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -1,18 +1,26 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"maps"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 
 	"github.com/SuzumiyaAoba/via/internal/config"
+	"github.com/SuzumiyaAoba/via/internal/history"
 	"github.com/SuzumiyaAoba/via/internal/sync"
 	"github.com/charmbracelet/huh"
+	"github.com/mattn/go-isatty"
 	"github.com/spf13/cobra"
 )
 
 var configSyncCmd = &cobra.Command{
 	Use:   "sync",
-	Short: "Sync configuration with Gist",
+	Short: "Sync configuration with a remote backend",
 }
 
 var configSyncInitCmd = &cobra.Command{
@@ -25,7 +33,7 @@ var configSyncInitCmd = &cobra.Command{
 
 var configSyncPushCmd = &cobra.Command{
 	Use:   "push",
-	Short: "Push configuration to Gist",
+	Short: "Push configuration to the sync backend",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		return runConfigSyncPush(cmd)
 	},
@@ -33,22 +41,78 @@ var configSyncPushCmd = &cobra.Command{
 
 var configSyncPullCmd = &cobra.Command{
 	Use:   "pull",
-	Short: "Pull configuration from Gist",
+	Short: "Pull configuration from the sync backend",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		return runConfigSyncPull(cmd)
 	},
 }
 
+var configSyncStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether the configuration is ahead of, behind or diverged from the sync backend",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runConfigSyncStatus(cmd)
+	},
+}
+
+var configSyncDiffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Show rule-by-rule differences between the local and remote configuration",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runConfigSyncDiff(cmd)
+	},
+}
+
+var configSyncLogCmd = &cobra.Command{
+	Use:   "log",
+	Short: "List remote revisions of the configuration",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runConfigSyncLog(cmd)
+	},
+}
+
+var configSyncCheckoutCmd = &cobra.Command{
+	Use:   "checkout <revision>",
+	Short: "Restore the configuration from a remote revision",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runConfigSyncCheckout(cmd, args[0])
+	},
+}
+
+var configSyncUndoCmd = &cobra.Command{
+	Use:   "undo",
+	Short: "Restore the configuration from before the last pull or checkout",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runConfigSyncUndo(cmd)
+	},
+}
+
+var syncLogLimit int
+
 func init() {
 	configSyncCmd.AddCommand(configSyncInitCmd)
 	configSyncCmd.AddCommand(configSyncPushCmd)
 	configSyncCmd.AddCommand(configSyncPullCmd)
+	configSyncCmd.AddCommand(configSyncStatusCmd)
+	configSyncCmd.AddCommand(configSyncDiffCmd)
+	configSyncCmd.AddCommand(configSyncLogCmd)
+	configSyncCmd.AddCommand(configSyncCheckoutCmd)
+	configSyncCmd.AddCommand(configSyncUndoCmd)
+
+	configSyncPushCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be pushed without pushing")
+	configSyncPullCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be pulled without changing the local config")
+	configSyncCheckoutCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be restored without changing the local config")
+	configSyncLogCmd.Flags().IntVarP(&syncLogLimit, "limit", "n", 20, "Maximum number of revisions to list (0 for all)")
 }
 
 // SyncInitInput holds the input gathered from the user
 type SyncInitInput struct {
+	Backend    string // gist (default), git, dir or webdav
 	CreateNew  bool
 	GistID     string
+	Location   string // Repository, directory or URL for non-gist backends
+	Username   string
 	Token      string
 	StoreToken bool
 }
@@ -57,6 +121,76 @@ type SyncInitInput struct {
 var getSyncInitInput = func() (SyncInitInput, error) {
 	var input SyncInitInput
 
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[string]().
+				Title("Sync backend").
+				Options(
+					huh.NewOption("GitHub Gist", sync.BackendGist),
+					huh.NewOption("Git repository", sync.BackendGit),
+					huh.NewOption("Local or shared directory", sync.BackendDir),
+					huh.NewOption("WebDAV", sync.BackendWebDAV),
+				).
+				Value(&input.Backend),
+		),
+	)
+
+	if err := form.Run(); err != nil {
+		return input, err
+	}
+
+	switch input.Backend {
+	case sync.BackendGist:
+		return getGistInitInput(input)
+	case sync.BackendGit:
+		form = huh.NewForm(
+			huh.NewGroup(
+				huh.NewInput().
+					Title("Repository").
+					Description("URL or path of the git repository (a bare repository works too)").
+					Value(&input.Location),
+			),
+		)
+	case sync.BackendDir:
+		form = huh.NewForm(
+			huh.NewGroup(
+				huh.NewInput().
+					Title("Directory").
+					Description("Local or shared directory (e.g. a mounted drive)").
+					Value(&input.Location),
+			),
+		)
+	case sync.BackendWebDAV:
+		form = huh.NewForm(
+			huh.NewGroup(
+				huh.NewInput().
+					Title("URL").
+					Description("URL of the config file, or a collection ending with /").
+					Value(&input.Location),
+				huh.NewInput().
+					Title("Username").
+					Value(&input.Username),
+				huh.NewInput().
+					Title("Password or Token").
+					EchoMode(huh.EchoModePassword).
+					Value(&input.Token),
+				huh.NewConfirm().
+					Title("Store password?").
+					Description("Saved to a credentials file readable only by you. It is never synced.").
+					Value(&input.StoreToken),
+			),
+		)
+	}
+
+	if err := form.Run(); err != nil {
+		return input, err
+	}
+
+	return input, nil
+}
+
+// getGistInitInput gathers the Gist specific input
+func getGistInitInput(input SyncInitInput) (SyncInitInput, error) {
 	form := huh.NewForm(
 		huh.NewGroup(
 			huh.NewConfirm().
@@ -98,8 +232,8 @@ var getSyncInitInput = func() (SyncInitInput, error) {
 	}
 
 	confirm := huh.NewConfirm().
-		Title("Store token in config?").
-		Description("WARNING: This is insecure as the config file is plain text.").
+		Title("Store token?").
+		Description("Saved to a credentials file readable only by you. It is never synced.").
 		Value(&input.StoreToken)
 	
 	if err := confirm.Run(); err != nil {
@@ -109,20 +243,60 @@ var getSyncInitInput = func() (SyncInitInput, error) {
 	return input, nil
 }
 
-// SyncClient interface for mocking
-type SyncClient interface {
+// GistCreator creates new Gists during sync initialization
+type GistCreator interface {
 	CreateGist(cfg *config.Config, public bool) (string, error)
-	UpdateGist(gistID string, cfg *config.Config) error
-	GetGist(gistID string) (*config.Config, error)
 }
 
-// newSyncClient creates a new sync client
-var newSyncClient = func(token string) SyncClient {
+// newGistCreator creates a Gist client, can be swapped for testing
+var newGistCreator = func(token string) GistCreator {
 	return sync.NewClient(token)
 }
 
+// newSyncBackend creates the configured sync backend, can be swapped for testing
+var newSyncBackend = func(cfg *config.SyncConfig) (sync.Backend, error) {
+	return sync.NewBackend(cfg)
+}
+
+// loadSyncBackend resolves the sync settings of cfg (filling the token from
+// token_command or the environment) and creates the backend. A relative git
+// repository is taken relative to the directory of configPath. If requireToken is set, Gist backends
+// without a token are rejected.
+func loadSyncBackend(cfg *config.Config, configPath string, requireToken bool) (sync.Backend, error) {
+	if cfg.Sync == nil {
+		return nil, fmt.Errorf("sync not initialized. Run 'vv :config sync init' first")
+	}
+
+	if err := sync.ValidateAuto(cfg.Sync); err != nil {
+		return nil, err
+	}
+
+	syncCfg := *cfg.Sync
+	token, err := sync.ResolveToken(&syncCfg)
+	if err != nil {
+		return nil, err
+	}
+	syncCfg.Token = token
+	if syncCfg.BackendName() == sync.BackendGit {
+		syncCfg.Repo = sync.ResolveRepo(syncCfg.Repo, filepath.Dir(configPath))
+	}
+
+	if requireToken && syncCfg.BackendName() == sync.BackendGist && syncCfg.GistID != "" && syncCfg.Token == "" {
+		return nil, fmt.Errorf("token not found. Set token_command, run 'vv :config sync init' to store it, or set %s", sync.TokenEnvVar)
+	}
+
+	backend, err := newSyncBackend(&syncCfg)
+	if errors.Is(err, sync.ErrNotConfigured) {
+		return nil, fmt.Errorf("sync not initialized. Run 'vv :config sync init' first")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return backend, nil
+}
+
 func runConfigSyncInit(cmd *cobra.Command) error {
-	cfg, err := config.LoadConfig(cfgFile)
+	cfg, loaded, err := config.LoadConfigFingerprint(cfgFile)
 	if err != nil {
 		return err
 	}
@@ -132,30 +306,51 @@ func runConfigSyncInit(cmd *cobra.Command) error {
 		return err
 	}
 
-	client := newSyncClient(input.Token)
-	gistID := input.GistID
+	if cfg.Sync == nil {
+		cfg.Sync = &config.SyncConfig{}
+	}
 
-	if input.CreateNew {
-		id, err := client.CreateGist(cfg, false) // Default to private
-		if err != nil {
-			return err
+	backendName := input.Backend
+	if backendName == "" {
+		backendName = sync.BackendGist
+	}
+
+	switch backendName {
+	case sync.BackendGist:
+		gistID := input.GistID
+		if input.CreateNew {
+			id, err := newGistCreator(input.Token).CreateGist(cfg, false) // Default to private
+			if err != nil {
+				return err
+			}
+			gistID = id
+			fmt.Fprintf(cmd.OutOrStdout(), "Created new Gist: %s\n", gistID)
 		}
-		gistID = id
-		fmt.Fprintf(cmd.OutOrStdout(), "Created new Gist: %s\n", gistID)
+		cfg.Sync.GistID = gistID
+	case sync.BackendGit:
+		cfg.Sync.Repo = input.Location
+	case sync.BackendDir:
+		cfg.Sync.Path = input.Location
+	case sync.BackendWebDAV:
+		cfg.Sync.URL = input.Location
+		cfg.Sync.Username = input.Username
+	default:
+		return fmt.Errorf("unknown sync backend: %s", backendName)
 	}
 
-	if cfg.Sync == nil {
-		cfg.Sync = &config.SyncConfig{}
+	// Gist is the default, so it is not written out explicitly
+	cfg.Sync.Backend = ""
+	if backendName != sync.BackendGist {
+		cfg.Sync.Backend = backendName
 	}
-	cfg.Sync.GistID = gistID
 
 	if input.StoreToken {
 		cfg.Sync.Token = input.Token
-	} else {
-		fmt.Fprintln(cmd.OutOrStdout(), "Token not stored. You will need to provide it via ENTRY_GITHUB_TOKEN env var.")
+	} else if input.Token != "" || backendName == sync.BackendGist {
+		fmt.Fprintf(cmd.OutOrStdout(), "Token not stored. Set sync.token_command or provide it via the %s env var.\n", sync.TokenEnvVar)
 	}
 
-	if err := config.SaveConfig(cfgFile, cfg); err != nil {
+	if _, err := config.SaveConfigFingerprint(cfgFile, cfg, loaded); err != nil {
 		return err
 	}
 
@@ -163,62 +358,630 @@ func runConfigSyncInit(cmd *cobra.Command) error {
 	return nil
 }
 
+// newConflictResolver returns the resolver used when both sides changed the same item.
+// Outside a terminal it returns nil, so conflicts fail with a diff. Can be swapped for testing.
+var newConflictResolver = func() sync.Resolver {
+	if !isatty.IsTerminal(os.Stdin.Fd()) {
+		return nil
+	}
+	return func(c sync.Conflict) (sync.Side, error) {
+		var side sync.Side
+		err := huh.NewSelect[sync.Side]().
+			Title(fmt.Sprintf("Conflict in %s %q", c.Kind, c.Key)).
+			Description(c.String()).
+			Options(
+				huh.NewOption("Keep local", sync.SideLocal),
+				huh.NewOption("Use remote", sync.SideRemote),
+			).
+			Value(&side).
+			Run()
+		return side, err
+	}
+}
+
+// syncSession holds everything push, pull, status and diff need about the local side
+type syncSession struct {
+	backend    sync.Backend
+	configPath string
+	local      *config.Config     // Local config as stored on this machine
+	loaded     config.Fingerprint // Content of the config file local was read from
+	shared     *config.Config     // Part of the local config that is synced
+	state      *sync.State        // Last sync, nil if never synced
+	files      *sync.LocalFiles
+}
+
+// openSyncSession loads the local config, its sync state and the backend
+func openSyncSession(requireToken bool) (*syncSession, error) {
+	cfg, loaded, err := config.LoadConfigFingerprint(cfgFile)
+	if err != nil {
+		return nil, err
+	}
+
+	configPath, err := config.GetConfigPath(cfgFile)
+	if err != nil {
+		return nil, err
+	}
+
+	backend, err := loadSyncBackend(cfg, configPath, requireToken)
+	if err != nil {
+		return nil, err
+	}
+
+	shared, err := sync.Shareable(cfg, cfg.Sync)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := sync.LoadState(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	historyPath, err := history.GetHistoryPath()
+	if err != nil {
+		return nil, err
+	}
+
+	return &syncSession{
+		backend:    backend,
+		configPath: configPath,
+		local:      cfg,
+		loaded:     loaded,
+		shared:     shared,
+		state:      state,
+		files:      &sync.LocalFiles{Root: filepath.Dir(configPath), HistoryPath: historyPath},
+	}, nil
+}
+
+// save replaces the local config with cfg. The remote is contacted between
+// loading and saving, so the file is not locked meanwhile; saving fails instead
+// if it was changed in the meantime, rather than losing that change.
+func (s *syncSession) save(cfg *config.Config) error {
+	saved, err := config.SaveConfigFingerprint(s.configPath, cfg, s.loaded)
+	if err != nil {
+		return err
+	}
+	s.loaded = saved
+	return nil
+}
+
+// pullRemote fetches the remote config and returns it as pulled and as the synced part
+func (s *syncSession) pullRemote() (raw, shared *config.Config, err error) {
+	raw, err = s.backend.Pull()
+	if err != nil {
+		return nil, nil, err
+	}
+	shared, err = sync.Shareable(raw, s.local.Sync)
+	if err != nil {
+		return nil, nil, err
+	}
+	return raw, shared, nil
+}
+
+// base returns the config recorded at the last sync, or fallback if it has never been synced
+func (s *syncSession) base(fallback *config.Config) (*config.Config, error) {
+	if s.state == nil {
+		return fallback, nil
+	}
+	return s.state.BaseConfig()
+}
+
+// fileSet holds the extra files on both sides and the plan to reconcile them
+type fileSet struct {
+	local  map[string][]byte
+	remote map[string][]byte
+	plan   *sync.FilePlan
+}
+
+// collectFiles reads the extra files on both sides. It returns nil if the backend only syncs the config.
+func (s *syncSession) collectFiles() (*fileSet, error) {
+	fileBackend, ok := s.backend.(sync.FileBackend)
+	if !ok {
+		return nil, nil
+	}
+
+	local, err := s.files.Collect(s.local.Sync)
+	if err != nil {
+		return nil, err
+	}
+	remote, err := fileBackend.PullFiles()
+	if err != nil {
+		return nil, err
+	}
+	return &fileSet{local: local, remote: sync.SelectFiles(s.local.Sync, remote)}, nil
+}
+
+// planFiles collects the extra files and plans how to reconcile them in the given direction
+func (s *syncSession) planFiles(direction sync.Direction, resolve sync.Resolver) (*fileSet, error) {
+	files, err := s.collectFiles()
+	if err != nil || files == nil {
+		return nil, err
+	}
+
+	var base map[string]string
+	if s.state != nil {
+		base = s.state.Files
+	}
+
+	files.plan, err = sync.PlanFiles(base, files.local, files.remote, direction, resolve)
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// apply writes the planned file changes on both sides and returns how many files changed
+func (s *syncSession) applyFiles(files *fileSet) (int, error) {
+	if files == nil {
+		return 0, nil
+	}
+	if err := s.files.Apply(files.plan.Local); err != nil {
+		return 0, err
+	}
+	if err := s.backend.(sync.FileBackend).PushFiles(files.plan.Remote); err != nil {
+		return 0, err
+	}
+	return len(files.plan.Local) + len(files.plan.Remote), nil
+}
+
+// saveState records base as the last synced config. The remote revision is best effort.
+func (s *syncSession) saveState(base *config.Config, files *fileSet) error {
+	revision := ""
+	if status, err := s.backend.Status(); err == nil {
+		revision = status.Revision
+	}
+
+	state, err := sync.NewState(base, revision)
+	if err != nil {
+		return err
+	}
+	if files != nil {
+		state.Files = files.plan.Base
+	} else if s.state != nil {
+		state.Files = s.state.Files
+	}
+	return sync.SaveState(s.configPath, state)
+}
+
+// snapshot saves the local config and the files about to be overwritten, so 'vv :config sync undo' can restore them
+func (s *syncSession) snapshot(reason string, files *fileSet) error {
+	var changed []string
+	if files != nil {
+		changed = slices.Sorted(maps.Keys(files.plan.Local))
+	}
+	return sync.TakeSnapshot(s.configPath, reason, s.files, changed)
+}
+
+// printChanges writes one block per change, or a note if there is none
+func printChanges(w io.Writer, changes []sync.Change) {
+	if len(changes) == 0 {
+		fmt.Fprintln(w, "No changes")
+		return
+	}
+	for _, c := range changes {
+		fmt.Fprint(w, c.String())
+	}
+}
+
+// dryRunResolver keeps dry runs from prompting, so conflicts are reported instead
+func dryRunResolver() sync.Resolver {
+	if dryRun {
+		return nil
+	}
+	return newConflictResolver()
+}
+
 func runConfigSyncPush(cmd *cobra.Command) error {
-	cfg, err := config.LoadConfig(cfgFile)
+	s, err := openSyncSession(true)
+	if err != nil {
+		return err
+	}
+	return s.push(cmd.OutOrStdout(), dryRunResolver())
+}
+
+// push merges remote changes if needed and uploads the config and extra files
+func (s *syncSession) push(out io.Writer, resolve sync.Resolver) error {
+	raw, remote, err := s.pullRemote()
+	if err != nil && !errors.Is(err, sync.ErrRemoteNotFound) {
+		return err
+	}
+
+	merged := s.shared
+	if remote != nil {
+		// Without a recorded sync, the local config is assumed to be newer
+		base, err := s.base(remote)
+		if err != nil {
+			return err
+		}
+
+		relation, err := sync.Compare(base, s.shared, remote)
+		if err != nil {
+			return err
+		}
+
+		switch relation {
+		case sync.Behind:
+			return fmt.Errorf("%s has changes that are not pulled yet. Run 'vv :config sync pull' first", s.backend.Name())
+		case sync.Diverged:
+			if merged, err = sync.Merge(base, s.shared, remote, resolve); err != nil {
+				return err
+			}
+		}
+	}
+
+	files, err := s.planFiles(sync.DirectionPush, resolve)
 	if err != nil {
 		return err
 	}
 
-	if cfg.Sync == nil || cfg.Sync.GistID == "" {
-		return fmt.Errorf("sync not initialized. Run 'et :config sync init' first")
+	// Host sections never leave this machine, the remote keeps its own version
+	upload := merged
+	if raw != nil && s.local.Sync != nil {
+		upload = sync.CopySections(merged, raw, s.local.Sync.HostSections)
 	}
 
-	token := cfg.Sync.Token
-	if token == "" {
-		token = os.Getenv("ENTRY_GITHUB_TOKEN")
+	if dryRun {
+		if remote == nil {
+			remote = &config.Config{}
+		}
+		fmt.Fprintf(out, "Would push to %s:\n", s.backend.Name())
+		changes := sync.Diff(remote, merged)
+		if files != nil {
+			changes = append(changes, sync.DiffFiles(files.remote, sync.Overlay(files.remote, files.plan.Remote))...)
+		}
+		printChanges(out, changes)
+		return nil
 	}
-	if token == "" {
-		return fmt.Errorf("token not found. Set ENTRY_GITHUB_TOKEN or store it in config")
+
+	if merged != s.shared {
+		if err := s.save(sync.WithHostSections(merged, s.local)); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Merged remote changes from %s\n", s.backend.Name())
 	}
 
-	client := sync.NewClient(token)
-	if err := client.UpdateGist(cfg.Sync.GistID, cfg); err != nil {
+	if err := s.backend.Push(upload); err != nil {
 		return err
 	}
 
-	fmt.Fprintln(cmd.OutOrStdout(), "Configuration pushed to Gist")
+	synced, err := s.applyFiles(files)
+	if err != nil {
+		return err
+	}
+
+	if err := s.saveState(merged, files); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Configuration pushed to %s\n", s.backend.Name())
+	if synced > 0 {
+		fmt.Fprintf(out, "Synced %d file(s)\n", synced)
+	}
 	return nil
 }
 
 func runConfigSyncPull(cmd *cobra.Command) error {
-	cfg, err := config.LoadConfig(cfgFile)
+	// Token might not be needed for public gists, but usually good to have.
+	s, err := openSyncSession(false)
 	if err != nil {
 		return err
 	}
+	out := cmd.OutOrStdout()
+	resolve := dryRunResolver()
 
-	if cfg.Sync == nil || cfg.Sync.GistID == "" {
-		return fmt.Errorf("sync not initialized. Run 'et :config sync init' first")
+	_, remote, err := s.pullRemote()
+	if err != nil {
+		return err
 	}
 
-	token := cfg.Sync.Token
-	if token == "" {
-		token = os.Getenv("ENTRY_GITHUB_TOKEN")
+	// Without a recorded sync, the remote config is assumed to be newer
+	base, err := s.base(s.shared)
+	if err != nil {
+		return err
 	}
-	// Token might not be needed for public gists, but usually good to have.
-	
-	client := sync.NewClient(token)
-	newCfg, err := client.GetGist(cfg.Sync.GistID)
+
+	relation, err := sync.Compare(base, s.shared, remote)
+	if err != nil {
+		return err
+	}
+
+	// pulled stays nil when the local config is kept as is
+	var pulled *config.Config
+	switch relation {
+	case sync.InSync, sync.Behind:
+		pulled = remote
+	case sync.Diverged:
+		if pulled, err = sync.Merge(base, s.shared, remote, resolve); err != nil {
+			return err
+		}
+	}
+
+	files, err := s.planFiles(sync.DirectionPull, resolve)
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		fmt.Fprintf(out, "Would pull from %s:\n", s.backend.Name())
+		var changes []sync.Change
+		if pulled != nil {
+			changes = sync.Diff(s.shared, pulled)
+		}
+		if files != nil {
+			changes = append(changes, sync.DiffFiles(files.local, sync.Overlay(files.local, files.plan.Local))...)
+		}
+		printChanges(out, changes)
+		return nil
+	}
+
+	if relation == sync.Behind || relation == sync.Diverged || files != nil && len(files.plan.Local) > 0 {
+		if err := s.snapshot("pull", files); err != nil {
+			return err
+		}
+	}
+
+	if pulled != nil {
+		// Preserve local sync settings and host sections
+		if err := s.save(sync.WithHostSections(pulled, s.local)); err != nil {
+			return err
+		}
+	}
+
+	synced, err := s.applyFiles(files)
+	if err != nil {
+		return err
+	}
+
+	// Local changes that are not pushed yet keep the previous base
+	stateBase := remote
+	if relation == sync.Ahead {
+		stateBase = base
+	}
+	if err := s.saveState(stateBase, files); err != nil {
+		return err
+	}
+
+	switch relation {
+	case sync.Ahead:
+		fmt.Fprintf(out, "No remote changes in %s. Local changes are not pushed yet\n", s.backend.Name())
+	case sync.Diverged:
+		fmt.Fprintf(out, "Merged remote changes from %s. Run 'vv :config sync push' to publish the result\n", s.backend.Name())
+	default:
+		fmt.Fprintf(out, "Configuration pulled from %s\n", s.backend.Name())
+	}
+	if synced > 0 {
+		fmt.Fprintf(out, "Synced %d file(s)\n", synced)
+	}
+	return nil
+}
+
+func runConfigSyncStatus(cmd *cobra.Command) error {
+	s, err := openSyncSession(false)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	status, err := s.backend.Status()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Backend: %s\n", s.backend.Name())
+	fmt.Fprintf(out, "Location: %s\n", status.Location)
+	if !status.Exists {
+		fmt.Fprintln(out, "Status: remote is empty. Run 'vv :config sync push' to upload your config")
+		return nil
+	}
+	if status.Revision != "" {
+		fmt.Fprintf(out, "Remote revision: %s\n", status.Revision)
+	}
+	if !status.UpdatedAt.IsZero() {
+		fmt.Fprintf(out, "Remote updated: %s\n", status.UpdatedAt.Local().Format("2006-01-02 15:04:05"))
+	}
+
+	_, remote, err := s.pullRemote()
+	if err != nil {
+		return err
+	}
+
+	if s.state == nil {
+		localHash, err := sync.Hash(s.shared)
+		if err != nil {
+			return err
+		}
+		remoteHash, err := sync.Hash(remote)
+		if err != nil {
+			return err
+		}
+		if localHash == remoteHash {
+			fmt.Fprintf(out, "Status: %s\n", sync.InSync)
+		} else {
+			fmt.Fprintln(out, "Status: never synced. Run 'vv :config sync diff' to compare")
+		}
+		return nil
+	}
+
+	fmt.Fprintf(out, "Last synced: %s\n", s.state.SyncedAt.Local().Format("2006-01-02 15:04:05"))
+
+	base, err := s.state.BaseConfig()
+	if err != nil {
+		return err
+	}
+	relation, err := sync.Compare(base, s.shared, remote)
 	if err != nil {
 		return err
 	}
 
-	// Preserve local sync settings
-	newCfg.Sync = cfg.Sync
+	switch relation {
+	case sync.Ahead:
+		fmt.Fprintf(out, "Status: %s. Run 'vv :config sync push' to upload local changes\n", relation)
+	case sync.Behind:
+		fmt.Fprintf(out, "Status: %s. Run 'vv :config sync pull' to get remote changes\n", relation)
+	case sync.Diverged:
+		fmt.Fprintf(out, "Status: %s. Both sides changed, 'vv :config sync pull' will merge them\n", relation)
+	default:
+		fmt.Fprintf(out, "Status: %s\n", relation)
+	}
 
-	if err := config.SaveConfig(cfgFile, newCfg); err != nil {
+	// Planning in both directions shows which files each side would change
+	toPush, err := s.planFiles(sync.DirectionPush, nil)
+	var conflictErr *sync.ConflictError
+	if errors.As(err, &conflictErr) {
+		fmt.Fprintf(out, "Files: %d conflict(s)\n", len(conflictErr.Conflicts))
+		return nil
+	}
+	if err != nil || toPush == nil {
+		return err
+	}
+	toPull, err := sync.PlanFiles(s.state.Files, toPush.local, toPush.remote, sync.DirectionPull, nil)
+	if err != nil {
+		return err
+	}
+	if n, m := len(toPush.plan.Remote), len(toPull.Local); n > 0 || m > 0 {
+		fmt.Fprintf(out, "Files: %d to push, %d to pull\n", n, m)
+	}
+	return nil
+}
+
+func runConfigSyncDiff(cmd *cobra.Command) error {
+	s, err := openSyncSession(false)
+	if err != nil {
+		return err
+	}
+
+	_, remote, err := s.pullRemote()
+	if errors.Is(err, sync.ErrRemoteNotFound) {
+		remote = &config.Config{}
+	} else if err != nil {
+		return err
+	}
+
+	changes := sync.Diff(s.shared, remote)
+	files, err := s.collectFiles()
+	if err != nil {
+		return err
+	}
+	if files != nil {
+		changes = append(changes, sync.DiffFiles(files.local, files.remote)...)
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "--- local\n+++ %s\n", s.backend.Name())
+	printChanges(cmd.OutOrStdout(), changes)
+	return nil
+}
+
+// historyBackend returns the backend of s if it keeps past revisions
+func (s *syncSession) historyBackend() (sync.HistoryBackend, error) {
+	backend, ok := s.backend.(sync.HistoryBackend)
+	if !ok {
+		return nil, fmt.Errorf("%s does not keep revisions, only the latest configuration", s.backend.Name())
+	}
+	return backend, nil
+}
+
+func runConfigSyncLog(cmd *cobra.Command) error {
+	s, err := openSyncSession(false)
+	if err != nil {
+		return err
+	}
+	backend, err := s.historyBackend()
+	if err != nil {
+		return err
+	}
+
+	revisions, err := backend.Revisions()
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	if len(revisions) == 0 {
+		fmt.Fprintln(out, "No revisions")
+		return nil
+	}
+	if syncLogLimit > 0 && len(revisions) > syncLogLimit {
+		revisions = revisions[:syncLogLimit]
+	}
+
+	// The revision of the last sync is marked, so it is clear what the local config is based on
+	marked := false
+	for _, r := range revisions {
+		marker := " "
+		if s.state != nil && r.ID == s.state.Revision {
+			marker = "*"
+			marked = true
+		}
+		line := fmt.Sprintf("%s %s  %s  %s", marker, r.ShortID(), r.CreatedAt.Local().Format("2006-01-02 15:04:05"), r.Message)
+		fmt.Fprintln(out, strings.TrimRight(line, " "))
+	}
+	if marked {
+		fmt.Fprintln(out, "\n* last synced revision")
+	}
+	return nil
+}
+
+func runConfigSyncCheckout(cmd *cobra.Command, revision string) error {
+	s, err := openSyncSession(false)
+	if err != nil {
+		return err
+	}
+	backend, err := s.historyBackend()
+	if err != nil {
+		return err
+	}
+
+	restored, err := backend.PullRevision(revision)
+	if err != nil {
+		return err
+	}
+	shared, err := sync.Shareable(restored, s.local.Sync)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	if dryRun {
+		fmt.Fprintf(out, "Would restore revision %s:\n", revision)
+		printChanges(out, sync.Diff(s.shared, shared))
+		return nil
+	}
+
+	if err := s.snapshot("checkout of "+revision, nil); err != nil {
+		return err
+	}
+	if err := s.save(sync.WithHostSections(shared, s.local)); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Configuration restored from revision %s\n", revision)
+	fmt.Fprintln(out, "Run 'vv :config sync push' to publish it, or 'vv :config sync undo' to go back")
+	return nil
+}
+
+func runConfigSyncUndo(cmd *cobra.Command) error {
+	configPath, err := config.GetConfigPath(cfgFile)
+	if err != nil {
+		return err
+	}
+
+	snapshot, err := sync.LatestSnapshot(configPath)
+	if err != nil {
+		return err
+	}
+	if snapshot == nil {
+		return fmt.Errorf("nothing to undo. A snapshot is taken before each pull and checkout")
+	}
+
+	historyPath, err := history.GetHistoryPath()
+	if err != nil {
+		return err
+	}
+	files := &sync.LocalFiles{Root: filepath.Dir(configPath), HistoryPath: historyPath}
+	if err := snapshot.Restore(configPath, files); err != nil {
 		return err
 	}
 
-	fmt.Fprintln(cmd.OutOrStdout(), "Configuration pulled from Gist")
+	fmt.Fprintf(cmd.OutOrStdout(), "Configuration restored from before the %s at %s\n", snapshot.Reason, snapshot.TakenAt.Local().Format("2006-01-02 15:04:05"))
 	return nil
 }
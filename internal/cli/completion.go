@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/SuzumiyaAoba/via/internal/config"
 	"github.com/samber/lo"
 	"github.com/spf13/cobra"
 )
@@ -85,10 +86,10 @@ func CompletionProfiles(cmd *cobra.Command, args []string, toComplete string) ([
 	}
 
 	profiles := lo.FilterMap(entries, func(entry os.DirEntry, _ int) (string, bool) {
-		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".yml") {
+		profileName, ok := config.TrimConfigExt(entry.Name())
+		if entry.IsDir() || !ok {
 			return "", false
 		}
-		profileName := strings.TrimSuffix(entry.Name(), ".yml")
 		if !strings.HasPrefix(profileName, toComplete) {
 			return "", false
 		}
@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/SuzumiyaAoba/via/internal/config"
+	"github.com/SuzumiyaAoba/via/internal/logger"
+)
+
+// configCache keeps resolved configs loaded for long running commands, and
+// loads a config again when a file it was resolved from, or a directory one
+// of its include globs is matched in, changes
+type configCache struct {
+	mu      sync.Mutex
+	entries map[string]*cachedConfig // By absolute config path
+}
+
+// cachedConfig is a resolved config and the modification times of the paths it was resolved from
+type cachedConfig struct {
+	cfg   *config.Config
+	files map[string]time.Time
+}
+
+func newConfigCache() *configCache {
+	return &configCache{entries: make(map[string]*cachedConfig)}
+}
+
+// load returns the resolved config at path, which must be absolute, expanded in
+// the current environment: the daemon takes on the environment of each caller.
+// The config is shared, callers must not modify it.
+func (c *configCache) load(path string) (*config.Config, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cached, ok := c.entries[path]; ok && !cached.changed() {
+		return config.Expand(cached.cfg), nil
+	}
+	delete(c.entries, path)
+
+	cfg, sources, err := config.LoadResolvedConfigSources(path)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]time.Time, len(sources))
+	for _, source := range sources {
+		files[source] = modTime(source)
+	}
+	c.entries[path] = &cachedConfig{cfg: cfg, files: files}
+	logger.Info("Loaded config %s", path)
+	return cfg, nil
+}
+
+func (c *cachedConfig) changed() bool {
+	for file, loaded := range c.files {
+		if !modTime(file).Equal(loaded) {
+			return true
+		}
+	}
+	return false
+}
+
+// modTime returns when a path was last modified, or the zero time if it does not exist
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 
 	"github.com/SuzumiyaAoba/via/internal/config"
+	"github.com/SuzumiyaAoba/via/internal/history"
 	"github.com/SuzumiyaAoba/via/internal/sync"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -62,6 +63,161 @@ rules:
 		})
 	})
 
+	Describe("runConfigShow", func() {
+		BeforeEach(func() {
+			base := filepath.Join(tmpDir, "base.yml")
+			Expect(os.WriteFile(base, []byte(`version: "1"
+default_command: vim {{.File}}
+rules:
+  - name: PDF Reader
+    extensions: [pdf]
+    command: open {{.File}}
+`), 0644)).To(Succeed())
+			Expect(os.WriteFile(configFile, []byte(`version: "1"
+extends: [base.yml]
+rules:
+  - name: Markdown
+    extensions: [md]
+    command: glow {{.File}}
+`), 0644)).To(Succeed())
+			cfgFile = configFile
+		})
+
+		It("should show the config file as written", func() {
+			Expect(runConfigShow(rootCmd, false)).To(Succeed())
+			Expect(outBuf.String()).To(ContainSubstring("- base.yml"))
+			Expect(outBuf.String()).NotTo(ContainSubstring("PDF Reader"))
+		})
+
+		It("should show the effective config", func() {
+			Expect(runConfigShow(rootCmd, true)).To(Succeed())
+			Expect(outBuf.String()).NotTo(ContainSubstring("extends"))
+			Expect(outBuf.String()).To(ContainSubstring("default_command: vim {{.File}}"))
+			Expect(outBuf.String()).To(MatchRegexp(`(?s)name: Markdown.*name: PDF Reader`))
+		})
+
+		It("should match inherited rules", func() {
+			rootCmd.SetArgs([]string{"--config", configFile, ":match", "doc.pdf"})
+			Expect(rootCmd.Execute()).To(Succeed())
+			Expect(outBuf.String()).To(ContainSubstring("PDF Reader"))
+		})
+	})
+
+	Describe("runConfigMigrate", func() {
+		legacy := "version: \"1\"\n# Fallback\ndefault: vim {{.File}}\nrules: []\n"
+
+		BeforeEach(func() {
+			Expect(os.WriteFile(configFile, []byte(legacy), 0644)).To(Succeed())
+			cfgFile = configFile
+		})
+
+		It("should only show the changes on a dry run", func() {
+			rootCmd.SetArgs([]string{"--config", configFile, ":config", "migrate", "--dry-run"})
+			Expect(rootCmd.Execute()).To(Succeed())
+			Expect(outBuf.String()).To(ContainSubstring("renamed default to default_command"))
+			Expect(outBuf.String()).To(ContainSubstring("Dry run: nothing was written"))
+
+			data, err := os.ReadFile(configFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(Equal(legacy))
+		})
+
+		It("should write the migrated config after a backup", func() {
+			Expect(runConfigMigrate(rootCmd)).To(Succeed())
+			Expect(outBuf.String()).To(ContainSubstring("Configuration migrated to version " + config.CurrentVersion))
+
+			data, err := os.ReadFile(configFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(ContainSubstring("# Fallback\ndefault_command: vim {{.File}}"))
+			Expect(filepath.Join(tmpDir, "backups", "config.v1.yml")).To(BeAnExistingFile())
+
+			outBuf.Reset()
+			Expect(runConfigMigrate(rootCmd)).To(Succeed())
+			Expect(outBuf.String()).To(ContainSubstring("already at version"))
+		})
+
+		It("should pick up a config from the old location", func() {
+			home := GinkgoT().TempDir()
+			origHome := config.UserHomeDir
+			origHistoryHome := history.UserHomeDir
+			config.UserHomeDir = func() (string, error) { return home, nil }
+			history.UserHomeDir = config.UserHomeDir
+			DeferCleanup(func() { config.UserHomeDir, history.UserHomeDir = origHome, origHistoryHome })
+
+			oldPath := filepath.Join(home, ".config", "entry", "config.yml")
+			Expect(os.MkdirAll(filepath.Dir(oldPath), 0755)).To(Succeed())
+			Expect(os.WriteFile(oldPath, []byte(legacy), 0644)).To(Succeed())
+			oldHistory := filepath.Join(home, ".config", "entry", "history.json")
+			Expect(os.WriteFile(oldHistory, []byte("[]"), 0644)).To(Succeed())
+
+			cfgFile = ""
+			Expect(runConfigMigrate(rootCmd)).To(Succeed())
+			Expect(outBuf.String()).To(ContainSubstring("copy " + oldPath))
+			Expect(outBuf.String()).To(ContainSubstring("move " + oldHistory))
+			Expect(oldHistory).NotTo(BeAnExistingFile())
+			Expect(filepath.Join(home, ".config", "via", "history.json")).To(BeAnExistingFile())
+
+			cfg, err := config.LoadConfig(filepath.Join(home, ".config", "via", "config.yml"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.DefaultCommand).To(Equal("vim {{.File}}"))
+		})
+	})
+
+	Describe("runConfigConvert", func() {
+		BeforeEach(func() {
+			Expect(os.WriteFile(configFile, []byte("version: \"2\"\n# Editor\ndefault_command: vim {{.File}}\nrules:\n  - name: PDF\n    extensions: [pdf]\n    command: zathura {{.File}}\n"), 0644)).To(Succeed())
+			cfgFile = configFile
+		})
+
+		It("should replace the config with the converted file", func() {
+			rootCmd.SetArgs([]string{"--config", configFile, ":config", "convert", "--to", "toml"})
+			Expect(rootCmd.Execute()).To(Succeed())
+
+			tomlFile := filepath.Join(tmpDir, "config.toml")
+			Expect(outBuf.String()).To(ContainSubstring("Configuration converted to toml: " + tomlFile))
+			Expect(configFile).NotTo(BeAnExistingFile())
+
+			cfg, err := config.LoadConfig(tomlFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.DefaultCommand).To(Equal("vim {{.File}}"))
+			Expect(cfg.Rules[0].Extensions).To(Equal([]string{"pdf"}))
+
+			backups, err := config.ListBackups(tomlFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(backups).To(HaveLen(1))
+			Expect(backups[0].Path).To(HaveSuffix(".yml"))
+		})
+
+		It("should keep the original with --output", func() {
+			jsonFile := filepath.Join(tmpDir, "export.json")
+			Expect(runConfigConvert(rootCmd, "json", jsonFile)).To(Succeed())
+			Expect(configFile).To(BeAnExistingFile())
+
+			data, err := os.ReadFile(jsonFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(ContainSubstring(`"default_command": "vim {{.File}}"`))
+		})
+
+		It("should reject unknown formats and existing targets", func() {
+			Expect(runConfigConvert(rootCmd, "ini", "")).To(MatchError(ContainSubstring(`unknown config format "ini"`)))
+			Expect(os.WriteFile(filepath.Join(tmpDir, "config.toml"), nil, 0644)).To(Succeed())
+			Expect(runConfigConvert(rootCmd, "toml", "")).To(MatchError(ContainSubstring("already exists")))
+		})
+
+		It("should do nothing when the format does not change", func() {
+			Expect(runConfigConvert(rootCmd, "yaml", "")).To(Succeed())
+			Expect(outBuf.String()).To(ContainSubstring("already in yaml format"))
+		})
+
+		It("should convert on export by extension", func() {
+			dest := filepath.Join(tmpDir, "export.toml")
+			Expect(runConfigExport(rootCmd, dest)).To(Succeed())
+			data, err := os.ReadFile(dest)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(ContainSubstring("[[rules]]"))
+		})
+	})
+
 	Describe("runConfigOpen", func() {
 		BeforeEach(func() {
 			cfgFile = configFile
@@ -372,6 +528,43 @@ rules:
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("validation failed"))
 		})
+
+		It("should report all problems with rule positions", func() {
+			cfgFile = configFile
+			Expect(os.WriteFile(cfgFile, []byte(`version: "2"
+rules:
+    - name: Text
+      extensions: [txt]
+      command: cat {{.File}}
+    - name: Notes
+      extensions: [txt]
+      command: less {{.File}}
+    - name: Broken
+      regex: "["
+`), 0644)).To(Succeed())
+
+			err := runConfigCheck(rootCmd)
+			Expect(err).To(MatchError("validation failed: 2 errors, 1 warning"))
+			output := outBuf.String()
+			Expect(output).To(ContainSubstring(cfgFile + `:6: rule "Notes": warning: is never reached, rule "Text" (` + cfgFile + `:3) matches everything it matches first (shadowed)`))
+			Expect(output).To(ContainSubstring(cfgFile + `:9: rule "Broken": error: command is required (schema)`))
+			Expect(output).To(ContainSubstring(cfgFile + `:9: rule "Broken": error: regex is not a valid regular expression`))
+		})
+
+		It("should pass with warnings only", func() {
+			cfgFile = configFile
+			cfg := &config.Config{
+				Version: "2",
+				Rules: []config.Rule{
+					{Name: "Mac", Extensions: []string{"pdf"}, OS: []string{"osx"}, Command: "open {{.File}}"},
+				},
+			}
+			Expect(config.SaveConfig(cfgFile, cfg)).To(Succeed())
+
+			Expect(runConfigCheck(rootCmd)).To(Succeed())
+			Expect(outBuf.String()).To(ContainSubstring(`unknown OS "osx", use "darwin" (unknown-os)`))
+			Expect(outBuf.String()).To(ContainSubstring("Configuration is valid, 1 warning"))
+		})
 	})
 
 	Describe("runConfigExport/Import", func() {
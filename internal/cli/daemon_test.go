@@ -0,0 +1,211 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/SuzumiyaAoba/via/internal/daemon"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Daemon", func() {
+	var (
+		tmpDir     string
+		configFile string
+		socket     string
+		outBuf     bytes.Buffer
+	)
+
+	writeConfig := func(content string) {
+		Expect(os.WriteFile(configFile, []byte(content), 0644)).To(Succeed())
+	}
+
+	// served returns how many requests the daemon has answered
+	served := func() int {
+		var status daemon.Status
+		Expect(daemon.Call(socket, &daemon.Request{Op: daemon.OpPing}, &status)).To(Succeed())
+		return status.Served
+	}
+
+	BeforeEach(func() {
+		resetGlobals()
+		tmpDir = GinkgoT().TempDir()
+		configFile = filepath.Join(tmpDir, "config.yml")
+		writeConfig(`version: "2"
+rules:
+  - name: Text
+    extensions: [txt]
+    command: echo {{.File}}
+`)
+		outBuf.Reset()
+		rootCmd.SetOut(&outBuf)
+
+		// Socket paths are limited to about 100 bytes, so stay clear of long test directories
+		dir, err := os.MkdirTemp("", "via")
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(os.RemoveAll, dir)
+		socket = filepath.Join(dir, "daemon.sock")
+
+		saved := daemonSocketPath
+		daemonSocketPath = func() string { return socket }
+		DeferCleanup(func() { daemonSocketPath = saved })
+
+		ln, err := daemon.Listen(socket)
+		Expect(err).NotTo(HaveOccurred())
+		server := daemon.NewServer((&daemonHandler{configs: newConfigCache()}).handle)
+		done := make(chan error, 1)
+		go func() { done <- server.Serve(ln) }()
+		DeferCleanup(func() {
+			server.Close()
+			Eventually(done).Should(Receive(BeNil()))
+		})
+	})
+
+	It("should open files through the daemon", func() {
+		rootCmd.SetArgs([]string{"--config", configFile, "--dry-run", "notes.txt"})
+		Expect(rootCmd.Execute()).To(Succeed())
+		Expect(outBuf.String()).To(Equal("echo notes.txt\n"))
+		Expect(served()).To(Equal(2))
+	})
+
+	It("should run unmatched arguments as commands", func() {
+		rootCmd.SetArgs([]string{"--config", configFile, "--dry-run", "ls", "-l"})
+		Expect(rootCmd.Execute()).To(Succeed())
+		Expect(outBuf.String()).To(Equal("ls -l\n"))
+		Expect(served()).To(Equal(2))
+	})
+
+	It("should run the argument as a command when the rule's command fails", func() {
+		writeConfig(`version: "2"
+rules:
+  - name: Broken
+    regex: ^echo$
+    command: exit 1
+`)
+		rootCmd.SetArgs([]string{"--config", configFile, "echo"})
+		Expect(rootCmd.Execute()).To(Succeed())
+		Expect(outBuf.String()).To(Equal("\n"))
+		Expect(served()).To(Equal(2))
+	})
+
+	It("should reload the config when the file changes", func() {
+		rootCmd.SetArgs([]string{"--config", configFile, "--dry-run", "notes.txt"})
+		Expect(rootCmd.Execute()).To(Succeed())
+
+		writeConfig(`version: "2"
+rules:
+  - name: Text
+    extensions: [txt]
+    command: cat {{.File}}
+`)
+		later := time.Now().Add(time.Minute)
+		Expect(os.Chtimes(configFile, later, later)).To(Succeed())
+
+		outBuf.Reset()
+		resetGlobals()
+		rootCmd.SetArgs([]string{"--config", configFile, "--dry-run", "notes.txt"})
+		Expect(rootCmd.Execute()).To(Succeed())
+		Expect(outBuf.String()).To(Equal("cat notes.txt\n"))
+	})
+
+	It("should reload the config when an included file or include directory changes", func() {
+		touch := func(path string) {
+			later := time.Now().Add(time.Minute)
+			Expect(os.Chtimes(path, later, later)).To(Succeed())
+		}
+		confDir := filepath.Join(tmpDir, "conf.d")
+		Expect(os.Mkdir(confDir, 0755)).To(Succeed())
+		aliasFile := filepath.Join(confDir, "aliases.yml")
+		Expect(os.WriteFile(aliasFile, []byte("version: \"2\"\naliases: {t: Text}\n"), 0644)).To(Succeed())
+		writeConfig("version: \"2\"\ninclude: [conf.d/*.yml]\nrules: []\n")
+
+		cache := newConfigCache()
+		cfg, err := cache.load(configFile)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.Aliases).To(Equal(map[string]string{"t": "Text"}))
+		cached := cache.entries[configFile].cfg
+		_, err = cache.load(configFile)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cache.entries[configFile].cfg).To(BeIdenticalTo(cached))
+
+		Expect(os.WriteFile(aliasFile, []byte("version: \"2\"\naliases: {txt: Text}\n"), 0644)).To(Succeed())
+		touch(aliasFile)
+		cfg, err = cache.load(configFile)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.Aliases).To(Equal(map[string]string{"txt": "Text"}))
+
+		Expect(os.WriteFile(filepath.Join(confDir, "text.yml"), []byte(`version: "2"
+rules:
+  - name: Text
+    extensions: [txt]
+    command: cat {{.File}}
+`), 0644)).To(Succeed())
+		touch(confDir)
+		cfg, err = cache.load(configFile)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.Rules).To(HaveLen(1))
+	})
+
+	It("should expand cached configs in the environment of each caller", func() {
+		writeConfig("version: \"2\"\nrules:\n  - name: View\n    command: ${VIA_TEST_VIEWER} {{.File}}\n")
+		cache := newConfigCache()
+
+		GinkgoT().Setenv("VIA_TEST_VIEWER", "less")
+		cfg, err := cache.load(configFile)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.Rules[0].Command).To(Equal("less {{.File}}"))
+
+		GinkgoT().Setenv("VIA_TEST_VIEWER", "bat")
+		cfg, err = cache.load(configFile)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.Rules[0].Command).To(Equal("bat {{.File}}"))
+	})
+
+	It("should report config errors", func() {
+		rootCmd.SetArgs([]string{"--config", filepath.Join(tmpDir, "missing.yml"), "--dry-run", "notes.txt"})
+		Expect(rootCmd.Execute()).To(MatchError(ContainSubstring("error loading config")))
+	})
+
+	It("should explain through the daemon", func() {
+		rootCmd.SetArgs([]string{"--config", configFile, "--explain", "--json", "notes.txt"})
+		Expect(rootCmd.Execute()).To(Succeed())
+
+		var e explanation
+		Expect(json.Unmarshal(outBuf.Bytes(), &e)).To(Succeed())
+		Expect(e.Matched).To(BeTrue())
+		Expect(e.Action.Commands[0].Rendered).To(Equal("echo notes.txt"))
+		Expect(served()).To(Equal(2))
+	})
+
+	It("should run :match through the daemon", func() {
+		cfgFile = configFile
+		Expect(runMatch(rootCmd, "notes.txt", matchOptions{})).To(Succeed())
+		Expect(outBuf.String()).To(Equal("Text\n"))
+
+		outBuf.Reset()
+		Expect(runMatch(rootCmd, "notes.pdf", matchOptions{})).To(MatchError(errNoMatch))
+		Expect(served()).To(Equal(3))
+	})
+
+	It("should be bypassed with VIA_NO_DAEMON", func() {
+		GinkgoT().Setenv("VIA_NO_DAEMON", "1")
+
+		rootCmd.SetArgs([]string{"--config", configFile, "--dry-run", "notes.txt"})
+		Expect(rootCmd.Execute()).To(Succeed())
+		Expect(outBuf.String()).To(Equal("echo notes.txt\n"))
+		Expect(served()).To(Equal(1))
+	})
+
+	It("should show the status and stop", func() {
+		Expect(runDaemonStatus(rootCmd)).To(Succeed())
+		Expect(outBuf.String()).To(ContainSubstring("Daemon running on " + socket))
+
+		Expect(runDaemonStop(rootCmd)).To(Succeed())
+		Expect(outBuf.String()).To(ContainSubstring("Daemon stopped"))
+		Eventually(func() error { return runDaemonStatus(rootCmd) }).Should(MatchError(ContainSubstring("no daemon running")))
+	})
+})
@@ -1,21 +1,31 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
+	"os"
 	os_exec "os/exec"
 
 	"github.com/SuzumiyaAoba/via/internal/config"
+	"github.com/SuzumiyaAoba/via/internal/desktop"
 	"github.com/SuzumiyaAoba/via/internal/executor"
 	"github.com/SuzumiyaAoba/via/internal/logger"
 	"github.com/SuzumiyaAoba/via/internal/matcher"
 )
 
+// errHandlerLoop is returned instead of opening something with the system default
+// when vv was started as the default application, which the system default may be
+var errHandlerLoop = errors.New("vv was started as the default application, so it does not hand this back to the system default")
+
 // executeWithDefault executes the filename with either the default command or system default
 func executeWithDefault(cfg *config.Config, exec *executor.Executor, filename string) error {
 	if cfg.DefaultCommand != "" {
 		logger.Debug("Executing with default command: %s", cfg.DefaultCommand)
 		return exec.Execute(cfg.DefaultCommand, filename, executor.ExecutionOptions{})
 	}
+	if isTruthy(os.Getenv(desktop.HandlerEnv)) {
+		return fmt.Errorf("no rule or default_command handles %s: %w", filename, errHandlerLoop)
+	}
 	logger.Debug("Opening with system default")
 	return exec.OpenSystem(filename)
 }
@@ -54,6 +64,7 @@ func executeRule(exec *executor.Executor, rule *config.Rule, filename string) (b
 		Background: rule.Background,
 		Terminal:   rule.Terminal,
 		Env:        rule.Env,
+		NoHistory:  !rule.RecordsHistory(),
 	}
 	if err := exec.Execute(command, filename, opts); err != nil {
 		return true, err
@@ -90,6 +101,22 @@ func matchRules(cfg *config.Config, filename string) ([]*config.Rule, error) {
 	return matched, nil
 }
 
+// handleOpen runs a single file or URL through the rules, and anything else,
+// or a file that fails to open, as a command or alias
+func handleOpen(cfg *config.Config, exec *executor.Executor, args []string) error {
+	if len(args) == 1 {
+		// Normal file execution - if it fails, try as command
+		err := handleFileExecution(cfg, exec, args[0])
+		if err == nil || errors.Is(err, errHandlerLoop) {
+			return err
+		}
+	}
+
+	// Handle command execution with multiple arguments
+	// Or if file execution failed
+	return handleCommandExecution(cfg, exec, args)
+}
+
 func handleFileExecution(cfg *config.Config, exec *executor.Executor, filename string) error {
 	// Try to match rules
 	rules, err := matchRules(cfg, filename)
@@ -1,29 +1,173 @@
 package cli
 
 import (
+	"bytes"
 	"fmt"
+	"maps"
 	"net/url"
 	"os"
 	"path/filepath"
-	"regexp"
-	"runtime"
+	"slices"
 	"strings"
 
 	"github.com/SuzumiyaAoba/via/internal/config"
+	"github.com/SuzumiyaAoba/via/internal/executor"
+	"github.com/SuzumiyaAoba/via/internal/matcher"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/gabriel-vasile/mimetype"
 	"github.com/spf13/cobra"
 )
 
-type ruleResult struct {
-	num        string
-	name       string
-	conditions []string
-	result     string
-	matched    bool
+// explanation is what --explain reports about matching an input, rendered as
+// tables or, with --json, as JSON. It is built from the matcher's trace.
+type explanation struct {
+	Input   string        `json:"input"`
+	File    explainFile   `json:"file"`
+	Rules   []explainRule `json:"rules"` // The rules evaluated, up to the first match without fallthrough
+	Matched bool          `json:"matched"`
+	Error   string        `json:"error,omitempty"` // Why matching failed, as running the file would report it
+	Action  explainAction `json:"action"`
+}
+
+type explainFile struct {
+	Type      string `json:"type"` // "file" or "url"
+	Scheme    string `json:"scheme,omitempty"`
+	Extension string `json:"extension,omitempty"`
+	MIME      string `json:"mime,omitempty"`
+	Exists    bool   `json:"exists"` // Always false for URLs
+}
+
+type explainRule struct {
+	Index       int                `json:"index"` // 1-based, as in :config list
+	Name        string             `json:"name,omitempty"`
+	Source      string             `json:"source,omitempty"`
+	Conditions  []explainCondition `json:"conditions"`
+	Result      string             `json:"result"` // "match", "no-match", "skip" if the OS or scheme differs, or "error"
+	Fallthrough bool               `json:"fallthrough,omitempty"`
+}
+
+type explainCondition struct {
+	Type     string   `json:"type"`             // os, scheme, extension, regex, mime or script
+	Expected []string `json:"expected"`         // The values or patterns of the rule, or the script
+	Actual   string   `json:"actual,omitempty"` // The value of the input, or what the script returned
+	Matched  bool     `json:"matched"`
+	Error    string   `json:"error,omitempty"`
+}
+
+type explainAction struct {
+	Type     string           `json:"type"` // "rule", "default_command", "system" or "error"
+	Commands []explainCommand `json:"commands,omitempty"`
+}
+
+// explainCommand is a command as it runs, with the values as written in the config
+type explainCommand struct {
+	Rule       string            `json:"rule,omitempty"`
+	Command    string            `json:"command"`
+	RawCommand string            `json:"raw_command"`
+	Env        map[string]string `json:"env,omitempty"`
+	RawEnv     map[string]string `json:"raw_env,omitempty"`
+	Rendered   string            `json:"rendered,omitempty"` // The command line as --dry-run prints it
 }
 
 func handleExplain(cmd *cobra.Command, cfg *config.Config, filename string) error {
+	return printExplanation(cmd, explainMatch(cfg, filename))
+}
+
+// printExplanation prints e as JSON with --json, as text otherwise
+func printExplanation(cmd *cobra.Command, e explanation) error {
+	if jsonOutput {
+		return printJSON(cmd, e)
+	}
+	renderExplanation(cmd, e)
+	return nil
+}
+
+// explainMatch matches filename against the rules of cfg and describes the trace
+func explainMatch(cfg *config.Config, filename string) explanation {
+	e := explanation{Input: filename, Rules: []explainRule{}}
+
+	if isURL(filename) {
+		u, _ := url.Parse(filename)
+		e.File = explainFile{Type: "url", Scheme: u.Scheme, Extension: strings.TrimPrefix(filepath.Ext(u.Path), ".")}
+	} else {
+		e.File = explainFile{Type: "file", Extension: strings.TrimPrefix(filepath.Ext(filename), ".")}
+		if _, err := os.Stat(filename); err == nil {
+			e.File.Exists = true
+			if mtype, err := mimetype.DetectFile(filename); err == nil {
+				e.File.MIME = mtype.String()
+			}
+		}
+	}
+
+	trace := matcher.TraceMatch(cfg.Rules, filename)
+	for _, t := range trace.Rules {
+		r := explainRule{Index: t.Index + 1, Name: t.Rule.Name, Result: t.Result, Fallthrough: t.Rule.Fallthrough}
+		if t.Rule.Source != nil {
+			r.Source = t.Rule.Source.String()
+		}
+		for _, c := range t.Conditions {
+			condition := explainCondition{Type: c.Type, Expected: c.Expected, Actual: c.Actual, Matched: c.Matched}
+			if c.Err != nil {
+				condition.Error = c.Err.Error()
+			}
+			r.Conditions = append(r.Conditions, condition)
+		}
+		e.Rules = append(e.Rules, r)
+	}
+	e.Matched = len(trace.Matches) > 0
+
+	// Commands are reported as they run, along with the values as written
+	raw := cfg
+	if cfg.Raw != nil {
+		raw = cfg.Raw
+	}
+	switch {
+	case trace.Err != nil:
+		e.Error = trace.Err.Error()
+		e.Action.Type = "error"
+	case e.Matched:
+		e.Action.Type = "rule"
+		for _, t := range trace.Rules {
+			if t.Result != matcher.ResultMatch {
+				continue
+			}
+			rawRule := raw.Rules[t.Index]
+			e.Action.Commands = append(e.Action.Commands, explainCommand{
+				Rule:       t.Rule.Name,
+				Command:    t.Rule.Command,
+				RawCommand: rawRule.Command,
+				Env:        t.Rule.Env,
+				RawEnv:     rawRule.Env,
+				Rendered: renderDryRun(func(exec *executor.Executor) error {
+					_, err := executeRule(exec, t.Rule, filename)
+					return err
+				}),
+			})
+		}
+	case cfg.DefaultCommand != "":
+		e.Action = explainAction{Type: "default_command", Commands: []explainCommand{{
+			Command:    cfg.DefaultCommand,
+			RawCommand: raw.DefaultCommand,
+			Rendered: renderDryRun(func(exec *executor.Executor) error {
+				return executeWithDefault(cfg, exec, filename)
+			}),
+		}}}
+	default:
+		e.Action.Type = "system"
+	}
+	return e
+}
+
+// renderDryRun returns what run prints with a dry-run executor, "" if it fails
+func renderDryRun(run func(exec *executor.Executor) error) string {
+	var buf bytes.Buffer
+	if err := run(executor.NewExecutor(&buf, true)); err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func renderExplanation(cmd *cobra.Command, e explanation) {
 	// Define styles
 	titleStyle := lipgloss.NewStyle().
 		Bold(true).
@@ -60,40 +204,28 @@ func handleExplain(cmd *cobra.Command, cfg *config.Config, filename string) erro
 	fmt.Fprintln(cmd.OutOrStdout(), titleStyle.Render("═══ EXPLAIN MODE ═══"))
 	fmt.Fprintln(cmd.OutOrStdout(), "")
 	
-	// Check if file/URL exists
-	isURLType := isURL(filename)
-	
 	// File Information Section
 	fmt.Fprintln(cmd.OutOrStdout(), sectionTitleStyle.Render("FILE INFORMATION"))
 	fmt.Fprintln(cmd.OutOrStdout(), "")
 	
-	fmt.Fprintln(cmd.OutOrStdout(), labelStyle.Render("  Path:")+" "+valueStyle.Render(filename))
+	fmt.Fprintln(cmd.OutOrStdout(), labelStyle.Render("  Path:")+" "+valueStyle.Render(e.Input))
 	
-	if isURLType {
+	if e.File.Type == "url" {
 		fmt.Fprintln(cmd.OutOrStdout(), labelStyle.Render("  Type:")+" "+valueStyle.Render("URL"))
-		u, _ := url.Parse(filename)
-		fmt.Fprintln(cmd.OutOrStdout(), labelStyle.Render("  Scheme:")+" "+valueStyle.Render(u.Scheme))
-		if u.Path != "" {
-			ext := filepath.Ext(u.Path)
-			if ext != "" {
-				fmt.Fprintln(cmd.OutOrStdout(), labelStyle.Render("  Extension:")+" "+valueStyle.Render(strings.TrimPrefix(ext, ".")))
-			}
+		fmt.Fprintln(cmd.OutOrStdout(), labelStyle.Render("  Scheme:")+" "+valueStyle.Render(e.File.Scheme))
+		if e.File.Extension != "" {
+			fmt.Fprintln(cmd.OutOrStdout(), labelStyle.Render("  Extension:")+" "+valueStyle.Render(e.File.Extension))
 		}
 	} else {
 		fmt.Fprintln(cmd.OutOrStdout(), labelStyle.Render("  Type:")+" "+valueStyle.Render("File"))
-		ext := filepath.Ext(filename)
-		if ext != "" {
-			fmt.Fprintln(cmd.OutOrStdout(), labelStyle.Render("  Extension:")+" "+valueStyle.Render(strings.TrimPrefix(ext, ".")))
+		if e.File.Extension != "" {
+			fmt.Fprintln(cmd.OutOrStdout(), labelStyle.Render("  Extension:")+" "+valueStyle.Render(e.File.Extension))
 		}
 		
-		// Check MIME type
-		if _, err := os.Stat(filename); err == nil {
-			mtype, err := mimetype.DetectFile(filename)
-			if err == nil {
-				fmt.Fprintln(cmd.OutOrStdout(), labelStyle.Render("  MIME Type:")+" "+valueStyle.Render(mtype.String()))
-			}
-		} else {
+		if !e.File.Exists {
 			fmt.Fprintln(cmd.OutOrStdout(), labelStyle.Render("  Status:")+" "+errorStyle.Render("Does not exist"))
+		} else if e.File.MIME != "" {
+			fmt.Fprintln(cmd.OutOrStdout(), labelStyle.Render("  MIME Type:")+" "+valueStyle.Render(e.File.MIME))
 		}
 	}
 	
@@ -102,139 +234,39 @@ func handleExplain(cmd *cobra.Command, cfg *config.Config, filename string) erro
 	fmt.Fprintln(cmd.OutOrStdout(), sectionTitleStyle.Render("RULE EVALUATION"))
 	fmt.Fprintln(cmd.OutOrStdout(), "")
 	
-	// Prepare table data
-	var results []ruleResult
-	matched := false
-	
-	for i, rule := range cfg.Rules {
-		result := ruleResult{
-			num:  fmt.Sprintf("%d", i+1),
-			name: rule.Name,
-		}
-		
-		if result.name == "" {
-			result.name = "-"
-		}
-		
-		// Check each condition
-		ruleMatched := false
-		var matchReasons []string
-		
-		// OS check
-		if len(rule.OS) > 0 {
-			osMatch := false
-			for _, osName := range rule.OS {
-				if strings.ToLower(osName) == runtime.GOOS {
-					osMatch = true
-					break
-				}
-			}
-			if osMatch {
-				result.conditions = append(result.conditions, "✓ OS: "+runtime.GOOS)
-				matchReasons = append(matchReasons, "OS")
-			} else {
-				result.conditions = append(result.conditions, "✗ OS mismatch")
-				result.result = errorStyle.Render("SKIP")
-				results = append(results, result)
-				continue
-			}
-		}
-		
-		// Scheme check
-		if rule.Scheme != "" {
-			u, _ := url.Parse(filename)
-			if isURLType && strings.EqualFold(u.Scheme, rule.Scheme) {
-				result.conditions = append(result.conditions, "✓ Scheme: "+rule.Scheme)
-				matchReasons = append(matchReasons, "Scheme")
-				ruleMatched = true
-			} else {
-				result.conditions = append(result.conditions, "✗ Scheme: "+rule.Scheme)
-				result.result = errorStyle.Render("SKIP")
-				results = append(results, result)
-				continue
-			}
-		}
-		
-		// Extension check
-		if !ruleMatched && len(rule.Extensions) > 0 {
-			var pathExt string
-			if isURLType {
-				u, _ := url.Parse(filename)
-				pathExt = filepath.Ext(u.Path)
-			} else {
-				pathExt = filepath.Ext(filename)
-			}
-			pathExt = strings.ToLower(strings.TrimPrefix(pathExt, "."))
-			
-			extMatched := false
-			for _, ruleExt := range rule.Extensions {
-				if strings.ToLower(ruleExt) == pathExt {
-					result.conditions = append(result.conditions, "✓ Ext: ."+pathExt)
-					matchReasons = append(matchReasons, "Extension")
-					ruleMatched = true
-					extMatched = true
-					break
-				}
-			}
-			if !extMatched {
-				result.conditions = append(result.conditions, skipStyle.Render("○ Ext: "+fmt.Sprintf("%v", rule.Extensions)))
-			}
-		}
-		
-		// Regex check
-		if !ruleMatched && rule.Regex != "" {
-			regexMatched, _ := regexp.MatchString(rule.Regex, filename)
-			if regexMatched {
-				result.conditions = append(result.conditions, "✓ Regex")
-				matchReasons = append(matchReasons, "Regex")
-				ruleMatched = true
-			} else {
-				result.conditions = append(result.conditions, skipStyle.Render("○ Regex: "+rule.Regex))
-			}
+	// Prepare table rows
+	rows := make([][]string, len(e.Rules))
+	for i, r := range e.Rules {
+		name := r.Name
+		if name == "" {
+			name = "-"
 		}
-		
-		// MIME check
-		if !ruleMatched && rule.Mime != "" && !isURLType {
-			if _, err := os.Stat(filename); err == nil {
-				mtype, err := mimetype.DetectFile(filename)
-				if err == nil {
-					mimeMatched, _ := regexp.MatchString(rule.Mime, mtype.String())
-					if mimeMatched {
-						result.conditions = append(result.conditions, "✓ MIME")
-						matchReasons = append(matchReasons, "MIME")
-						ruleMatched = true
-					} else {
-						result.conditions = append(result.conditions, skipStyle.Render("○ MIME: "+rule.Mime))
-					}
-				}
-			}
+
+		conditions := make([]string, len(r.Conditions))
+		for j, c := range r.Conditions {
+			conditions[j] = renderCondition(c, skipStyle, errorStyle)
 		}
-		
-		if ruleMatched {
-			result.result = matchStyle.Render("[MATCH]")
-			if rule.Fallthrough {
-				result.result += skipStyle.Render(" →")
-			}
-			result.matched = true
-			matched = true
-			results = append(results, result)
-			if !rule.Fallthrough {
-				break
+
+		var result string
+		switch r.Result {
+		case "match":
+			result = matchStyle.Render("[MATCH]")
+			if r.Fallthrough {
+				result += skipStyle.Render(" →")
 			}
-		} else {
-			result.result = skipStyle.Render("—")
-			results = append(results, result)
+		case "skip":
+			result = errorStyle.Render("SKIP")
+		case "error":
+			result = errorStyle.Render("ERROR")
+		default:
+			result = skipStyle.Render("—")
 		}
-	}
-	
-	// Prepare table rows
-	rows := make([][]string, len(results))
-	for i, r := range results {
+
 		rows[i] = []string{
-			r.num,
-			r.name,
-			strings.Join(r.conditions, "\n"),
-			r.result,
+			fmt.Sprintf("%d", r.Index),
+			name,
+			strings.Join(conditions, "\n"),
+			result,
 		}
 	}
 
@@ -247,20 +279,102 @@ func handleExplain(cmd *cobra.Command, cfg *config.Config, filename string) erro
 	fmt.Fprintln(cmd.OutOrStdout(), sectionTitleStyle.Render("RESULT"))
 	fmt.Fprintln(cmd.OutOrStdout(), "")
 	
-	if !matched {
+	// Values are shown as written, followed by their expansion when it differs
+	printValue := func(label, value, expanded string) {
+		fmt.Fprintln(cmd.OutOrStdout(), "  "+labelStyle.Render(label)+" "+valueStyle.Render(value))
+		if expanded != value {
+			fmt.Fprintln(cmd.OutOrStdout(), "  "+labelStyle.Render("Expanded:")+" "+valueStyle.Render(expanded))
+		}
+	}
+
+	switch e.Action.Type {
+	case "rule":
+		if len(e.Action.Commands) > 1 {
+			fmt.Fprintln(cmd.OutOrStdout(), "  "+matchStyle.Render(fmt.Sprintf("[OK] %d rules matched, run in this order through fallthrough", len(e.Action.Commands))))
+		} else {
+			fmt.Fprintln(cmd.OutOrStdout(), "  "+matchStyle.Render("[OK] Rule matched successfully"))
+		}
+		for _, c := range e.Action.Commands {
+			name := c.Rule
+			if name == "" {
+				name = "-"
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), "")
+			fmt.Fprintln(cmd.OutOrStdout(), "  "+labelStyle.Render("Rule:")+" "+valueStyle.Render(name))
+			printValue("Command:", c.RawCommand, c.Command)
+			for _, k := range slices.Sorted(maps.Keys(c.Env)) {
+				printValue("Env:", k+"="+c.RawEnv[k], k+"="+c.Env[k])
+			}
+			if c.Rendered != "" {
+				fmt.Fprintln(cmd.OutOrStdout(), "  "+labelStyle.Render("Runs:")+" "+valueStyle.Render(c.Rendered))
+			}
+		}
+	case "default_command":
 		fmt.Fprintln(cmd.OutOrStdout(), "  "+skipStyle.Render("No rules matched."))
 		fmt.Fprintln(cmd.OutOrStdout(), "")
-		if cfg.DefaultCommand != "" {
-			fmt.Fprintln(cmd.OutOrStdout(), "  "+labelStyle.Render("Action:")+" "+valueStyle.Render("Execute default command"))
-			fmt.Fprintln(cmd.OutOrStdout(), "  "+labelStyle.Render("Command:")+" "+valueStyle.Render(cfg.DefaultCommand))
-		} else {
-			fmt.Fprintln(cmd.OutOrStdout(), "  "+labelStyle.Render("Action:")+" "+valueStyle.Render("Use system default application"))
+		fmt.Fprintln(cmd.OutOrStdout(), "  "+labelStyle.Render("Action:")+" "+valueStyle.Render("Execute default command"))
+		printValue("Command:", e.Action.Commands[0].RawCommand, e.Action.Commands[0].Command)
+		if rendered := e.Action.Commands[0].Rendered; rendered != "" {
+			fmt.Fprintln(cmd.OutOrStdout(), "  "+labelStyle.Render("Runs:")+" "+valueStyle.Render(rendered))
 		}
-	} else {
-		fmt.Fprintln(cmd.OutOrStdout(), "  "+matchStyle.Render("[OK] Rule matched successfully"))
+	case "error":
+		fmt.Fprintln(cmd.OutOrStdout(), "  "+errorStyle.Render("Matching failed: "+e.Error))
+	default:
+		fmt.Fprintln(cmd.OutOrStdout(), "  "+skipStyle.Render("No rules matched."))
+		fmt.Fprintln(cmd.OutOrStdout(), "")
+		fmt.Fprintln(cmd.OutOrStdout(), "  "+labelStyle.Render("Action:")+" "+valueStyle.Render("Use system default application"))
 	}
 	
 	fmt.Fprintln(cmd.OutOrStdout(), "")
-	
-	return nil
+}
+
+// renderCondition describes a condition for the table: ✓ matched, ✗ failed and
+// skipped the rule or stopped matching, ○ failed but other conditions may still match
+func renderCondition(c explainCondition, skipStyle, errorStyle lipgloss.Style) string {
+	if c.Error != "" {
+		return errorStyle.Render(fmt.Sprintf("✗ %s error: %s", conditionLabel(c.Type), c.Error))
+	}
+	switch c.Type {
+	case "os":
+		if c.Matched {
+			return "✓ OS: " + c.Actual
+		}
+		return "✗ OS mismatch"
+	case "scheme":
+		if c.Matched {
+			return "✓ Scheme: " + c.Expected[0]
+		}
+		return "✗ Scheme: " + c.Expected[0]
+	case "extension":
+		if c.Matched {
+			return "✓ Ext: ." + c.Actual
+		}
+		return skipStyle.Render("○ Ext: " + fmt.Sprintf("%v", c.Expected))
+	case "regex":
+		if c.Matched {
+			return "✓ Regex"
+		}
+		return skipStyle.Render("○ Regex: " + c.Expected[0])
+	case "mime":
+		if c.Matched {
+			return "✓ MIME"
+		}
+		return skipStyle.Render("○ MIME: " + c.Expected[0])
+	case "script":
+		if c.Matched {
+			return "✓ Script returned " + c.Actual
+		}
+		return skipStyle.Render("○ Script returned " + c.Actual)
+	}
+	return c.Type
+}
+
+func conditionLabel(kind string) string {
+	switch kind {
+	case "os", "mime":
+		return strings.ToUpper(kind)
+	case "extension":
+		return "Ext"
+	}
+	return strings.ToUpper(kind[:1]) + kind[1:]
 }
@@ -2,6 +2,7 @@ package cli
 
 import (
 	"bytes"
+	"encoding/json"
 	"os"
 	"path/filepath"
 
@@ -95,6 +96,102 @@ var _ = Describe("Explain mode", func() {
 			Expect(output).To(ContainSubstring("default command"))
 		})
 
+		It("should show raw and expanded commands", func() {
+			GinkgoT().Setenv("VIA_TEST_VIEWER", "zathura")
+			cfg.Rules = []config.Rule{{
+				Name:    "PDF",
+				Extensions: []string{"pdf"},
+				Command: "${VIA_TEST_VIEWER} {{.File}}",
+				Env:     map[string]string{"PAGER": "${VIA_TEST_PAGER:-less}"},
+			}}
+			testFile := filepath.Join(tmpDir, "test.pdf")
+			Expect(os.WriteFile(testFile, []byte("%PDF-1.4"), 0644)).To(Succeed())
+
+			Expect(handleExplain(rootCmd, config.Expand(cfg), testFile)).To(Succeed())
+
+			output := outBuf.String()
+			Expect(output).To(ContainSubstring("${VIA_TEST_VIEWER} {{.File}}"))
+			Expect(output).To(ContainSubstring("zathura {{.File}}"))
+			Expect(output).To(ContainSubstring("PAGER=${VIA_TEST_PAGER:-less}"))
+			Expect(output).To(ContainSubstring("PAGER=less"))
+		})
+
+		It("should show script results and the fallthrough chain", func() {
+			cfg.Rules = []config.Rule{
+				{Name: "Large", Script: `file.length > 100`, Command: "less {{.File}}"},
+				{Name: "Log", Regex: ".*", Command: "echo opened", Fallthrough: true},
+				{Name: "Text", Extensions: []string{"txt"}, Command: "cat {{.File}}"},
+			}
+
+			Expect(handleExplain(rootCmd, cfg, "a.txt")).To(Succeed())
+
+			output := outBuf.String()
+			Expect(output).To(ContainSubstring("○ Script returned false"))
+			Expect(output).To(ContainSubstring("2 rules matched"))
+			Expect(output).To(MatchRegexp(`Runs:\s+echo opened`))
+			Expect(output).To(MatchRegexp(`Runs:\s+cat a.txt`))
+		})
+
+		It("should show script errors", func() {
+			cfg.Rules = []config.Rule{{Name: "Broken", Script: "syntax error(", Command: "cat"}}
+
+			Expect(handleExplain(rootCmd, cfg, "a.txt")).To(Succeed())
+
+			output := outBuf.String()
+			Expect(output).To(ContainSubstring("✗ Script error:"))
+			Expect(output).To(ContainSubstring("ERROR"))
+			Expect(output).To(ContainSubstring(`Matching failed: rule "Broken"`))
+		})
+
+		Describe("with --json", func() {
+			BeforeEach(func() {
+				jsonOutput = true
+				DeferCleanup(func() { jsonOutput = false })
+			})
+
+			It("should print the file, every condition and the action", func() {
+				testFile := filepath.Join(tmpDir, "notes.md")
+				Expect(os.WriteFile(testFile, []byte("# Notes"), 0644)).To(Succeed())
+
+				Expect(handleExplain(rootCmd, cfg, testFile)).To(Succeed())
+				Expect(outBuf.String()).NotTo(ContainSubstring("\x1b["))
+
+				var e explanation
+				Expect(json.Unmarshal(outBuf.Bytes(), &e)).To(Succeed())
+				Expect(e.Input).To(Equal(testFile))
+				Expect(e.File).To(Equal(explainFile{Type: "file", Extension: "md", MIME: "text/plain; charset=utf-8", Exists: true}))
+				Expect(e.Matched).To(BeTrue())
+				Expect(e.Rules).To(Equal([]explainRule{
+					{Index: 1, Name: "Text Editor", Result: "no-match", Conditions: []explainCondition{
+						{Type: "extension", Expected: []string{"txt"}, Actual: "md"},
+					}},
+					{Index: 2, Name: "Markdown Editor", Result: "match", Conditions: []explainCondition{
+						{Type: "extension", Expected: []string{"md"}, Actual: "md", Matched: true},
+					}},
+				}))
+				Expect(e.Action).To(Equal(explainAction{Type: "rule", Commands: []explainCommand{
+					{Rule: "Markdown Editor", Command: "vim {{.File}}", RawCommand: "vim {{.File}}", Rendered: "vim " + testFile},
+				}}))
+			})
+
+			It("should report skipped rules and the default command", func() {
+				cfg.DefaultCommand = "less {{.File}}"
+
+				Expect(handleExplain(rootCmd, cfg, "http://example.com/page")).To(Succeed())
+
+				var e explanation
+				Expect(json.Unmarshal(outBuf.Bytes(), &e)).To(Succeed())
+				Expect(e.File).To(Equal(explainFile{Type: "url", Scheme: "http"}))
+				Expect(e.Matched).To(BeFalse())
+				Expect(e.Rules[2]).To(Equal(explainRule{Index: 3, Name: "Web Browser", Result: "skip", Conditions: []explainCondition{
+					{Type: "scheme", Expected: []string{"https"}, Actual: "http"},
+				}}))
+				Expect(e.Action).To(Equal(explainAction{Type: "default_command", Commands: []explainCommand{
+					{Command: "less {{.File}}", RawCommand: "less {{.File}}", Rendered: "less http://example.com/page"},
+				}}))
+			})
+		})
+
 		It("should explain for non-existing file", func() {
 			err := handleExplain(rootCmd, cfg, "nonexistent.txt")
 			Expect(err).NotTo(HaveOccurred())
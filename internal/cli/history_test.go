@@ -31,6 +31,8 @@ var _ = Describe("History command", func() {
 
 	AfterEach(func() {
 		history.SetHistoryPath("")
+		history.SetDisabled(false)
+		history.SetExcludePatterns(nil)
 	})
 
 	It("should show empty message if no history", func() {
@@ -108,4 +110,84 @@ var _ = Describe("History command", func() {
 			}
 		})
 	})
+
+	Describe("Private mode", func() {
+		var (
+			cfgPath  string
+			testFile string
+		)
+
+		BeforeEach(func() {
+			cfgPath = filepath.Join(tmpDir, "config.yml")
+			testFile = filepath.Join(tmpDir, "test.txt")
+			err := os.WriteFile(testFile, []byte("content"), 0644)
+			Expect(err).NotTo(HaveOccurred())
+
+			content := `
+version: "1"
+history:
+  exclude: ["*.secret"]
+rules:
+  - name: Text
+    extensions: [txt]
+    command: "true {{.File}}"
+  - name: Private
+    extensions: [priv]
+    command: "true {{.File}}"
+    history: false
+  - name: Secret
+    extensions: [secret]
+    command: "true {{.File}}"
+`
+			err = os.WriteFile(cfgPath, []byte(content), 0644)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		loadEntries := func() []history.HistoryEntry {
+			entries, err := history.LoadHistory()
+			Expect(err).NotTo(HaveOccurred())
+			return entries
+		}
+
+		It("should record history by default", func() {
+			err := rootCmd.RunE(rootCmd, []string{"--config", cfgPath, testFile})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loadEntries()).To(HaveLen(1))
+		})
+
+		It("should not record history with --no-history", func() {
+			err := rootCmd.RunE(rootCmd, []string{"--config", cfgPath, "--no-history", testFile})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loadEntries()).To(BeEmpty())
+		})
+
+		It("should not record history when VIA_NO_HISTORY is set", func() {
+			os.Setenv("VIA_NO_HISTORY", "1")
+			defer os.Unsetenv("VIA_NO_HISTORY")
+
+			err := rootCmd.RunE(rootCmd, []string{"--config", cfgPath, testFile})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loadEntries()).To(BeEmpty())
+		})
+
+		It("should not record history for rules with history disabled", func() {
+			privFile := filepath.Join(tmpDir, "doc.priv")
+			err := os.WriteFile(privFile, []byte("content"), 0644)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = rootCmd.RunE(rootCmd, []string{"--config", cfgPath, privFile})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loadEntries()).To(BeEmpty())
+		})
+
+		It("should not record history for excluded paths", func() {
+			secretFile := filepath.Join(tmpDir, "key.secret")
+			err := os.WriteFile(secretFile, []byte("content"), 0644)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = rootCmd.RunE(rootCmd, []string{"--config", cfgPath, secretFile})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loadEntries()).To(BeEmpty())
+		})
+	})
 })
@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("InstallHandler", func() {
+	var (
+		outBuf       bytes.Buffer
+		entryPath    string
+		mimeAppsPath string
+		updated      []string
+	)
+
+	readFile := func(path string) string {
+		data, err := os.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		return string(data)
+	}
+
+	BeforeEach(func() {
+		resetGlobals()
+		outBuf.Reset()
+		rootCmd.SetOut(&outBuf)
+
+		dataHome := GinkgoT().TempDir()
+		configHome := GinkgoT().TempDir()
+		GinkgoT().Setenv("XDG_DATA_HOME", dataHome)
+		GinkgoT().Setenv("XDG_CONFIG_HOME", configHome)
+		entryPath = filepath.Join(dataHome, "applications", "via.desktop")
+		mimeAppsPath = filepath.Join(configHome, "mimeapps.list")
+
+		savedExecutable, savedUpdate := handlerExecutable, updateDesktopDatabase
+		handlerExecutable = func() (string, error) { return "/usr/local/bin/vv", nil }
+		updated = nil
+		updateDesktopDatabase = func(dir string) error {
+			updated = append(updated, dir)
+			return nil
+		}
+		DeferCleanup(func() { handlerExecutable, updateDesktopDatabase = savedExecutable, savedUpdate })
+	})
+
+	It("should register http and https by default", func() {
+		Expect(runInstallHandler(rootCmd, nil, false)).To(Succeed())
+		Expect(outBuf.String()).To(ContainSubstring("vv is now the default application for http, https"))
+
+		entry := readFile(entryPath)
+		Expect(entry).To(ContainSubstring("Exec=env VIA_HANDLER=1 /usr/local/bin/vv %u\n"))
+		Expect(entry).To(ContainSubstring("MimeType=x-scheme-handler/http;x-scheme-handler/https;\n"))
+		Expect(readFile(mimeAppsPath)).To(ContainSubstring("[Default Applications]\nx-scheme-handler/http=via.desktop;\nx-scheme-handler/https=via.desktop;\n"))
+		Expect(updated).To(Equal([]string{filepath.Dir(entryPath)}))
+
+		outBuf.Reset()
+		Expect(runInstallHandler(rootCmd, []string{"https"}, false)).To(Succeed())
+		Expect(outBuf.String()).To(Equal("vv is already the default application for https\n"))
+	})
+
+	It("should add types to an existing registration", func() {
+		Expect(os.WriteFile(mimeAppsPath, []byte("[Default Applications]\napplication/pdf=evince.desktop;\n"), 0644)).To(Succeed())
+		Expect(runInstallHandler(rootCmd, []string{"https"}, false)).To(Succeed())
+		Expect(runInstallHandler(rootCmd, []string{"application/pdf"}, false)).To(Succeed())
+
+		Expect(readFile(entryPath)).To(ContainSubstring("MimeType=x-scheme-handler/https;application/pdf;\n"))
+		Expect(readFile(mimeAppsPath)).To(ContainSubstring("application/pdf=via.desktop;evince.desktop;\n"))
+	})
+
+	It("should only show the changes on a dry run", func() {
+		rootCmd.SetArgs([]string{":install-handler", "--dry-run", "mailto"})
+		Expect(rootCmd.Execute()).To(Succeed())
+		Expect(outBuf.String()).To(ContainSubstring(`+ file "` + entryPath + `"`))
+		Expect(outBuf.String()).To(ContainSubstring("+ MimeType=x-scheme-handler/mailto;"))
+		Expect(outBuf.String()).To(ContainSubstring(`+ file "` + mimeAppsPath + `"`))
+		Expect(outBuf.String()).To(ContainSubstring("Dry run: nothing was written"))
+
+		Expect(entryPath).NotTo(BeAnExistingFile())
+		Expect(mimeAppsPath).NotTo(BeAnExistingFile())
+		Expect(updated).To(BeEmpty())
+	})
+
+	It("should uninstall some or all types", func() {
+		Expect(runInstallHandler(rootCmd, []string{"http", "https", "text/plain"}, false)).To(Succeed())
+
+		Expect(runInstallHandler(rootCmd, []string{"text/plain"}, true)).To(Succeed())
+		Expect(readFile(entryPath)).To(ContainSubstring("MimeType=x-scheme-handler/http;x-scheme-handler/https;\n"))
+		Expect(readFile(mimeAppsPath)).NotTo(ContainSubstring("text/plain"))
+
+		outBuf.Reset()
+		Expect(runInstallHandler(rootCmd, nil, true)).To(Succeed())
+		Expect(outBuf.String()).To(ContainSubstring("vv is no longer a default application"))
+		Expect(entryPath).NotTo(BeAnExistingFile())
+		Expect(readFile(mimeAppsPath)).NotTo(ContainSubstring("via.desktop"))
+
+		outBuf.Reset()
+		Expect(runInstallHandler(rootCmd, nil, true)).To(Succeed())
+		Expect(outBuf.String()).To(Equal("vv is not registered as a default application\n"))
+	})
+
+	It("should not hand unhandled links back to xdg-open when run as the handler", func() {
+		configFile := filepath.Join(GinkgoT().TempDir(), "config.yml")
+		Expect(os.WriteFile(configFile, []byte("version: \"2\"\nrules: []\n"), 0644)).To(Succeed())
+		GinkgoT().Setenv("VIA_NO_DAEMON", "1")
+		GinkgoT().Setenv("VIA_HANDLER", "1")
+
+		rootCmd.SetArgs([]string{"--config", configFile, "--dry-run", "https://example.com"})
+		Expect(rootCmd.Execute()).To(MatchError(ContainSubstring("no rule or default_command handles https://example.com")))
+		Expect(outBuf.String()).NotTo(ContainSubstring("xdg-open"))
+	})
+
+	It("should reject invalid types", func() {
+		Expect(runInstallHandler(rootCmd, []string{"not a scheme"}, false)).To(MatchError(ContainSubstring("invalid URL scheme")))
+	})
+})
@@ -2,6 +2,8 @@ package cli
 
 import (
 	"bytes"
+	"encoding/json"
+	"strings"
 	"path/filepath"
 
 	"github.com/SuzumiyaAoba/via/internal/config"
@@ -44,27 +46,120 @@ var _ = Describe("Match command", func() {
 		})
 
 		It("should print matched rule name", func() {
-			err := runMatch(rootCmd, "file.txt")
+			err := runMatch(rootCmd, "file.txt", matchOptions{})
 			Expect(err).NotTo(HaveOccurred())
 			Expect(outBuf.String()).To(ContainSubstring("Text Rule"))
 		})
 
 		It("should print matched rule command if name missing", func() {
-			err := runMatch(rootCmd, "file.log")
+			err := runMatch(rootCmd, "file.log", matchOptions{})
 			Expect(err).NotTo(HaveOccurred())
 			Expect(outBuf.String()).To(ContainSubstring("echo"))
 		})
 
 		It("should return error if no match found", func() {
-			err := runMatch(rootCmd, "file.pdf")
+			err := runMatch(rootCmd, "file.pdf", matchOptions{})
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("no match found"))
 		})
 
+		It("should print the evaluation as JSON", func() {
+			err := runMatch(rootCmd, "file.log", matchOptions{JSON: true})
+			Expect(err).NotTo(HaveOccurred())
+
+			var e explanation
+			Expect(json.Unmarshal(outBuf.Bytes(), &e)).To(Succeed())
+			Expect(e.Matched).To(BeTrue())
+			Expect(e.Rules).To(HaveLen(2))
+			Expect(e.Rules[0].Source).To(Equal(configFile + ":3"))
+			Expect(e.Action.Type).To(Equal("rule"))
+			Expect(e.Action.Commands).To(Equal([]explainCommand{{Command: "echo", RawCommand: "echo", Rendered: "echo"}}))
+		})
+
+		It("should print JSON and fail if no match found", func() {
+			err := runMatch(rootCmd, "file.pdf", matchOptions{JSON: true})
+			Expect(err).To(MatchError("no match found"))
+
+			var e explanation
+			Expect(json.Unmarshal(outBuf.Bytes(), &e)).To(Succeed())
+			Expect(e.Matched).To(BeFalse())
+			Expect(e.Action.Type).To(Equal("system"))
+		})
+
+		It("should reject --json with --all or --command", func() {
+			err := runMatch(rootCmd, "file.txt", matchOptions{JSON: true, All: true})
+			Expect(err).To(MatchError(ContainSubstring("cannot be combined")))
+		})
+
 		It("should return error if config load fails", func() {
 			cfgFile = filepath.Join(tmpDir, "nonexistent.yml")
-			err := runMatch(rootCmd, "file.txt")
+			err := runMatch(rootCmd, "file.txt", matchOptions{})
 			Expect(err).To(HaveOccurred())
 		})
 	})
+	Describe("runMatch options", func() {
+		BeforeEach(func() {
+			cfg := config.Config{
+				Version:        "1",
+				DefaultCommand: "less {{.File}}",
+				Rules: []config.Rule{
+					{Name: "Go Test", Regex: `_test\.go$`, Command: "go test {{.File}}", Fallthrough: true},
+					{Name: "Go", Extensions: []string{"go"}, Command: "vim {{.File}}", Env: map[string]string{"B": "2", "A": "1"}},
+					{Name: "Go Viewer", Extensions: []string{"go"}, Command: "bat {{.Base}}", Background: true},
+				},
+			}
+			Expect(config.SaveConfig(configFile, &cfg)).To(Succeed())
+		})
+
+		It("should print every matching rule with --all", func() {
+			Expect(runMatch(rootCmd, "main_test.go", matchOptions{All: true})).To(Succeed())
+			Expect(outBuf.String()).To(Equal("Go Test\nGo\nGo Viewer\n"))
+		})
+
+		It("should print the commands that would run with --command", func() {
+			Expect(runMatch(rootCmd, "main_test.go", matchOptions{Command: true})).To(Succeed())
+			Expect(outBuf.String()).To(Equal("go test main_test.go\nvim main_test.go [A=1, B=2]\n"))
+		})
+
+		It("should print the commands of every matching rule with --all --command", func() {
+			Expect(runMatch(rootCmd, "main.go", matchOptions{All: true, Command: true})).To(Succeed())
+			Expect(outBuf.String()).To(Equal("vim main.go [A=1, B=2]\nbat main.go (background)\n"))
+		})
+
+		It("should print the default command and fail when nothing matches", func() {
+			err := runMatch(rootCmd, "notes.txt", matchOptions{Command: true})
+			Expect(err).To(MatchError("no match found"))
+			Expect(outBuf.String()).To(Equal("less notes.txt\n"))
+		})
+
+		It("should match paths from stdin", func() {
+			rootCmd.SetIn(strings.NewReader("main.go\n\nmain_test.go\nnotes.txt\n"))
+			DeferCleanup(func() { rootCmd.SetIn(nil) })
+
+			err := runMatch(rootCmd, "-", matchOptions{})
+			Expect(err).To(MatchError("no match found for 1 of 3 paths"))
+			Expect(outBuf.String()).To(Equal("main.go\tGo\nmain_test.go\tGo Test\nnotes.txt\t\n"))
+		})
+
+		It("should print one line per command from stdin", func() {
+			rootCmd.SetIn(strings.NewReader("main_test.go\n"))
+			DeferCleanup(func() { rootCmd.SetIn(nil) })
+
+			Expect(runMatch(rootCmd, "-", matchOptions{Command: true})).To(Succeed())
+			Expect(outBuf.String()).To(Equal("main_test.go\tgo test main_test.go\nmain_test.go\tvim main_test.go [A=1, B=2]\n"))
+		})
+
+		It("should print JSON Lines from stdin", func() {
+			rootCmd.SetIn(strings.NewReader("main.go\nnotes.txt\n"))
+			DeferCleanup(func() { rootCmd.SetIn(nil) })
+
+			Expect(runMatch(rootCmd, "-", matchOptions{JSON: true})).To(MatchError("no match found for 1 of 2 paths"))
+			lines := strings.Split(strings.TrimSpace(outBuf.String()), "\n")
+			Expect(lines).To(HaveLen(2))
+			var e explanation
+			Expect(json.Unmarshal([]byte(lines[1]), &e)).To(Succeed())
+			Expect(e.Input).To(Equal("notes.txt"))
+			Expect(e.Action.Type).To(Equal("default_command"))
+		})
+	})
 })
@@ -1,12 +1,15 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/SuzumiyaAoba/via/internal/config"
+	"github.com/SuzumiyaAoba/via/internal/sync"
 	"github.com/spf13/cobra"
 )
 
@@ -28,36 +31,96 @@ var configProfileCopyCmd = &cobra.Command{
 	ValidArgsFunction: CompletionProfiles,
 }
 
-func runConfigProfileList(cmd *cobra.Command) error {
-	home, err := config.UserHomeDir()
-	if err != nil {
-		return fmt.Errorf("failed to get user home dir: %w", err)
-	}
+var configProfileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Manage configuration profiles",
+	Long:  `Create, remove and switch between configuration profiles.`,
+}
 
-	profilesDir := filepath.Join(home, ".config", "via", "profiles")
-	
-	// Check if profiles directory exists
-	if _, err := os.Stat(profilesDir); os.IsNotExist(err) {
-		fmt.Fprintln(cmd.OutOrStdout(), "No profiles available")
-		return nil
-	}
+var configProfileCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create a profile extending the default config",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		from, _ := cmd.Flags().GetString("from")
+		return runConfigProfileCreate(cmd, args[0], from)
+	},
+}
+
+var configProfileDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Delete a profile",
+	Long:  `Delete a profile together with its credentials, sync state, sync snapshots and backups.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runConfigProfileDelete(cmd, args[0])
+	},
+	ValidArgsFunction: CompletionProfiles,
+}
+
+var configProfileRenameCmd = &cobra.Command{
+	Use:   "rename <old> <new>",
+	Short: "Rename a profile and update the configs referring to it",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runConfigProfileRename(cmd, args[0], args[1])
+	},
+	ValidArgsFunction: CompletionProfiles,
+}
+
+var configProfileUseCmd = &cobra.Command{
+	Use:   "use <name>",
+	Short: "Use a profile by default (\"default\" for the main config)",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runConfigProfileUse(cmd, args[0])
+	},
+	ValidArgsFunction: CompletionProfiles,
+}
+
+var configProfileDiffCmd = &cobra.Command{
+	Use:   "diff <a> <b>",
+	Short: "Show the differences between the effective configs of two profiles",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runConfigProfileDiff(cmd, args[0], args[1])
+	},
+	ValidArgsFunction: CompletionProfiles,
+}
 
-	entries, err := os.ReadDir(profilesDir)
+var configProfileCurrentCmd = &cobra.Command{
+	Use:   "current",
+	Short: "Show the active profile and what selected it",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runConfigProfileCurrent(cmd)
+	},
+}
+
+func init() {
+	configProfileCmd.AddCommand(configProfileCreateCmd)
+	configProfileCmd.AddCommand(configProfileDeleteCmd)
+	configProfileCmd.AddCommand(configProfileRenameCmd)
+	configProfileCmd.AddCommand(configProfileUseCmd)
+	configProfileCmd.AddCommand(configProfileDiffCmd)
+	configProfileCmd.AddCommand(configProfileCurrentCmd)
+
+	configProfileCreateCmd.Flags().String("from", "", "Copy an existing profile instead of extending the default config")
+}
+
+func runConfigProfileList(cmd *cobra.Command) error {
+	names, err := config.ListProfiles()
 	if err != nil {
-		return fmt.Errorf("failed to read profiles directory: %w", err)
+		return err
 	}
 
-	if len(entries) == 0 {
+	if len(names) == 0 {
 		fmt.Fprintln(cmd.OutOrStdout(), "No profiles available")
 		return nil
 	}
 
 	fmt.Fprintln(cmd.OutOrStdout(), "Available profiles:")
-	for _, entry := range entries {
-		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".yml") {
-			profileName := strings.TrimSuffix(entry.Name(), ".yml")
-			fmt.Fprintf(cmd.OutOrStdout(), "  - %s\n", profileName)
-		}
+	for _, name := range names {
+		fmt.Fprintf(cmd.OutOrStdout(), "  - %s\n", name)
 	}
 
 	return nil
@@ -115,3 +178,306 @@ func runConfigProfileCopy(cmd *cobra.Command, from, to string) error {
 	fmt.Fprintf(cmd.OutOrStdout(), "Profile '%s' copied to '%s'\n", from, to)
 	return nil
 }
+
+// existingProfilePath returns the config file of a profile, failing if it does not exist
+func existingProfilePath(name string) (string, error) {
+	path, err := config.ProfilePath(name)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return "", fmt.Errorf("profile '%s' does not exist", name)
+	}
+	return path, nil
+}
+
+// profileCompanions returns the per-config files kept next to a profile:
+// credentials, sync state and the snapshots taken before pulls
+func profileCompanions(path string) []string {
+	return []string{config.CredentialsPath(path), sync.StatePath(path), sync.AutoStatePath(path), sync.SnapshotDir(path)}
+}
+
+// errUnchanged tells UpdateConfig to leave a config that needs no change alone
+var errUnchanged = errors.New("config unchanged")
+
+// forEachProfileConfig calls fn with the main config and every profile. Configs
+// for which fn returns true are saved.
+func forEachProfileConfig(fn func(name string, cfg *config.Config) bool) ([]string, error) {
+	names, err := config.ListProfiles()
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	for _, name := range append([]string{config.DefaultProfile}, names...) {
+		path, err := config.ProfilePath(name)
+		if err != nil {
+			return nil, err
+		}
+		if !fileExists(path) {
+			continue
+		}
+		err = config.UpdateConfig(path, func(cfg *config.Config) error {
+			if !fn(name, cfg) {
+				return errUnchanged
+			}
+			return nil
+		})
+		if errors.Is(err, errUnchanged) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to update profile '%s': %w", name, err)
+		}
+		changed = append(changed, name)
+	}
+	return changed, nil
+}
+
+// profileReferences returns the other configs extending the profile and those
+// whose auto rules select it. Configs are only read, so nothing is locked.
+func profileReferences(name string) (extendedBy, autoRules []string, err error) {
+	names, err := config.ListProfiles()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, other := range append([]string{config.DefaultProfile}, names...) {
+		if other == name {
+			continue
+		}
+		path, err := config.ProfilePath(other)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !fileExists(path) {
+			continue
+		}
+		cfg, err := config.LoadConfig(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load profile '%s': %w", other, err)
+		}
+		if slices.Contains(cfg.Extends, name) {
+			extendedBy = append(extendedBy, other)
+		}
+		if cfg.Profiles != nil && slices.ContainsFunc(cfg.Profiles.Auto, func(a config.AutoProfile) bool { return a.Profile == name }) {
+			autoRules = append(autoRules, other)
+		}
+	}
+	return extendedBy, autoRules, nil
+}
+
+func runConfigProfileCreate(cmd *cobra.Command, name, from string) error {
+	if err := config.ValidateProfileName(name); err != nil {
+		return err
+	}
+	if from != "" {
+		return runConfigProfileCopy(cmd, from, name)
+	}
+
+	path, err := config.ProfilePath(name)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("profile '%s' already exists", name)
+	}
+
+	cfg := &config.Config{Version: config.CurrentVersion, Extends: []string{config.DefaultProfile}}
+	if err := config.SaveConfig(path, cfg); err != nil {
+		return fmt.Errorf("failed to create profile: %w", err)
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "Profile '%s' created at %s\n", name, path)
+	return nil
+}
+
+func runConfigProfileDelete(cmd *cobra.Command, name string) error {
+	if name == config.DefaultProfile {
+		return fmt.Errorf("the default config cannot be deleted")
+	}
+	path, err := existingProfilePath(name)
+	if err != nil {
+		return err
+	}
+
+	// Deleting a profile others extend would break them
+	extendedBy, autoRules, err := profileReferences(name)
+	if err != nil {
+		return err
+	}
+	if len(extendedBy) > 0 {
+		return fmt.Errorf("profile '%s' is extended by %s", name, strings.Join(extendedBy, ", "))
+	}
+
+	backups, err := config.ListBackups(path)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to delete profile: %w", err)
+	}
+	for _, p := range profileCompanions(path) {
+		if err := os.RemoveAll(p); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", p, err)
+		}
+	}
+	for _, b := range backups {
+		if err := os.Remove(b.Path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to delete %s: %w", b.Path, err)
+		}
+	}
+
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "Profile '%s' deleted\n", name)
+
+	active, err := config.LoadActiveProfile()
+	if err != nil {
+		return err
+	}
+	if active == name {
+		if err := config.SaveActiveProfile(""); err != nil {
+			return err
+		}
+		fmt.Fprintln(out, "Using the default config again")
+	}
+	if len(autoRules) > 0 {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Warning: auto rules in %s still select '%s'\n", strings.Join(autoRules, ", "), name)
+	}
+	return nil
+}
+
+func runConfigProfileRename(cmd *cobra.Command, oldName, newName string) error {
+	if oldName == config.DefaultProfile {
+		return fmt.Errorf("the default config cannot be renamed")
+	}
+	if err := config.ValidateProfileName(newName); err != nil {
+		return err
+	}
+	oldPath, err := existingProfilePath(oldName)
+	if err != nil {
+		return err
+	}
+	newPath, err := config.ProfilePath(newName)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(newPath); err == nil {
+		return fmt.Errorf("profile '%s' already exists", newName)
+	}
+	// Keep the format of the profile
+	newPath = strings.TrimSuffix(newPath, filepath.Ext(newPath)) + filepath.Ext(oldPath)
+
+	if err := os.Rename(oldPath, newPath); err != nil {
+		return fmt.Errorf("failed to rename profile: %w", err)
+	}
+	newCompanions := profileCompanions(newPath)
+	for i, p := range profileCompanions(oldPath) {
+		if err := os.Rename(p, newCompanions[i]); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to move %s: %w", p, err)
+		}
+	}
+
+	// Keep extends lists and auto rules pointing at the profile
+	updated, err := forEachProfileConfig(func(_ string, cfg *config.Config) bool {
+		changed := false
+		for i, parent := range cfg.Extends {
+			if parent == oldName {
+				cfg.Extends[i] = newName
+				changed = true
+			}
+		}
+		if cfg.Profiles != nil {
+			for i := range cfg.Profiles.Auto {
+				if cfg.Profiles.Auto[i].Profile == oldName {
+					cfg.Profiles.Auto[i].Profile = newName
+					changed = true
+				}
+			}
+		}
+		return changed
+	})
+	if err != nil {
+		return err
+	}
+
+	active, err := config.LoadActiveProfile()
+	if err != nil {
+		return err
+	}
+	if active == oldName {
+		if err := config.SaveActiveProfile(newName); err != nil {
+			return err
+		}
+	}
+
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "Profile '%s' renamed to '%s'\n", oldName, newName)
+	if len(updated) > 0 {
+		fmt.Fprintf(out, "Updated references in %s\n", strings.Join(updated, ", "))
+	}
+	return nil
+}
+
+func runConfigProfileUse(cmd *cobra.Command, name string) error {
+	if name != config.DefaultProfile {
+		if _, err := existingProfilePath(name); err != nil {
+			return err
+		}
+	}
+	if err := config.SaveActiveProfile(name); err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	if name == config.DefaultProfile {
+		fmt.Fprintln(out, "Using the default config")
+	} else {
+		fmt.Fprintf(out, "Using profile '%s' by default\n", name)
+	}
+	if os.Getenv("VIA_PROFILE") != "" {
+		fmt.Fprintf(out, "Note: VIA_PROFILE=%s takes precedence in this shell\n", os.Getenv("VIA_PROFILE"))
+	}
+	return nil
+}
+
+func runConfigProfileDiff(cmd *cobra.Command, a, b string) error {
+	load := func(name string) (*config.Config, error) {
+		path, err := existingProfilePath(name)
+		if err != nil {
+			return nil, err
+		}
+		return config.LoadResolvedConfig(path)
+	}
+
+	from, err := load(a)
+	if err != nil {
+		return err
+	}
+	to, err := load(b)
+	if err != nil {
+		return err
+	}
+
+	// Sync settings are specific to each file and say nothing about behaviour
+	from.Sync, to.Sync = nil, nil
+
+	fmt.Fprintf(cmd.OutOrStdout(), "--- %s\n+++ %s\n", a, b)
+	printChanges(cmd.OutOrStdout(), sync.Diff(from, to))
+	return nil
+}
+
+func runConfigProfileCurrent(cmd *cobra.Command) error {
+	out := cmd.OutOrStdout()
+	switch {
+	case profile != "" && profileSource != "":
+		fmt.Fprintf(out, "%s (selected by %s)\n", profile, profileSource)
+	case profile != "":
+		fmt.Fprintln(out, profile)
+	case cfgFile != "":
+		fmt.Fprintf(out, "custom config %s\n", cfgFile)
+	default:
+		fmt.Fprintln(out, config.DefaultProfile)
+	}
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 
 	"github.com/SuzumiyaAoba/via/internal/config"
+	"github.com/SuzumiyaAoba/via/internal/sync"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 )
@@ -147,4 +148,238 @@ var _ = Describe("Profile commands", func() {
 			Expect(err.Error()).To(ContainSubstring("target profile 'existing' already exists"))
 		})
 	})
+
+	Describe("profile subcommands", func() {
+		profilePath := func(name string) string {
+			return filepath.Join(filepath.Dir(configFile), "profiles", name+".yml")
+		}
+
+		run := func(args ...string) error {
+			rootCmd.SetArgs(append([]string{":config", "profile"}, args...))
+			return rootCmd.Execute()
+		}
+
+		BeforeEach(func() {
+			cfgFile = ""
+		})
+
+		It("should create a profile extending the default config", func() {
+			Expect(run("create", "work")).To(Succeed())
+			cfg, err := config.LoadConfig(profilePath("work"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Extends).To(Equal([]string{"default"}))
+
+			Expect(run("create", "work")).To(MatchError(ContainSubstring("profile 'work' already exists")))
+			Expect(run("create", "default")).To(MatchError(ContainSubstring("cannot be used as a profile name")))
+		})
+
+		It("should create a profile from a copy", func() {
+			Expect(config.SaveConfig(configFile, &config.Config{Version: "1", DefaultCommand: "less {{.File}}"})).To(Succeed())
+			DeferCleanup(func() { configProfileCreateCmd.Flags().Set("from", "") })
+			Expect(run("create", "work", "--from", "default")).To(Succeed())
+			cfg, err := config.LoadConfig(profilePath("work"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.DefaultCommand).To(Equal("less {{.File}}"))
+		})
+
+		It("should use a profile by default until switched back", func() {
+			Expect(run("create", "work")).To(Succeed())
+			Expect(run("use", "work")).To(Succeed())
+			Expect(outBuf.String()).To(ContainSubstring("Using profile 'work' by default"))
+
+			resetGlobals()
+			outBuf.Reset()
+			Expect(run("current")).To(Succeed())
+			Expect(outBuf.String()).To(Equal("work (selected by profile use)\n"))
+
+			Expect(run("use", "default")).To(Succeed())
+			resetGlobals()
+			outBuf.Reset()
+			Expect(run("current")).To(Succeed())
+			Expect(outBuf.String()).To(Equal("default\n"))
+
+			Expect(run("use", "missing")).To(MatchError(ContainSubstring("profile 'missing' does not exist")))
+		})
+
+		It("should report VIA_PROFILE as the source", func() {
+			GinkgoT().Setenv("VIA_PROFILE", "work")
+			Expect(run("current")).To(Succeed())
+			Expect(outBuf.String()).To(Equal("work (selected by VIA_PROFILE)\n"))
+		})
+
+		It("should rename a profile and the references to it", func() {
+			Expect(run("create", "base")).To(Succeed())
+			Expect(config.SaveConfig(profilePath("work"), &config.Config{Version: "1", Extends: []string{"base"}})).To(Succeed())
+			Expect(config.SaveConfig(configFile, &config.Config{
+				Version:  "1",
+				Profiles: &config.ProfilesConfig{Auto: []config.AutoProfile{{Profile: "base", Env: "CI"}}},
+			})).To(Succeed())
+			Expect(config.SaveCredentials(profilePath("base"), &config.Credentials{SyncToken: "secret"})).To(Succeed())
+			Expect(run("use", "base")).To(Succeed())
+
+			Expect(run("rename", "base", "shared")).To(Succeed())
+			Expect(outBuf.String()).To(ContainSubstring("Updated references in default, work"))
+			Expect(fileExists(profilePath("base"))).To(BeFalse())
+
+			work, err := config.LoadConfig(profilePath("work"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(work.Extends).To(Equal([]string{"shared"}))
+			main, err := config.LoadConfig(configFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(main.Profiles.Auto[0].Profile).To(Equal("shared"))
+
+			creds, err := config.LoadCredentials(profilePath("shared"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(creds.SyncToken).To(Equal("secret"))
+
+			active, err := config.LoadActiveProfile()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(active).To(Equal("shared"))
+		})
+
+		It("should delete a profile nothing extends", func() {
+			Expect(run("create", "base")).To(Succeed())
+			Expect(config.SaveConfig(profilePath("work"), &config.Config{Version: "1", Extends: []string{"base"}})).To(Succeed())
+
+			Expect(run("delete", "base")).To(MatchError(ContainSubstring("profile 'base' is extended by work")))
+
+			Expect(run("use", "work")).To(Succeed())
+			Expect(run("delete", "work")).To(Succeed())
+			Expect(outBuf.String()).To(ContainSubstring("Using the default config again"))
+			Expect(fileExists(profilePath("work"))).To(BeFalse())
+
+			Expect(run("delete", "default")).To(MatchError(ContainSubstring("cannot be deleted")))
+		})
+
+		It("should delete the backups and sync snapshots of a deleted profile", func() {
+			Expect(run("create", "work")).To(Succeed())
+			Expect(run("create", "home")).To(Succeed())
+			for _, name := range []string{"work", "home"} {
+				_, err := config.SaveBackup(profilePath(name))
+				Expect(err).NotTo(HaveOccurred())
+			}
+			Expect(sync.TakeSnapshot(profilePath("work"), "pull", nil, nil)).To(Succeed())
+			Expect(config.SaveCredentials(profilePath("work"), &config.Credentials{SyncToken: "secret"})).To(Succeed())
+
+			Expect(run("delete", "work")).To(Succeed())
+
+			backups, err := config.ListBackups(profilePath("work"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(backups).To(BeEmpty())
+			Expect(sync.SnapshotDir(profilePath("work"))).NotTo(BeADirectory())
+			Expect(config.CredentialsPath(profilePath("work"))).NotTo(BeAnExistingFile())
+
+			backups, err = config.ListBackups(profilePath("home"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(backups).To(HaveLen(1))
+		})
+
+		It("should diff the effective configs of two profiles", func() {
+			Expect(config.SaveConfig(configFile, &config.Config{
+				Version: "1",
+				Rules:   []config.Rule{{Name: "PDF", Extensions: []string{"pdf"}, Command: "evince {{.File}}"}},
+			})).To(Succeed())
+			Expect(config.SaveConfig(profilePath("work"), &config.Config{
+				Version: "1",
+				Extends: []string{"default"},
+				Rules:   []config.Rule{{Name: "Markdown", Extensions: []string{"md"}, Command: "glow {{.File}}"}},
+			})).To(Succeed())
+
+			Expect(run("diff", "default", "work")).To(Succeed())
+			Expect(outBuf.String()).To(ContainSubstring("--- default\n+++ work"))
+			Expect(outBuf.String()).To(ContainSubstring("Markdown"))
+			Expect(outBuf.String()).NotTo(ContainSubstring("PDF"))
+		})
+	})
+})
+
+var _ = Describe("Automatic profiles", func() {
+	var (
+		tmpDir  string
+		project string
+		outBuf  bytes.Buffer
+	)
+
+	write := func(path, content string) {
+		Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+		Expect(os.WriteFile(path, []byte(content), 0644)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		resetGlobals()
+		tmpDir = GinkgoT().TempDir()
+		project = filepath.Join(tmpDir, "work", "api")
+
+		origHome := config.UserHomeDir
+		config.UserHomeDir = func() (string, error) { return tmpDir, nil }
+		DeferCleanup(func() { config.UserHomeDir = origHome })
+
+		outBuf.Reset()
+		rootCmd.SetOut(&outBuf)
+		rootCmd.SetErr(&outBuf)
+
+		configDir := filepath.Join(tmpDir, ".config", "via")
+		write(filepath.Join(configDir, "config.yml"), `
+version: "1"
+profiles:
+  auto:
+    - profile: work
+      dir: ~/work/*
+rules:
+  - name: Markdown
+    extensions: [md]
+    command: less {{.File}}
+`)
+		write(filepath.Join(configDir, "profiles", "work.yml"), `
+version: "1"
+extends: [default]
+profiles:
+  project_files: true
+rules:
+  - name: Markdown
+    extensions: [md]
+    command: glow {{.File}}
+`)
+		write(filepath.Join(project, "docs", "README.md"), "# API\n")
+		write(filepath.Join(tmpDir, "notes.md"), "# Notes\n")
+	})
+
+	run := func(file string) string {
+		rootCmd.SetArgs([]string{"--dry-run", "--no-history", file})
+		Expect(rootCmd.Execute()).To(Succeed())
+		return outBuf.String()
+	}
+
+	It("should select the profile matching the file's directory", func() {
+		Expect(run(filepath.Join(project, "docs", "README.md"))).To(ContainSubstring("glow"))
+		Expect(profile).To(Equal("work"))
+	})
+
+	It("should use the main config elsewhere", func() {
+		Expect(run(filepath.Join(tmpDir, "notes.md"))).To(ContainSubstring("less"))
+		Expect(profile).To(BeEmpty())
+	})
+
+	It("should not override an explicit profile", func() {
+		GinkgoT().Setenv("VIA_PROFILE", "default-only")
+		write(filepath.Join(tmpDir, ".config", "via", "profiles", "default-only.yml"), `
+version: "1"
+rules:
+  - name: Markdown
+    extensions: [md]
+    command: bat {{.File}}
+`)
+		Expect(run(filepath.Join(project, "docs", "README.md"))).To(ContainSubstring("bat"))
+	})
+
+	It("should layer project files when the profile enables them", func() {
+		write(filepath.Join(project, config.ProjectConfigName), `
+version: "1"
+rules:
+  - name: Markdown
+    extensions: [md]
+    command: mdcat {{.File}}
+`)
+		Expect(run(filepath.Join(project, "docs", "README.md"))).To(ContainSubstring("mdcat"))
+	})
 })
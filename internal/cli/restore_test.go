@@ -0,0 +1,115 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+
+	"github.com/SuzumiyaAoba/via/internal/config"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Restore command", func() {
+	var (
+		tmpDir     string
+		configFile string
+		outBuf     bytes.Buffer
+	)
+
+	save := func(command string) {
+		cfg := config.Config{Version: config.CurrentVersion, DefaultCommand: command}
+		Expect(config.SaveConfig(configFile, &cfg)).To(Succeed())
+	}
+
+	defaultCommand := func() string {
+		cfg, err := config.LoadConfig(configFile)
+		Expect(err).NotTo(HaveOccurred())
+		return cfg.DefaultCommand
+	}
+
+	BeforeEach(func() {
+		resetGlobals()
+		tmpDir = GinkgoT().TempDir()
+		configFile = filepath.Join(tmpDir, "config.yml")
+		outBuf.Reset()
+		rootCmd.SetOut(&outBuf)
+		rootCmd.SetErr(&outBuf)
+		cfgFile = configFile
+	})
+
+	AfterEach(func() {
+		cfgFile = ""
+	})
+
+	Describe("runConfigRestore", func() {
+		BeforeEach(func() {
+			save("vim")
+			save("nvim")
+			save("code")
+		})
+
+		It("should restore a backup by number", func() {
+			Expect(runConfigRestore(rootCmd, []string{"2"})).To(Succeed())
+			Expect(defaultCommand()).To(Equal("vim"))
+			Expect(outBuf.String()).To(ContainSubstring("Configuration restored from"))
+		})
+
+		It("should restore a backup by file name", func() {
+			backups, err := config.ListBackups(configFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(runConfigRestore(rootCmd, []string{filepath.Base(backups[0].Path)})).To(Succeed())
+			Expect(defaultCommand()).To(Equal("nvim"))
+		})
+
+		It("should ask which backup to restore", func() {
+			origSelect := selectBackup
+			DeferCleanup(func() { selectBackup = origSelect })
+			selectBackup = func(backups []config.Backup) (config.Backup, error) {
+				Expect(backups).To(HaveLen(2))
+				return backups[0], nil
+			}
+
+			Expect(runConfigRestore(rootCmd, nil)).To(Succeed())
+			Expect(defaultCommand()).To(Equal("nvim"))
+		})
+
+		It("should be undoable", func() {
+			Expect(runConfigRestore(rootCmd, []string{"2"})).To(Succeed())
+			Expect(runConfigRestore(rootCmd, []string{"1"})).To(Succeed())
+			Expect(defaultCommand()).To(Equal("code"))
+		})
+
+		It("should reject unknown backups", func() {
+			Expect(runConfigRestore(rootCmd, []string{"7"})).To(MatchError(ContainSubstring("out of range")))
+			Expect(runConfigRestore(rootCmd, []string{"nope.yml"})).To(MatchError(ContainSubstring("not found")))
+		})
+
+		It("should list the backups", func() {
+			rootCmd.SetArgs([]string{"--config", configFile, ":config", "restore", "--list"})
+			DeferCleanup(func() {
+				Expect(configRestoreCmd.Flags().Set("list", "false")).To(Succeed())
+			})
+			Expect(rootCmd.Execute()).To(Succeed())
+			Expect(outBuf.String()).To(ContainSubstring("config."))
+			Expect(defaultCommand()).To(Equal("code"))
+		})
+	})
+
+	It("should fail without backups", func() {
+		Expect(os.WriteFile(configFile, []byte("version: \"2\"\n"), 0644)).To(Succeed())
+		Expect(runConfigRestore(rootCmd, nil)).To(MatchError(ContainSubstring("no backups")))
+	})
+
+	It("should back up the config replaced by an import", func() {
+		save("vim")
+		src := filepath.Join(tmpDir, "import.yml")
+		Expect(os.WriteFile(src, []byte("version: \"2\"\ndefault_command: nano\n"), 0644)).To(Succeed())
+
+		Expect(runConfigImport(rootCmd, src)).To(Succeed())
+		Expect(defaultCommand()).To(Equal("nano"))
+		backups, err := config.ListBackups(configFile)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(backups).To(HaveLen(1))
+	})
+})
@@ -6,6 +6,7 @@ import (
 
 	"github.com/SuzumiyaAoba/via/internal/config"
 	"github.com/SuzumiyaAoba/via/internal/executor"
+	"github.com/SuzumiyaAoba/via/internal/history"
 	"github.com/SuzumiyaAoba/via/internal/logger"
 	"github.com/spf13/cobra"
 )
@@ -16,8 +17,13 @@ var (
 	dryRun      bool
 	interactive bool
 	explain     bool
+	jsonOutput  bool
 	verbose     bool
 	profile     string
+	noHistory   bool
+
+	// profileSource records what selected the profile, for 'profile current'
+	profileSource string
 )
 
 func init() {
@@ -25,9 +31,11 @@ func init() {
 	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "print command instead of executing")
 	rootCmd.Flags().BoolVarP(&interactive, "select", "s", false, "Interactive selection")
 	rootCmd.Flags().BoolVar(&explain, "explain", false, "Show detailed matching information")
+	rootCmd.Flags().BoolVar(&jsonOutput, "json", false, "Print --explain output as JSON")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose logging")
 	rootCmd.PersistentFlags().StringVarP(&profile, "profile", "p", "", "Configuration profile to use")
 	rootCmd.RegisterFlagCompletionFunc("profile", CompletionProfiles)
+	rootCmd.PersistentFlags().BoolVar(&noHistory, "no-history", false, "Do not record this execution in history")
 	
 	// Allow flags after positional arguments to be passed to the command
 	rootCmd.Flags().SetInterspersed(false)
@@ -57,6 +65,9 @@ Simply type vv :help [path to command] for full details.`,
 	rootCmd.AddCommand(historyCmd)
 	rootCmd.AddCommand(dashboardCmd)
 	rootCmd.AddCommand(matchCmd)
+	rootCmd.AddCommand(daemonCmd)
+	rootCmd.AddCommand(serveCmd)
+	rootCmd.AddCommand(installHandlerCmd)
 }
 
 var rootCmd = &cobra.Command{
@@ -83,20 +94,46 @@ var rootCmd = &cobra.Command{
 
 // initialize handles common setup like logging and config loading
 func initialize(cmd *cobra.Command, args []string) error {
-	// Check for profile environment variable
-	if profile == "" && os.Getenv("VIA_PROFILE") != "" {
+	// The profile comes from --profile, VIA_PROFILE, the auto rules of the main
+	// config or 'profile use', in that order
+	profileSource = ""
+	if profile != "" {
+		profileSource = "--profile"
+	} else if os.Getenv("VIA_PROFILE") != "" {
 		profile = os.Getenv("VIA_PROFILE")
+		profileSource = "VIA_PROFILE"
+	}
+
+	if cfgFile == "" && profile == "" {
+		if profile = autoProfile(args); profile != "" {
+			profileSource = "auto rule"
+		}
+	}
+	if cfgFile == "" && profile == "" {
+		active, err := config.LoadActiveProfile()
+		if err != nil {
+			return err
+		}
+		if profile = active; profile != "" {
+			profileSource = "profile use"
+		}
 	}
 
 	// Resolve config file path with profile
 	if cfgFile == "" && profile != "" {
-		resolvedPath, err := config.GetConfigPathWithProfile("", profile)
+		resolvedPath, err := config.ProfilePath(profile)
 		if err != nil {
 			return fmt.Errorf("failed to resolve profile config path: %w", err)
 		}
 		cfgFile = resolvedPath
 	}
 
+	// Check for private mode environment variable
+	if !noHistory && isTruthy(os.Getenv("VIA_NO_HISTORY")) {
+		noHistory = true
+	}
+	history.SetDisabled(noHistory)
+
 	// Initialize logger
 	if err := initLogger(); err != nil {
 		return fmt.Errorf("failed to initialize logger: %w", err)
@@ -105,10 +142,23 @@ func initialize(cmd *cobra.Command, args []string) error {
 	// We defer logger closing in main, not here, or we let the process exit handle it
 	// defer logger.GetGlobal().Close()
 
+	if profileSource != "" {
+		logger.Debug("Using profile %s selected by %s", profile, profileSource)
+	}
+
 	logger.Debug("Initialized with flags - verbose: %v, dryRun: %v, profile: %s, config: %s", verbose, dryRun, profile, cfgFile)
 	return nil
 }
 
+// configureHistory applies the history settings from the loaded config
+func configureHistory(cfg *config.Config) {
+	if cfg.History != nil {
+		history.SetExcludePatterns(cfg.History.Exclude)
+	} else {
+		history.SetExcludePatterns(nil)
+	}
+}
+
 func runRoot(cmd *cobra.Command, args []string) error {
 	// Manually parse flags
 	// Note: We must use cmd.Flags().Parse() directly because cmd.ParseFlags()
@@ -194,10 +244,24 @@ func runRoot(cmd *cobra.Command, args []string) error {
 
 	logger.Debug("Starting via execution with args: %v", args)
 
-	cfg, err := config.LoadConfig(cfgFile)
+	// A running daemon has the config loaded already, interactive selection needs it here
+	if !interactive {
+		if handled, err := openWithDaemon(cmd, args); handled {
+			return err
+		}
+	}
+
+	cfg, err := config.LoadResolvedConfig(cfgFile)
 	if err != nil {
 		return fmt.Errorf("error loading config: %w", err)
 	}
+	if !dryRun {
+		cfg = autoPullOnStart(cmd, cfg)
+	}
+	if cfg, err = applyProjectConfigs(cfg, args); err != nil {
+		return fmt.Errorf("error loading config: %w", err)
+	}
+	configureHistory(cfg)
 
 	// Initialize Executor
 	exec := executor.NewExecutor(cmd.OutOrStdout(), dryRun)
@@ -209,24 +273,12 @@ func runRoot(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	// Handle file execution with single argument
-	if len(args) == 1 {
-		filename := args[0]
-
-		// Interactive mode
-		if interactive {
-			return handleInteractive(cfg, exec, filename)
-		}
-
-		// Normal file execution - if it fails, try as command
-		if err := handleFileExecution(cfg, exec, filename); err == nil {
-			return nil
-		}
+	// Interactive mode
+	if interactive && len(args) == 1 {
+		return handleInteractive(cfg, exec, args[0])
 	}
 
-	// Handle command execution with multiple arguments
-	// Or if file execution failed
-	return handleCommandExecution(cfg, exec, args)
+	return handleOpen(cfg, exec, args)
 }
 
 func Execute() {
@@ -37,7 +37,7 @@ var _ = Describe("Via CLI", func() {
 	Context("Command Disambiguation", func() {
 		BeforeEach(func() {
 			configContent := `
-version: "1"
+version: "2"
 rules:
   - name: Config Rule
     extensions: [conf]
@@ -74,7 +74,7 @@ rules:
 			err := rootCmd.Execute()
 			Expect(err).NotTo(HaveOccurred())
 			// config list output is YAML
-			Expect(outBuf.String()).To(ContainSubstring("version: \"1\""))
+			Expect(outBuf.String()).To(ContainSubstring("version: \"2\""))
 		})
 
 		It("should handle flags before double dash", func() {
@@ -83,7 +83,7 @@ rules:
 			rootCmd.SetArgs([]string{"--config", configFile, "--dry-run", "--", ":config", "list"})
 			err := rootCmd.Execute()
 			Expect(err).NotTo(HaveOccurred())
-			Expect(outBuf.String()).To(ContainSubstring("version: \"1\""))
+			Expect(outBuf.String()).To(ContainSubstring("version: \"2\""))
 		})
 	})
 
@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Config test command", func() {
+	var (
+		configFile string
+		outBuf     bytes.Buffer
+	)
+
+	BeforeEach(func() {
+		resetGlobals()
+		configFile = filepath.Join(GinkgoT().TempDir(), "config.yml")
+		outBuf.Reset()
+		rootCmd.SetOut(&outBuf)
+		rootCmd.SetErr(&outBuf)
+		cfgFile = configFile
+	})
+
+	AfterEach(func() {
+		cfgFile = ""
+	})
+
+	It("should report passing tests", func() {
+		Expect(os.WriteFile(configFile, []byte(`version: "2"
+rules:
+    - name: Go Test Runner
+      regex: _test\.go$
+      command: go test {{.File}}
+      tests:
+        - input: foo_test.go
+    - name: Go
+      extensions: [go]
+      command: vim {{.File}}
+      tests:
+        - input: main.go
+        - input: foo_test.go
+          expect: Go Test Runner
+        - input: notes.txt
+          expect_none: true
+`), 0644)).To(Succeed())
+
+		Expect(runConfigTest(rootCmd)).To(Succeed())
+		output := outBuf.String()
+		Expect(output).To(ContainSubstring(`PASS  ` + configFile + `:3: rule "Go Test Runner": foo_test.go`))
+		Expect(output).To(ContainSubstring(`PASS  ` + configFile + `:8: rule "Go": notes.txt`))
+		Expect(output).To(ContainSubstring("4 passed, 0 failed"))
+	})
+
+	It("should fail when a test fails", func() {
+		Expect(os.WriteFile(configFile, []byte(`version: "2"
+rules:
+    - name: Go
+      extensions: [go]
+      command: vim {{.File}}
+    - name: Go Test Runner
+      regex: _test\.go$
+      command: go test {{.File}}
+      tests:
+        - input: foo_test.go
+        - input: notes.txt
+          expect_none: true
+`), 0644)).To(Succeed())
+
+		err := runConfigTest(rootCmd)
+		Expect(err).To(MatchError("1 of 2 rule tests failed"))
+		Expect(outBuf.String()).To(ContainSubstring(`FAIL  ` + configFile + `:6: rule "Go Test Runner": foo_test.go: expected "Go Test Runner", matched "Go"`))
+		Expect(outBuf.String()).To(ContainSubstring("1 passed, 1 failed"))
+	})
+
+	It("should say when there are no tests", func() {
+		Expect(os.WriteFile(configFile, []byte("version: \"2\"\nrules: []\n"), 0644)).To(Succeed())
+
+		Expect(runConfigTest(rootCmd)).To(Succeed())
+		Expect(outBuf.String()).To(ContainSubstring("No rule tests found"))
+	})
+})
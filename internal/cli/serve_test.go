@@ -0,0 +1,236 @@
+package cli
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/SuzumiyaAoba/via/internal/history"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Serve", func() {
+	var (
+		tmpDir     string
+		configFile string
+		server     *httptest.Server
+	)
+
+	// call sends a request with the token and decodes the JSON response into v
+	call := func(method, path, body string, v any) int {
+		req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		Expect(err).NotTo(HaveOccurred())
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := server.Client().Do(req)
+		Expect(err).NotTo(HaveOccurred())
+		defer resp.Body.Close()
+		if v != nil {
+			Expect(resp.Header.Get("Content-Type")).To(Equal("application/json"))
+			Expect(json.NewDecoder(resp.Body).Decode(v)).To(Succeed())
+		}
+		return resp.StatusCode
+	}
+
+	BeforeEach(func() {
+		resetGlobals()
+		tmpDir = GinkgoT().TempDir()
+		configFile = filepath.Join(tmpDir, "config.yml")
+		Expect(os.WriteFile(configFile, []byte(`version: "2"
+rules:
+  - name: Text
+    extensions: [txt]
+    command: echo {{.File}}
+  - name: Browser
+    scheme: https
+    command: true {{.File}}
+`), 0644)).To(Succeed())
+
+		history.SetHistoryPath(filepath.Join(tmpDir, "history.json"))
+		history.SetDisabled(false)
+		DeferCleanup(func() {
+			history.SetHistoryPath("")
+			history.SetExcludePatterns(nil)
+		})
+
+		server = httptest.NewServer(newServeHandler(configFile, "secret"))
+		DeferCleanup(server.Close)
+	})
+
+	It("should reject requests without the token", func() {
+		resp, err := http.Get(server.URL + "/rules")
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+		Expect(resp.Header.Get("WWW-Authenticate")).To(Equal("Bearer"))
+
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/rules", nil)
+		req.Header.Set("Authorization", "Bearer wrong")
+		resp, err = http.DefaultClient.Do(req)
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+	})
+
+	It("should reject other methods", func() {
+		Expect(call(http.MethodGet, "/open", "", nil)).To(Equal(http.StatusMethodNotAllowed))
+	})
+
+	Describe("POST /open", func() {
+		It("should return the commands without running them on a dry run", func() {
+			target := filepath.Join(tmpDir, "notes.txt")
+			var resp openResponse
+			Expect(call(http.MethodPost, "/open", `{"target": "`+target+`", "dry_run": true}`, &resp)).To(Equal(http.StatusOK))
+			Expect(resp).To(Equal(openResponse{Target: target, Commands: []string{"echo " + target}, DryRun: true}))
+
+			entries, err := history.LoadHistory()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(BeEmpty())
+		})
+
+		It("should open links and record them in history", func() {
+			var resp openResponse
+			Expect(call(http.MethodPost, "/open", `{"target": "https://example.com/a"}`, &resp)).To(Equal(http.StatusAccepted))
+			Expect(resp.Commands).To(Equal([]string{"true https://example.com/a"}))
+			Expect(resp.DryRun).To(BeFalse())
+
+			Eventually(history.LoadHistory).Should(ConsistOf(
+				HaveField("Command", "https://example.com/a"),
+			))
+		})
+
+		It("should answer before the commands finish", func() {
+			Expect(os.WriteFile(configFile, []byte(`version: "2"
+rules:
+  - name: Slow
+    extensions: [txt]
+    command: touch {{.Dir}}/running; while [ -e {{.Dir}}/running ]; do sleep 0.1; done
+    history: false
+`), 0644)).To(Succeed())
+
+			// The command runs until the file it creates is removed
+			target := filepath.Join(tmpDir, "notes.txt")
+			Expect(call(http.MethodPost, "/open", `{"target": "`+target+`"}`, nil)).To(Equal(http.StatusAccepted))
+			running := filepath.Join(tmpDir, "running")
+			Eventually(running).Should(BeAnExistingFile())
+			Expect(os.Remove(running)).To(Succeed())
+		})
+
+		DescribeTable("rejecting targets",
+			func(body string, status int, message string) {
+				var resp map[string]string
+				Expect(call(http.MethodPost, "/open", body, &resp)).To(Equal(status))
+				Expect(resp["error"]).To(ContainSubstring(message))
+			},
+			Entry("invalid JSON", `{`, http.StatusBadRequest, "invalid request body"),
+			Entry("no target", `{}`, http.StatusBadRequest, "target is required"),
+			Entry("relative path", `{"target": "notes.txt"}`, http.StatusBadRequest, "absolute path"),
+			Entry("command", `{"target": "/bin/ls -l"}`, http.StatusNotFound, "no rule matches"),
+		)
+	})
+
+	Describe("GET /match", func() {
+		It("should return the same JSON as :match --json", func() {
+			var e explanation
+			Expect(call(http.MethodGet, "/match?target="+url.QueryEscape("https://example.com"), "", &e)).To(Equal(http.StatusOK))
+			Expect(e.Matched).To(BeTrue())
+			Expect(e.File.Scheme).To(Equal("https"))
+			Expect(e.Action.Commands[0].Rule).To(Equal("Browser"))
+		})
+
+		It("should report inputs without a match", func() {
+			var e explanation
+			Expect(call(http.MethodGet, "/match?target=/a.pdf", "", &e)).To(Equal(http.StatusOK))
+			Expect(e.Matched).To(BeFalse())
+		})
+
+		DescribeTable("rejecting targets",
+			func(query, message string) {
+				var resp map[string]string
+				Expect(call(http.MethodGet, "/match"+query, "", &resp)).To(Equal(http.StatusBadRequest))
+				Expect(resp["error"]).To(ContainSubstring(message))
+			},
+			Entry("no target", "", "target is required"),
+			Entry("relative path", "?target=notes.txt", "absolute path"),
+		)
+	})
+
+	Describe("GET /rules", func() {
+		It("should list the rules", func() {
+			var resp struct {
+				Rules []serveRule `json:"rules"`
+			}
+			Expect(call(http.MethodGet, "/rules", "", &resp)).To(Equal(http.StatusOK))
+			Expect(resp.Rules).To(HaveLen(2))
+			Expect(resp.Rules[0]).To(Equal(serveRule{Index: 1, Name: "Text", Extensions: []string{"txt"}, Command: "echo {{.File}}", Source: configFile + ":3"}))
+			Expect(resp.Rules[1].Scheme).To(Equal("https"))
+		})
+
+		It("should report config errors", func() {
+			Expect(os.WriteFile(configFile, []byte("rules: ["), 0644)).To(Succeed())
+			var resp map[string]string
+			Expect(call(http.MethodGet, "/rules", "", &resp)).To(Equal(http.StatusInternalServerError))
+			Expect(resp["error"]).To(ContainSubstring("error loading config"))
+		})
+	})
+
+	Describe("GET /history", func() {
+		BeforeEach(func() {
+			Expect(history.AddEntry("a.txt", "Text")).To(Succeed())
+			Expect(history.AddEntry("b.txt", "Text")).To(Succeed())
+		})
+
+		It("should list the history newest first", func() {
+			var resp struct {
+				Entries []history.HistoryEntry `json:"entries"`
+			}
+			Expect(call(http.MethodGet, "/history", "", &resp)).To(Equal(http.StatusOK))
+			Expect(resp.Entries).To(HaveLen(2))
+			Expect(resp.Entries[0].Command).To(Equal("b.txt"))
+
+			Expect(call(http.MethodGet, "/history?limit=1", "", &resp)).To(Equal(http.StatusOK))
+			Expect(resp.Entries).To(HaveLen(1))
+		})
+
+		It("should reject an invalid limit", func() {
+			Expect(call(http.MethodGet, "/history?limit=x", "", nil)).To(Equal(http.StatusBadRequest))
+		})
+	})
+
+	Describe("serveToken", func() {
+		It("should prefer the token file, then VIA_SERVE_TOKEN, then generate one", func() {
+			GinkgoT().Setenv("VIA_SERVE_TOKEN", "from-env")
+			tokenFile := filepath.Join(tmpDir, "token")
+			Expect(os.WriteFile(tokenFile, []byte("from-file\n"), 0600)).To(Succeed())
+			token, generated, err := serveToken(tokenFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(token).To(Equal("from-file"))
+			Expect(generated).To(BeFalse())
+
+			token, generated, err = serveToken("")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(token).To(Equal("from-env"))
+			Expect(generated).To(BeFalse())
+
+			GinkgoT().Setenv("VIA_SERVE_TOKEN", "")
+			token, generated, err = serveToken("")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(token).To(HaveLen(32))
+			Expect(generated).To(BeTrue())
+		})
+
+		It("should reject missing and empty token files", func() {
+			_, _, err := serveToken(filepath.Join(tmpDir, "missing"))
+			Expect(err).To(MatchError(ContainSubstring("failed to read token")))
+
+			tokenFile := filepath.Join(tmpDir, "token")
+			Expect(os.WriteFile(tokenFile, []byte("\n"), 0600)).To(Succeed())
+			_, _, err = serveToken(tokenFile)
+			Expect(err).To(MatchError(ContainSubstring("is empty")))
+		})
+	})
+})
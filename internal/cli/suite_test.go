@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"path/filepath"
 	"testing"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -13,13 +14,23 @@ func TestCLI(t *testing.T) {
 	RunSpecs(t, "CLI Suite")
 }
 
+var _ = BeforeSuite(func() {
+	// Never hand work to a daemon running on this machine
+	socket := filepath.Join(GinkgoT().TempDir(), "none.sock")
+	daemonSocketPath = func() string { return socket }
+})
+
 func resetGlobals() {
 	cfgFile = ""
 	dryRun = false
 	interactive = false
 	explain = false
+	jsonOutput = false
 	verbose = false
 	profile = ""
+	profileSource = ""
+	noHistory = false
+	syncLogLimit = 20
 
 	// Reset flags on rootCmd
 	rootCmd.Flags().VisitAll(func(f *pflag.Flag) {
@@ -3,9 +3,12 @@ package cli
 import (
 	"bytes"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 
 	"github.com/SuzumiyaAoba/via/internal/config"
+	"github.com/SuzumiyaAoba/via/internal/sync"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 )
@@ -57,6 +60,21 @@ var _ = Describe("Sync command", func() {
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("token not found"))
 		})
+		It("should resolve the token with token_command", func() {
+			cfg := &config.Config{
+				Version: "1",
+				Sync: &config.SyncConfig{
+					GistID:       "123",
+					TokenCommand: "echo vault locked >&2; exit 1",
+				},
+			}
+			Expect(config.SaveConfig(cfgFile, cfg)).To(Succeed())
+
+			rootCmd.SetArgs([]string{"--config", cfgFile, ":config", "sync", "push"})
+			err := rootCmd.Execute()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("token_command failed: vault locked"))
+		})
 	})
 
 	Describe("runConfigSyncPull", func() {
@@ -110,17 +128,17 @@ var _ = Describe("Sync command", func() {
 	Describe("runConfigSyncInit", func() {
 		var (
 			originalInputProvider func() (SyncInitInput, error)
-			originalClientFactory func(token string) SyncClient
+			originalClientFactory func(token string) GistCreator
 		)
 
 		BeforeEach(func() {
 			originalInputProvider = getSyncInitInput
-			originalClientFactory = newSyncClient
+			originalClientFactory = newGistCreator
 		})
 
 		AfterEach(func() {
 			getSyncInitInput = originalInputProvider
-			newSyncClient = originalClientFactory
+			newGistCreator = originalClientFactory
 		})
 
 		It("should fail if config load fails", func() {
@@ -145,8 +163,8 @@ var _ = Describe("Sync command", func() {
 			}
 
 			// Mock client
-			newSyncClient = func(token string) SyncClient {
-				return &mockSyncClient{}
+			newGistCreator = func(token string) GistCreator {
+				return &mockGistCreator{}
 			}
 
 			rootCmd.SetArgs([]string{"--config", cfgFile, ":config", "sync", "init"})
@@ -159,6 +177,12 @@ var _ = Describe("Sync command", func() {
 			Expect(err).NotTo(HaveOccurred())
 			Expect(cfg.Sync.GistID).To(Equal("existing-gist-id"))
 			Expect(cfg.Sync.Token).To(Equal("test-token"))
+
+			// The token lives in the credentials file, not in the synced config
+			data, err := os.ReadFile(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).NotTo(ContainSubstring("test-token"))
+			Expect(config.CredentialsPath(cfgFile)).To(BeAnExistingFile())
 		})
 
 		It("should initialize by creating new Gist", func() {
@@ -172,8 +196,8 @@ var _ = Describe("Sync command", func() {
 			}
 
 			// Mock client
-			newSyncClient = func(token string) SyncClient {
-				return &mockSyncClient{
+			newGistCreator = func(token string) GistCreator {
+				return &mockGistCreator{
 					createGistFunc: func(cfg *config.Config, public bool) (string, error) {
 						return "new-gist-id", nil
 					},
@@ -192,32 +216,428 @@ var _ = Describe("Sync command", func() {
 			Expect(cfg.Sync.GistID).To(Equal("new-gist-id"))
 			Expect(cfg.Sync.Token).To(BeEmpty())
 		})
+
+		It("should initialize a directory backend", func() {
+			remoteDir := filepath.Join(tmpDir, "shared")
+			getSyncInitInput = func() (SyncInitInput, error) {
+				return SyncInitInput{
+					Backend:  sync.BackendDir,
+					Location: remoteDir,
+				}, nil
+			}
+
+			rootCmd.SetArgs([]string{"--config", cfgFile, ":config", "sync", "init"})
+			err := rootCmd.Execute()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(outBuf.String()).To(ContainSubstring("Sync initialized successfully"))
+			Expect(outBuf.String()).NotTo(ContainSubstring("Token not stored"))
+
+			cfg, err := config.LoadConfig(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Sync.Backend).To(Equal("dir"))
+			Expect(cfg.Sync.Path).To(Equal(remoteDir))
+		})
+	})
+
+	Describe("directory backend", func() {
+		var remoteDir string
+
+		BeforeEach(func() {
+			remoteDir = filepath.Join(tmpDir, "shared")
+			cfg := &config.Config{
+				Version: "1",
+				Rules:   []config.Rule{{Name: "Local", Command: "cat {{.File}}"}},
+				Sync: &config.SyncConfig{
+					Backend: "dir",
+					Path:    remoteDir,
+				},
+			}
+			err := config.SaveConfig(cfgFile, cfg)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should push without a token", func() {
+			os.Unsetenv("ENTRY_GITHUB_TOKEN")
+
+			rootCmd.SetArgs([]string{"--config", cfgFile, ":config", "sync", "push"})
+			err := rootCmd.Execute()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(outBuf.String()).To(ContainSubstring("Configuration pushed to directory"))
+
+			remote, err := config.LoadConfig(filepath.Join(remoteDir, "config.yml"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(remote.Rules[0].Name).To(Equal("Local"))
+		})
+
+		It("should pull and keep local sync settings", func() {
+			remote := &config.Config{
+				Version: "1",
+				Rules:   []config.Rule{{Name: "Remote", Command: "less {{.File}}"}},
+			}
+			err := config.SaveConfig(filepath.Join(remoteDir, "config.yml"), remote)
+			Expect(err).NotTo(HaveOccurred())
+
+			rootCmd.SetArgs([]string{"--config", cfgFile, ":config", "sync", "pull"})
+			err = rootCmd.Execute()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(outBuf.String()).To(ContainSubstring("Configuration pulled from directory"))
+
+			cfg, err := config.LoadConfig(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Rules[0].Name).To(Equal("Remote"))
+			Expect(cfg.Sync.Path).To(Equal(remoteDir))
+		})
+
+		Context("after a previous sync", func() {
+			var originalResolver func() sync.Resolver
+
+			saveRules := func(path string, rules ...config.Rule) {
+				cfg, err := config.LoadConfig(path)
+				Expect(err).NotTo(HaveOccurred())
+				cfg.Rules = rules
+				Expect(config.SaveConfig(path, cfg)).To(Succeed())
+			}
+
+			BeforeEach(func() {
+				originalResolver = newConflictResolver
+				newConflictResolver = func() sync.Resolver { return nil }
+
+				rootCmd.SetArgs([]string{"--config", cfgFile, ":config", "sync", "push"})
+				Expect(rootCmd.Execute()).To(Succeed())
+				outBuf.Reset()
+			})
+
+			AfterEach(func() {
+				newConflictResolver = originalResolver
+			})
+
+			It("should record the sync state", func() {
+				state, err := sync.LoadState(cfgFile)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(state).NotTo(BeNil())
+				Expect(state.Revision).NotTo(BeEmpty())
+			})
+
+			It("should refuse to push when the remote is ahead", func() {
+				saveRules(filepath.Join(remoteDir, "config.yml"), config.Rule{Name: "Local", Command: "less {{.File}}"})
+
+				rootCmd.SetArgs([]string{"--config", cfgFile, ":config", "sync", "push"})
+				err := rootCmd.Execute()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("vv :config sync pull"))
+			})
+
+			It("should report nothing to pull when only local changed", func() {
+				saveRules(cfgFile, config.Rule{Name: "Local", Command: "vim {{.File}}"})
+
+				rootCmd.SetArgs([]string{"--config", cfgFile, ":config", "sync", "pull"})
+				Expect(rootCmd.Execute()).To(Succeed())
+				Expect(outBuf.String()).To(ContainSubstring("No remote changes"))
+
+				cfg, err := config.LoadConfig(cfgFile)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(cfg.Rules[0].Command).To(Equal("vim {{.File}}"))
+			})
+
+			It("should merge diverged changes on pull", func() {
+				saveRules(cfgFile,
+					config.Rule{Name: "Local", Command: "cat {{.File}}"},
+					config.Rule{Name: "Mine", Command: "vim {{.File}}"})
+				saveRules(filepath.Join(remoteDir, "config.yml"),
+					config.Rule{Name: "Local", Command: "cat {{.File}}"},
+					config.Rule{Name: "Theirs", Command: "less {{.File}}"})
+
+				rootCmd.SetArgs([]string{"--config", cfgFile, ":config", "sync", "pull"})
+				Expect(rootCmd.Execute()).To(Succeed())
+				Expect(outBuf.String()).To(ContainSubstring("Merged remote changes"))
+
+				cfg, err := config.LoadConfig(cfgFile)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(cfg.Rules).To(HaveLen(3))
+				Expect(cfg.Sync.Path).To(Equal(remoteDir))
+
+				// The merge result is ahead of the remote and can be pushed
+				rootCmd.SetArgs([]string{"--config", cfgFile, ":config", "sync", "push"})
+				Expect(rootCmd.Execute()).To(Succeed())
+
+				remote, err := config.LoadConfig(filepath.Join(remoteDir, "config.yml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(remote.Rules).To(HaveLen(3))
+			})
+
+			It("should fail with a diff on conflicting changes", func() {
+				saveRules(cfgFile, config.Rule{Name: "Local", Command: "vim {{.File}}"})
+				saveRules(filepath.Join(remoteDir, "config.yml"), config.Rule{Name: "Local", Command: "less {{.File}}"})
+
+				rootCmd.SetArgs([]string{"--config", cfgFile, ":config", "sync", "pull"})
+				err := rootCmd.Execute()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring(`rule "Local"`))
+				Expect(err.Error()).To(ContainSubstring("- command: vim {{.File}}"))
+				Expect(err.Error()).To(ContainSubstring("+ command: less {{.File}}"))
+
+				cfg, err := config.LoadConfig(cfgFile)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(cfg.Rules[0].Command).To(Equal("vim {{.File}}"))
+			})
+
+			It("should report the sync status", func() {
+				rootCmd.SetArgs([]string{"--config", cfgFile, ":config", "sync", "status"})
+				Expect(rootCmd.Execute()).To(Succeed())
+				Expect(outBuf.String()).To(ContainSubstring("Backend: directory"))
+				Expect(outBuf.String()).To(ContainSubstring("Status: up to date"))
+
+				saveRules(cfgFile, config.Rule{Name: "Local", Command: "vim {{.File}}"})
+				outBuf.Reset()
+				rootCmd.SetArgs([]string{"--config", cfgFile, ":config", "sync", "status"})
+				Expect(rootCmd.Execute()).To(Succeed())
+				Expect(outBuf.String()).To(ContainSubstring("Status: ahead"))
+
+				saveRules(filepath.Join(remoteDir, "config.yml"), config.Rule{Name: "Local", Command: "less {{.File}}"})
+				outBuf.Reset()
+				rootCmd.SetArgs([]string{"--config", cfgFile, ":config", "sync", "status"})
+				Expect(rootCmd.Execute()).To(Succeed())
+				Expect(outBuf.String()).To(ContainSubstring("Status: diverged"))
+			})
+
+			It("should report when the remote is behind", func() {
+				saveRules(filepath.Join(remoteDir, "config.yml"), config.Rule{Name: "Local", Command: "less {{.File}}"})
+
+				rootCmd.SetArgs([]string{"--config", cfgFile, ":config", "sync", "status"})
+				Expect(rootCmd.Execute()).To(Succeed())
+				Expect(outBuf.String()).To(ContainSubstring("Status: behind"))
+			})
+
+			It("should show what a pull would change without applying it", func() {
+				saveRules(filepath.Join(remoteDir, "config.yml"),
+					config.Rule{Name: "Local", Command: "cat {{.File}}"},
+					config.Rule{Name: "Theirs", Command: "less {{.File}}"})
+
+				rootCmd.SetArgs([]string{"--config", cfgFile, ":config", "sync", "pull", "--dry-run"})
+				Expect(rootCmd.Execute()).To(Succeed())
+				Expect(outBuf.String()).To(ContainSubstring("Would pull from directory"))
+				Expect(outBuf.String()).To(ContainSubstring(`+ rule "Theirs"`))
+
+				cfg, err := config.LoadConfig(cfgFile)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(cfg.Rules).To(HaveLen(1))
+			})
+
+			It("should show what a push would change without applying it", func() {
+				saveRules(cfgFile, config.Rule{Name: "Local", Command: "vim {{.File}}"})
+
+				rootCmd.SetArgs([]string{"--config", cfgFile, ":config", "sync", "push", "--dry-run"})
+				Expect(rootCmd.Execute()).To(Succeed())
+				Expect(outBuf.String()).To(ContainSubstring("Would push to directory"))
+				Expect(outBuf.String()).To(ContainSubstring(`~ rule "Local"`))
+				Expect(outBuf.String()).To(ContainSubstring("+ command: vim {{.File}}"))
+
+				remote, err := config.LoadConfig(filepath.Join(remoteDir, "config.yml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(remote.Rules[0].Command).To(Equal("cat {{.File}}"))
+			})
+
+			It("should undo a pull", func() {
+				saveRules(filepath.Join(remoteDir, "config.yml"), config.Rule{Name: "Theirs", Command: "less {{.File}}"})
+
+				rootCmd.SetArgs([]string{"--config", cfgFile, ":config", "sync", "pull"})
+				Expect(rootCmd.Execute()).To(Succeed())
+
+				rootCmd.SetArgs([]string{"--config", cfgFile, ":config", "sync", "undo"})
+				Expect(rootCmd.Execute()).To(Succeed())
+				Expect(outBuf.String()).To(ContainSubstring("Configuration restored from before the pull"))
+
+				cfg, err := config.LoadConfig(cfgFile)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(cfg.Rules[0].Name).To(Equal("Local"))
+
+				// The remote changes are still waiting to be pulled
+				outBuf.Reset()
+				rootCmd.SetArgs([]string{"--config", cfgFile, ":config", "sync", "status"})
+				Expect(rootCmd.Execute()).To(Succeed())
+				Expect(outBuf.String()).To(ContainSubstring("Status: behind"))
+
+				rootCmd.SetArgs([]string{"--config", cfgFile, ":config", "sync", "undo"})
+				Expect(rootCmd.Execute()).To(MatchError(ContainSubstring("nothing to undo")))
+			})
+
+			It("should not list revisions of a directory", func() {
+				rootCmd.SetArgs([]string{"--config", cfgFile, ":config", "sync", "log"})
+				Expect(rootCmd.Execute()).To(MatchError(ContainSubstring("does not keep revisions")))
+			})
+
+			It("should resolve conflicts with the resolver", func() {
+				newConflictResolver = func() sync.Resolver {
+					return func(c sync.Conflict) (sync.Side, error) { return sync.SideRemote, nil }
+				}
+				saveRules(cfgFile, config.Rule{Name: "Local", Command: "vim {{.File}}"})
+				saveRules(filepath.Join(remoteDir, "config.yml"), config.Rule{Name: "Local", Command: "less {{.File}}"})
+
+				rootCmd.SetArgs([]string{"--config", cfgFile, ":config", "sync", "push"})
+				Expect(rootCmd.Execute()).To(Succeed())
+
+				cfg, err := config.LoadConfig(cfgFile)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(cfg.Rules[0].Command).To(Equal("less {{.File}}"))
+			})
+		})
+
+		It("should sync profiles next to the config", func() {
+			profile := filepath.Join(tmpDir, "profiles", "work.yml")
+			Expect(os.MkdirAll(filepath.Dir(profile), 0755)).To(Succeed())
+			Expect(os.WriteFile(profile, []byte("version: \"1\"\n"), 0644)).To(Succeed())
+
+			rootCmd.SetArgs([]string{"--config", cfgFile, ":config", "sync", "push"})
+			Expect(rootCmd.Execute()).To(Succeed())
+			Expect(outBuf.String()).To(ContainSubstring("Synced 1 file(s)"))
+			Expect(filepath.Join(remoteDir, "profiles", "work.yml")).To(BeAnExistingFile())
+
+			// Another machine added a profile and removed ours
+			Expect(os.WriteFile(filepath.Join(remoteDir, "profiles", "home.yml"), []byte("version: \"1\"\n"), 0644)).To(Succeed())
+			Expect(os.Remove(filepath.Join(remoteDir, "profiles", "work.yml"))).To(Succeed())
+
+			rootCmd.SetArgs([]string{"--config", cfgFile, ":config", "sync", "pull"})
+			Expect(rootCmd.Execute()).To(Succeed())
+			Expect(filepath.Join(tmpDir, "profiles", "home.yml")).To(BeAnExistingFile())
+			Expect(profile).NotTo(BeAnExistingFile())
+		})
+
+		It("should not push excluded files or host sections", func() {
+			cfg, err := config.LoadConfig(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+			cfg.DefaultCommand = "xdg-open {{.File}}"
+			cfg.Sync.HostSections = []string{"default_command"}
+			cfg.Sync.Exclude = []string{"scripts/*.local.sh"}
+			Expect(config.SaveConfig(cfgFile, cfg)).To(Succeed())
+
+			scripts := filepath.Join(tmpDir, "scripts")
+			Expect(os.MkdirAll(scripts, 0755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(scripts, "open.sh"), []byte("#!/bin/sh\n"), 0755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(scripts, "work.local.sh"), []byte("#!/bin/sh\n"), 0755)).To(Succeed())
+
+			rootCmd.SetArgs([]string{"--config", cfgFile, ":config", "sync", "push"})
+			Expect(rootCmd.Execute()).To(Succeed())
+
+			Expect(filepath.Join(remoteDir, "scripts", "open.sh")).To(BeAnExistingFile())
+			Expect(filepath.Join(remoteDir, "scripts", "work.local.sh")).NotTo(BeAnExistingFile())
+
+			remote, err := config.LoadConfig(filepath.Join(remoteDir, "config.yml"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(remote.DefaultCommand).To(BeEmpty())
+			Expect(remote.Sync).To(BeNil())
+			Expect(remote.Rules[0].Name).To(Equal("Local"))
+
+			// Pulling keeps the host section even though the remote lacks it
+			rootCmd.SetArgs([]string{"--config", cfgFile, ":config", "sync", "pull"})
+			Expect(rootCmd.Execute()).To(Succeed())
+			cfg, err = config.LoadConfig(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.DefaultCommand).To(Equal("xdg-open {{.File}}"))
+		})
+
+		It("should report an unsynced config", func() {
+			rootCmd.SetArgs([]string{"--config", cfgFile, ":config", "sync", "status"})
+			Expect(rootCmd.Execute()).To(Succeed())
+			Expect(outBuf.String()).To(ContainSubstring("remote is empty"))
+		})
+
+		It("should diff local and remote configs", func() {
+			remote := &config.Config{
+				Version: "1",
+				Rules:   []config.Rule{{Name: "Remote", Command: "less {{.File}}"}},
+			}
+			Expect(config.SaveConfig(filepath.Join(remoteDir, "config.yml"), remote)).To(Succeed())
+
+			rootCmd.SetArgs([]string{"--config", cfgFile, ":config", "sync", "diff"})
+			Expect(rootCmd.Execute()).To(Succeed())
+			Expect(outBuf.String()).To(ContainSubstring("--- local\n+++ directory"))
+			Expect(outBuf.String()).To(ContainSubstring(`- rule "Local"`))
+			Expect(outBuf.String()).To(ContainSubstring(`+ rule "Remote"`))
+		})
+
+		It("should fail if the backend location is missing", func() {
+			cfg := &config.Config{
+				Version: "1",
+				Sync:    &config.SyncConfig{Backend: "dir"},
+			}
+			err := config.SaveConfig(cfgFile, cfg)
+			Expect(err).NotTo(HaveOccurred())
+
+			rootCmd.SetArgs([]string{"--config", cfgFile, ":config", "sync", "pull"})
+			err = rootCmd.Execute()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("sync not initialized"))
+		})
+	})
+
+	Describe("git backend", func() {
+		BeforeEach(func() {
+			if _, err := exec.LookPath("git"); err != nil {
+				Skip("git is not installed")
+			}
+			repo := filepath.Join(tmpDir, "remote.git")
+			out, err := exec.Command("git", "init", "-q", "--bare", repo).CombinedOutput()
+			Expect(err).NotTo(HaveOccurred(), string(out))
+
+			cfg := &config.Config{
+				Version:        "1",
+				DefaultCommand: "first {{.File}}",
+				// Relative to the config file, not to the temporary clone git runs in
+				Sync: &config.SyncConfig{Backend: "git", Repo: "remote.git"},
+			}
+			Expect(config.SaveConfig(cfgFile, cfg)).To(Succeed())
+			rootCmd.SetArgs([]string{"--config", cfgFile, ":config", "sync", "push"})
+			Expect(rootCmd.Execute()).To(Succeed())
+
+			cfg.DefaultCommand = "second {{.File}}"
+			Expect(config.SaveConfig(cfgFile, cfg)).To(Succeed())
+			rootCmd.SetArgs([]string{"--config", cfgFile, ":config", "sync", "push"})
+			Expect(rootCmd.Execute()).To(Succeed())
+			outBuf.Reset()
+		})
+
+		It("should list revisions and check out an old one", func() {
+			rootCmd.SetArgs([]string{"--config", cfgFile, ":config", "sync", "log"})
+			Expect(rootCmd.Execute()).To(Succeed())
+
+			lines := strings.Split(strings.TrimSpace(outBuf.String()), "\n")
+			Expect(lines[0]).To(HavePrefix("* "))
+			Expect(lines[1]).To(HavePrefix("  "))
+			Expect(outBuf.String()).To(ContainSubstring("* last synced revision"))
+			first := strings.Fields(lines[1])[0]
+
+			outBuf.Reset()
+			rootCmd.SetArgs([]string{"--config", cfgFile, ":config", "sync", "checkout", first})
+			Expect(rootCmd.Execute()).To(Succeed())
+			Expect(outBuf.String()).To(ContainSubstring("Configuration restored from revision " + first))
+
+			cfg, err := config.LoadConfig(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.DefaultCommand).To(Equal("first {{.File}}"))
+			Expect(cfg.Sync.Backend).To(Equal("git"))
+
+			rootCmd.SetArgs([]string{"--config", cfgFile, ":config", "sync", "undo"})
+			Expect(rootCmd.Execute()).To(Succeed())
+			cfg, err = config.LoadConfig(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.DefaultCommand).To(Equal("second {{.File}}"))
+		})
+
+		It("should limit the listed revisions", func() {
+			rootCmd.SetArgs([]string{"--config", cfgFile, ":config", "sync", "log", "-n", "1"})
+			Expect(rootCmd.Execute()).To(Succeed())
+			Expect(strings.Count(outBuf.String(), "Update via configuration")).To(Equal(1))
+		})
 	})
 })
 
-type mockSyncClient struct {
+type mockGistCreator struct {
 	createGistFunc func(cfg *config.Config, public bool) (string, error)
-	updateGistFunc func(gistID string, cfg *config.Config) error
-	getGistFunc    func(gistID string) (*config.Config, error)
 }
 
-func (m *mockSyncClient) CreateGist(cfg *config.Config, public bool) (string, error) {
+func (m *mockGistCreator) CreateGist(cfg *config.Config, public bool) (string, error) {
 	if m.createGistFunc != nil {
 		return m.createGistFunc(cfg, public)
 	}
 	return "mock-gist-id", nil
 }
-
-func (m *mockSyncClient) UpdateGist(gistID string, cfg *config.Config) error {
-	if m.updateGistFunc != nil {
-		return m.updateGistFunc(gistID, cfg)
-	}
-	return nil
-}
-
-func (m *mockSyncClient) GetGist(gistID string) (*config.Config, error) {
-	if m.getGistFunc != nil {
-		return m.getGistFunc(gistID)
-	}
-	return &config.Config{}, nil
-}
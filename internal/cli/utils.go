@@ -1,8 +1,12 @@
 package cli
 
 import (
+	"encoding/json"
 	"net/url"
 	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
 )
 
 // File and URL detection helpers
@@ -23,3 +27,20 @@ func fileExists(filename string) bool {
 func isFileOrURL(filename string) bool {
 	return isURL(filename) || fileExists(filename)
 }
+
+// isTruthy reports whether an environment variable value means "enabled"
+func isTruthy(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "1", "true", "yes", "on":
+		return true
+	}
+	return false
+}
+
+// printJSON writes v as indented JSON for scripts and editor integrations
+func printJSON(cmd *cobra.Command, v any) error {
+	enc := json.NewEncoder(cmd.OutOrStdout())
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(v)
+}
@@ -60,4 +60,18 @@ var _ = Describe("Utils", func() {
 			Expect(isFileOrURL("nonexistent.txt")).To(BeFalse())
 		})
 	})
+
+	Describe("isTruthy", func() {
+		It("should accept common true values", func() {
+			Expect(isTruthy("1")).To(BeTrue())
+			Expect(isTruthy("true")).To(BeTrue())
+			Expect(isTruthy("YES")).To(BeTrue())
+		})
+
+		It("should reject other values", func() {
+			Expect(isTruthy("")).To(BeFalse())
+			Expect(isTruthy("0")).To(BeFalse())
+			Expect(isTruthy("false")).To(BeFalse())
+		})
+	})
 })
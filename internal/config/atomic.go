@@ -0,0 +1,234 @@
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// LockTimeout is how long a writer waits for another process to finish changing a config file
+var LockTimeout = 5 * time.Second
+
+// lockPath returns the lock file guarding a config file. It is hidden so that
+// sync and the profile list ignore it.
+func lockPath(configPath string) string {
+	return filepath.Join(filepath.Dir(configPath), "."+filepath.Base(configPath)+".lock")
+}
+
+// Lock takes an exclusive lock on a config file so that concurrent writers, such
+// as the CLI and an open dashboard, cannot interleave. It waits up to LockTimeout
+// and returns a function releasing the lock.
+func Lock(configPath string) (func(), error) {
+	f, err := os.OpenFile(lockPath(configPath), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	deadline := time.Now().Add(LockTimeout)
+	for {
+		locked, err := lockFile(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", configPath, err)
+		}
+		if locked {
+			break
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("%s is being changed by another vv process, try again", configPath)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
+
+// withLock creates the directory of a config file and calls fn with its path
+// while holding its lock
+func withLock(path string, fn func(configPath string) error) error {
+	configPath, err := GetConfigPath(path)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	unlock, err := Lock(configPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return fn(configPath)
+}
+
+// UpdateConfig loads a config file, lets fn change it and saves the result,
+// holding the lock from the read to the write so that concurrent updates are
+// never lost. A missing file starts out as an empty config. Nothing is written
+// if fn returns an error.
+func UpdateConfig(path string, fn func(cfg *Config) error) error {
+	return withLock(path, func(configPath string) error {
+		cfg, err := LoadConfig(configPath)
+		if errors.Is(err, ErrNotFound) {
+			cfg, err = &Config{Version: CurrentVersion}, nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(cfg); err != nil {
+			return err
+		}
+		return saveLocked(configPath, cfg)
+	})
+}
+
+// ErrConfigChanged is returned by SaveConfigFingerprint when the file was
+// changed by someone else after it was loaded
+var ErrConfigChanged = errors.New("config file was changed by another process")
+
+// Fingerprint identifies the content of a config file, empty for a missing file
+type Fingerprint string
+
+func fingerprint(data []byte) Fingerprint {
+	if data == nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return Fingerprint(hex.EncodeToString(sum[:]))
+}
+
+func readFingerprint(configPath string) (Fingerprint, error) {
+	data, err := os.ReadFile(configPath)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read config file: %w", err)
+	}
+	return fingerprint(data), nil
+}
+
+// LoadConfigFingerprint loads a config like LoadConfig and returns the
+// fingerprint of the file it was read from, for SaveConfigFingerprint. It is
+// meant for editors that keep a config while the user works on it, where
+// holding the lock as UpdateConfig does is not an option.
+func LoadConfigFingerprint(path string) (*Config, Fingerprint, error) {
+	cfg, raw, err := loadConfig(path)
+	if err != nil {
+		return nil, "", err
+	}
+	return cfg, fingerprint(raw), nil
+}
+
+// SaveConfigFingerprint saves a config like SaveConfig, unless the file no
+// longer has the fingerprint it was loaded with, in which case it fails with
+// ErrConfigChanged instead of overwriting the other change. It returns the
+// fingerprint of the saved file.
+func SaveConfigFingerprint(path string, cfg *Config, loaded Fingerprint) (Fingerprint, error) {
+	var saved Fingerprint
+	err := withLock(path, func(configPath string) error {
+		current, err := readFingerprint(configPath)
+		if err != nil {
+			return err
+		}
+		if current != loaded {
+			return fmt.Errorf("%w since it was loaded, reload it and try again: %s", ErrConfigChanged, configPath)
+		}
+		if err := saveLocked(configPath, cfg); err != nil {
+			return err
+		}
+		saved, err = readFingerprint(configPath)
+		return err
+	})
+	return saved, err
+}
+
+// WriteFileAtomic replaces a file so that readers and crashes see either the old
+// or the new content, never a partial write: the data goes to a temporary file in
+// the same directory, is synced to disk and then renamed over the target.
+// Symlinks are followed so that a config managed by a dotfiles repository stays linked.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		path = target
+	}
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // Fails harmlessly once the file has been renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	// Sync the directory too, so the rename itself survives a crash
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// WriteConfigFile replaces a config file with data while holding its lock,
+// keeping the previous content in BackupDir
+func WriteConfigFile(configPath string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	unlock, err := Lock(configPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return writeLocked(configPath, data)
+}
+
+// writeLocked backs up and replaces a config file; the caller holds its lock
+func writeLocked(configPath string, data []byte) error {
+	existing, err := os.ReadFile(configPath)
+	if err == nil && bytes.Equal(existing, data) {
+		return nil
+	}
+
+	// Keep the file as written by an older schema version before replacing it
+	if _, err := BackupOutdated(configPath); err != nil {
+		return err
+	}
+	if _, err := SaveBackup(configPath); err != nil {
+		return err
+	}
+
+	if err := WriteFileAtomic(configPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Hostname is a variable to allow mocking in tests
+var Hostname = os.Hostname
+
+// GitRemotes returns the remote URLs of the git repository enclosing dir.
+// It is a variable to allow mocking in tests.
+var GitRemotes = func(dir string) []string {
+	out, err := exec.Command("git", "-C", dir, "config", "--get-regexp", `^remote\..*\.url$`).Output()
+	if err != nil {
+		return nil
+	}
+
+	var urls []string
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if _, url, ok := strings.Cut(line, " "); ok {
+			urls = append(urls, url)
+		}
+	}
+	return urls
+}
+
+// MatchAutoProfile returns the profile of the first entry matching dir and the
+// current environment, or "" if none matches.
+func MatchAutoProfile(entries []AutoProfile, dir string) string {
+	for _, entry := range entries {
+		if entry.Matches(dir) {
+			return entry.Profile
+		}
+	}
+	return ""
+}
+
+// Matches reports whether all conditions of the entry hold for dir. An entry without conditions never matches.
+func (a *AutoProfile) Matches(dir string) bool {
+	if a.Dir == "" && a.GitRemote == "" && a.Hostname == "" && a.Env == "" {
+		return false
+	}
+	if a.Env != "" && !matchEnv(a.Env) {
+		return false
+	}
+	if a.Hostname != "" {
+		host, err := Hostname()
+		if err != nil {
+			return false
+		}
+		if ok, _ := filepath.Match(a.Hostname, host); !ok {
+			return false
+		}
+	}
+	if a.Dir != "" && !matchDir(a.Dir, dir) {
+		return false
+	}
+	if a.GitRemote != "" && !matchRemote(a.GitRemote, GitRemotes(dir)) {
+		return false
+	}
+	return true
+}
+
+func matchEnv(cond string) bool {
+	name, want, hasValue := strings.Cut(cond, "=")
+	value := os.Getenv(name)
+	if hasValue {
+		return value == want
+	}
+	return value != ""
+}
+
+// matchDir matches the pattern against dir and each of its parents, so "~/work/*"
+// also matches directories nested anywhere inside a project under ~/work
+func matchDir(pattern, dir string) bool {
+	if strings.HasPrefix(pattern, "~/") {
+		home, err := UserHomeDir()
+		if err != nil {
+			return false
+		}
+		pattern = filepath.Join(home, pattern[2:])
+	}
+	pattern = filepath.Clean(pattern)
+
+	for d := filepath.Clean(dir); ; d = filepath.Dir(d) {
+		if ok, _ := filepath.Match(pattern, d); ok {
+			return true
+		}
+		if parent := filepath.Dir(d); parent == d {
+			return false
+		}
+	}
+}
+
+// matchRemote matches the pattern against remote URLs. "*" matches any characters,
+// including "/" and ":", so "*github.com*myorg/*" covers both SSH and HTTPS URLs.
+func matchRemote(pattern string, urls []string) bool {
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.ReplaceAll(expr, `\*`, ".*")
+	expr = strings.ReplaceAll(expr, `\?`, ".")
+	re, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return false
+	}
+
+	for _, url := range urls {
+		if re.MatchString(url) {
+			return true
+		}
+	}
+	return false
+}
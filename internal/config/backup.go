@@ -0,0 +1,118 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// MaxBackups is the number of previous versions kept for each config file
+const MaxBackups = 10
+
+const backupTimeFormat = "20060102T150405.000000000"
+
+// Backup is a previous version of a config file kept in BackupDir
+type Backup struct {
+	Path    string
+	TakenAt time.Time
+}
+
+// backupPrefix is the start of the backup file names of a config file
+func backupPrefix(configPath string) string {
+	return strings.TrimSuffix(filepath.Base(configPath), filepath.Ext(configPath)) + "."
+}
+
+// ListBackups returns the backups of a config file, newest first
+func ListBackups(configPath string) ([]Backup, error) {
+	dir := BackupDir(configPath)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	prefix := backupPrefix(configPath)
+	var backups []Backup
+	for _, e := range entries {
+		name := e.Name()
+		stem, ok := TrimConfigExt(name)
+		if e.IsDir() || !ok || !strings.HasPrefix(stem, prefix) {
+			continue
+		}
+		// Skips the backups made by migrations, whose names hold a version instead
+		takenAt, err := time.Parse(backupTimeFormat, strings.TrimPrefix(stem, prefix))
+		if err != nil {
+			continue
+		}
+		backups = append(backups, Backup{Path: filepath.Join(dir, name), TakenAt: takenAt})
+	}
+	slices.SortFunc(backups, func(a, b Backup) int { return b.TakenAt.Compare(a.TakenAt) })
+	return backups, nil
+}
+
+// SaveBackup copies the current content of a config file into BackupDir before it
+// is replaced and removes all but the newest MaxBackups. It returns the backup
+// path, or "" if the file does not exist or the newest backup already holds it.
+func SaveBackup(configPath string) (string, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to read config for backup: %w", err)
+	}
+
+	backups, err := ListBackups(configPath)
+	if err != nil {
+		return "", err
+	}
+	if len(backups) > 0 {
+		if latest, err := os.ReadFile(backups[0].Path); err == nil && bytes.Equal(latest, data) {
+			return "", nil
+		}
+	}
+
+	dir := BackupDir(configPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	name := backupPrefix(configPath) + time.Now().UTC().Format(backupTimeFormat) + FormatOf(configPath).Ext()
+	path := filepath.Join(dir, name)
+	if err := WriteFileAtomic(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write backup: %w", err)
+	}
+
+	backups = append([]Backup{{Path: path}}, backups...)
+	for _, old := range backups[min(len(backups), MaxBackups):] {
+		if err := os.Remove(old.Path); err != nil && !os.IsNotExist(err) {
+			return "", fmt.Errorf("failed to remove old backup: %w", err)
+		}
+	}
+	return path, nil
+}
+
+// RestoreBackup replaces a config file with one of its backups, converting it if
+// the config has been converted to another format since. The current content is
+// backed up first, so a restore can itself be undone.
+func RestoreBackup(configPath, backupPath string) error {
+	data, err := os.ReadFile(backupPath)
+	if err != nil {
+		return fmt.Errorf("failed to read backup: %w", err)
+	}
+	cfg, err := Decode(data, FormatOf(backupPath))
+	if err != nil {
+		return fmt.Errorf("backup %s is not a valid config: %w", backupPath, err)
+	}
+	if format := FormatOf(configPath); format != FormatOf(backupPath) {
+		if data, err = Encode(cfg, format); err != nil {
+			return err
+		}
+	}
+	return WriteConfigFile(configPath, data)
+}
@@ -1,10 +1,12 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
 	"gopkg.in/yaml.v3"
@@ -23,49 +25,214 @@ type Rule struct {
 	Command     string            `yaml:"command,omitempty" validate:"required"`
 	Script      string            `yaml:"script,omitempty"` // JavaScript code
 	Env         map[string]string `yaml:"env,omitempty"`    // Environment variables
+	History     *bool             `yaml:"history,omitempty"` // Set to false to never record matches in history
+	Merge       string            `yaml:"merge,omitempty" validate:"omitempty,oneof=prepend append override"` // How the rule is layered over inherited rules: prepend, append or override
+	Tests       []RuleTest        `yaml:"tests,omitempty" validate:"dive"` // Expected matches checked by :config test
+	Source      *Source           `yaml:"-"` // Where the rule was loaded from, nil for rules built in code
+}
+
+// RuleTest is an input and the rule expected to handle it
+type RuleTest struct {
+	Input      string `yaml:"input" validate:"required"`
+	Expect     string `yaml:"expect,omitempty"`                                      // Name of the expected rule, the rule the test belongs to if empty
+	ExpectNone bool   `yaml:"expect_none,omitempty" validate:"excluded_with=Expect"` // Set to true if no rule should match
+}
+
+// Source is the position of a rule in a config file
+type Source struct {
+	File string
+	Line int
+}
+
+func (s *Source) String() string {
+	if s.Line == 0 {
+		return s.File
+	}
+	return fmt.Sprintf("%s:%d", s.File, s.Line)
+}
+
+// Label describes the rule for error messages, with its source position when known
+func (r *Rule) Label() string {
+	label := "unnamed rule"
+	if r.Name != "" {
+		label = fmt.Sprintf("rule %q", r.Name)
+	}
+	if r.Source != nil {
+		label = fmt.Sprintf("%s: %s", r.Source, label)
+	}
+	return label
+}
+
+// RecordsHistory reports whether executions of this rule may be written to history
+func (r *Rule) RecordsHistory() bool {
+	return r.History == nil || *r.History
 }
 
 type Config struct {
 	Version        string            `yaml:"version"`
+	Extends        []string          `yaml:"extends,omitempty"` // Configs this one is layered on: "default", profile names or paths
+	Include        []string          `yaml:"include,omitempty"` // Files or globs whose rules are appended to this file's rules
 	DefaultCommand string            `yaml:"default_command,omitempty"`
 	Default        string            `yaml:"default,omitempty"` // Shorter alias for DefaultCommand
 	Aliases        map[string]string `yaml:"aliases,omitempty"`
 	Rules          []Rule            `yaml:"rules" validate:"dive"`
 	Sync           *SyncConfig       `yaml:"sync,omitempty"`
+	History        *HistoryConfig    `yaml:"history,omitempty"`
+	Profiles       *ProfilesConfig   `yaml:"profiles,omitempty"`
+	Raw            *Config           `yaml:"-"` // The config before Expand, nil if it was not expanded
+}
+
+type HistoryConfig struct {
+	Exclude []string `yaml:"exclude,omitempty"` // Glob patterns for paths that are never recorded
+}
+
+type ProfilesConfig struct {
+	Auto         []AutoProfile `yaml:"auto,omitempty" validate:"dive"` // Profiles selected by where vv runs, first match wins
+	ProjectFiles bool          `yaml:"project_files,omitempty"` // Layer .via.yml files found above the opened file over the config
+}
+
+// AutoProfile selects a profile when all of its conditions match
+type AutoProfile struct {
+	Profile   string `yaml:"profile" validate:"required"`
+	Dir       string `yaml:"dir,omitempty"`        // Glob matched against the directory and its parents, e.g. ~/work/*
+	GitRemote string `yaml:"git_remote,omitempty"` // Glob matched against the remote URLs of the enclosing git repository
+	Hostname  string `yaml:"hostname,omitempty"`   // Glob matched against the hostname
+	Env       string `yaml:"env,omitempty"`        // NAME (set and not empty) or NAME=value
 }
 
 type SyncConfig struct {
-	GistID string `yaml:"gist_id,omitempty"`
-	Token  string `yaml:"token,omitempty"` // Optional: usually passed via env var or flag, but can be stored
+	Backend      string   `yaml:"backend,omitempty"` // gist (default), git, dir or webdav
+	GistID       string   `yaml:"gist_id,omitempty"`
+	Token        string   `yaml:"token,omitempty"`         // Loaded from the credentials file (or legacy configs), never written back
+	TokenCommand string   `yaml:"token_command,omitempty"` // Command printing the token, e.g. "pass show github/via"
+	Repo         string   `yaml:"repo,omitempty"`          // git: repository URL, local path or bare repository
+	Branch       string   `yaml:"branch,omitempty"`        // git: branch to sync (default main)
+	Path         string   `yaml:"path,omitempty"`          // dir: local or shared directory
+	URL          string   `yaml:"url,omitempty"`           // webdav: URL of the config file or collection
+	Username     string   `yaml:"username,omitempty"`      // webdav: basic auth user (token is the password)
+	Include      []string `yaml:"include,omitempty"`       // Extra files to sync, e.g. profiles, scripts, history.json (default profiles and scripts)
+	Exclude      []string `yaml:"exclude,omitempty"`       // Glob patterns of extra files that are never synced
+	HostSections []string `yaml:"host_sections,omitempty"` // Top-level config sections kept on this machine only
+	Auto         string   `yaml:"auto,omitempty"`          // pull-on-start, push-on-change, or both separated by commas
+	Interval     int      `yaml:"interval,omitempty"`      // Minutes between remote checks for pull-on-start (default 15)
 }
 
+// BackendName returns the configured sync backend, defaulting to gist
+func (s *SyncConfig) BackendName() string {
+	if s.Backend == "" {
+		return "gist"
+	}
+	return s.Backend
+}
+
+// AutoEnabled reports whether the given automatic sync mode is enabled
+func (s *SyncConfig) AutoEnabled(mode string) bool {
+	for _, m := range strings.Split(s.Auto, ",") {
+		if strings.TrimSpace(m) == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrNotFound is returned when loading a config file that does not exist
+var ErrNotFound = errors.New("config file not found")
+
 func LoadConfig(path string) (*Config, error) {
+	cfg, _, err := loadConfig(path)
+	return cfg, err
+}
+
+// loadConfig loads a config and also returns the file content it was read from
+func loadConfig(path string) (*Config, []byte, error) {
 	configPath, err := GetConfigPath(path)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	data, err := os.ReadFile(configPath)
+	raw, err := os.ReadFile(configPath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			// Return empty config if file doesn't exist, or maybe error?
-			// For now, let's return an error so the user knows they need a config.
-			return nil, fmt.Errorf("config file not found at %s", configPath)
+			// Return an error so the user knows they need a config
+			return nil, nil, fmt.Errorf("%w at %s", ErrNotFound, configPath)
 		}
-		return nil, fmt.Errorf("failed to read config file: %w", err)
+		return nil, nil, fmt.Errorf("failed to read config file: %w", err)
 	}
+	cfg, err := parseConfigFile(configPath, raw)
+	return cfg, raw, err
+}
 
-	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
+func parseConfigFile(configPath string, data []byte) (*Config, error) {
+	format := FormatOf(configPath)
+	data, err := ToYAML(data, format)
+	if err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
+	cfg, root, err := decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	setRuleSources(cfg, configPath, root, format == FormatYAML)
 
-	// If 'default' is set, use it as DefaultCommand (unless DefaultCommand is already set)
-	if cfg.Default != "" && cfg.DefaultCommand == "" {
-		cfg.DefaultCommand = cfg.Default
+	// Tokens live in the credentials file; a token in the config itself is a legacy setup
+	if cfg.Sync != nil && cfg.Sync.Token == "" {
+		creds, err := LoadCredentials(configPath)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Sync.Token = creds.SyncToken
+	}
+
+	return cfg, nil
+}
+
+// Parse decodes a config document, migrating it to the current schema version
+func Parse(data []byte) (*Config, error) {
+	cfg, _, err := decode(data)
+	return cfg, err
+}
+
+func decode(data []byte) (*Config, *yaml.Node, error) {
+	root, _, err := MigrateDocument(data)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return &cfg, nil
+	var cfg Config
+	if err := root.Decode(&cfg); err != nil {
+		return nil, nil, err
+	}
+	cfg.Normalize()
+	return &cfg, root, nil
+}
+
+// setRuleSources records the file and line each rule was read from. Lines are
+// only known for YAML files, other formats are converted before decoding.
+func setRuleSources(cfg *Config, configPath string, root *yaml.Node, withLines bool) {
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return
+	}
+
+	rules := mappingValue(root.Content[0], "rules")
+	if rules == nil {
+		return
+	}
+	for i, item := range rules.Content {
+		if i < len(cfg.Rules) {
+			cfg.Rules[i].Source = &Source{File: configPath}
+			if withLines {
+				cfg.Rules[i].Source.Line = item.Line
+			}
+		}
+	}
+}
+
+// Normalize fills derived fields after a config has been decoded
+func (c *Config) Normalize() {
+	// If 'default' is set, use it as DefaultCommand (unless DefaultCommand is already set)
+	if c.Default != "" && c.DefaultCommand == "" {
+		c.DefaultCommand = c.Default
+	}
 }
 
 func GetConfigPath(path string) (string, error) {
@@ -88,56 +255,97 @@ func GetConfigPathWithProfile(path string, profile string) (string, error) {
 	}
 
 	if profile == "" {
-		return filepath.Join(home, ".config", "via", "config.yml"), nil
+		return findConfigFile(filepath.Join(home, ".config", "via", "config")), nil
 	}
 
-	return filepath.Join(home, ".config", "via", "profiles", profile+".yml"), nil
+	return findConfigFile(filepath.Join(home, ".config", "via", "profiles", profile)), nil
 }
 
 func SaveConfig(path string, cfg *Config) error {
-	configPath, err := GetConfigPath(path)
-	if err != nil {
-		return err
+	return withLock(path, func(configPath string) error {
+		return saveLocked(configPath, cfg)
+	})
+}
+
+// saveLocked writes cfg to a config file; the caller holds its lock
+func saveLocked(configPath string, cfg *Config) error {
+	// The token is never marshalled, so keep it in the credentials file instead
+	if cfg.Sync != nil && cfg.Sync.Token != "" {
+		creds, err := LoadCredentials(configPath)
+		if err != nil {
+			return err
+		}
+		if creds.SyncToken != cfg.Sync.Token {
+			creds.SyncToken = cfg.Sync.Token
+			if err := SaveCredentials(configPath, creds); err != nil {
+				return err
+			}
+		}
 	}
 
-	data, err := yaml.Marshal(cfg)
+	var data []byte
+	var err error
+	if format := FormatOf(configPath); format == FormatYAML {
+		// Apply the changes to the existing document so comments and layout survive
+		existing, readErr := os.ReadFile(configPath)
+		if readErr != nil && !os.IsNotExist(readErr) {
+			return fmt.Errorf("failed to read config file: %w", readErr)
+		}
+		data, err = UpdateDocument(existing, cfg)
+	} else {
+		data, err = Encode(cfg, format)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 
-	// Ensure directory exists
-	dir := filepath.Dir(configPath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create config directory: %w", err)
-	}
+	return writeLocked(configPath, data)
+}
 
-	if err := os.WriteFile(configPath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write config file: %w", err)
+// ValidateConfig checks cfg against the schema and returns every violation,
+// joined into one error. Lint also reports problems beyond the schema.
+func ValidateConfig(cfg *Config) error {
+	if err := newValidator().Struct(cfg); err != nil {
+		validationErrors, ok := err.(validator.ValidationErrors)
+		if !ok {
+			return err
+		}
+		// Simplify error messages for the user
+		errs := make([]error, len(validationErrors))
+		for i, e := range validationErrors {
+			// e.Namespace() gives full path like Config.Rules[0].Command
+			if rule := failedRule(cfg, e.Namespace()); rule != nil && rule.Source != nil {
+				errs[i] = fmt.Errorf("validation failed: %s: %s is %s", rule.Label(), e.Namespace(), e.Tag())
+			} else {
+				errs[i] = fmt.Errorf("validation failed: %s is %s", e.Namespace(), e.Tag())
+			}
+		}
+		return errors.Join(errs...)
 	}
-
 	return nil
 }
 
-func ValidateConfig(cfg *Config) error {
+func newValidator() *validator.Validate {
 	validate := validator.New()
-	
+
 	// Register custom validation for regex
 	validate.RegisterValidation("is-regex", func(fl validator.FieldLevel) bool {
 		_, err := regexp.Compile(fl.Field().String())
 		return err == nil
 	})
+	return validate
+}
 
-	if err := validate.Struct(cfg); err != nil {
-		if validationErrors, ok := err.(validator.ValidationErrors); ok {
-			// Simplify error message for the user
-			for _, e := range validationErrors {
-				// e.Namespace() gives full path like Config.Rules[0].Command
-				return fmt.Errorf("validation failed: %s is %s", e.Namespace(), e.Tag())
-			}
-		}
-		return err
+// failedRule returns the rule a validation error namespace like Config.Rules[2].Command points at
+func failedRule(cfg *Config, namespace string) *Rule {
+	var index int
+	if _, err := fmt.Sscanf(namespace, "Config.Rules[%d]", &index); err != nil {
+		return nil
 	}
-	return nil
+	if index < 0 || index >= len(cfg.Rules) {
+		return nil
+	}
+	return &cfg.Rules[index]
 }
 
 // ValidateRegex validates a regex pattern
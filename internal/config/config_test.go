@@ -1,14 +1,25 @@
 package config
 
 import (
+	"flag"
+	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime"
+	"strings"
+	gosync "sync"
 	"testing"
+	"text/template"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v3"
 )
 
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata")
+
 func TestConfig(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Config Suite")
@@ -32,7 +43,7 @@ var _ = Describe("Config", func() {
 
 			cfg, err := LoadConfig(cfgFile)
 			Expect(err).NotTo(HaveOccurred())
-			Expect(cfg.Version).To(Equal("1"))
+			Expect(cfg.Version).To(Equal(CurrentVersion)) // Migrated on load
 		})
 
 		It("should return error if file does not exist", func() {
@@ -114,6 +125,17 @@ default: "vim {{.File}}"
 			Expect(err.Error()).To(ContainSubstring("Mime"))
 			Expect(err.Error()).To(ContainSubstring("is-regex"))
 		})
+
+		It("should fail if a test expects a rule and no rule at once", func() {
+			cfg := &Config{
+				Version: "1",
+				Rules: []Rule{
+					{Name: "Rule", Command: "cmd", Tests: []RuleTest{{Input: "a.txt", Expect: "Rule", ExpectNone: true}}},
+				},
+			}
+			err := ValidateConfig(cfg)
+			Expect(err).To(MatchError(ContainSubstring("Config.Rules[0].Tests[0].ExpectNone is excluded_with")))
+		})
 	})
 
 	Describe("GetConfigPathWithProfile", func() {
@@ -169,6 +191,1052 @@ default: "vim {{.File}}"
 		})
 	})
 
+	Describe("Credentials", func() {
+		It("should store the sync token outside the config file", func() {
+			cfg := &Config{Version: "1", Sync: &SyncConfig{GistID: "123", Token: "secret"}}
+			Expect(SaveConfig(cfgFile, cfg)).To(Succeed())
+
+			data, err := os.ReadFile(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).NotTo(ContainSubstring("secret"))
+			Expect(string(data)).To(ContainSubstring("gist_id"))
+
+			info, err := os.Stat(CredentialsPath(cfgFile))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+
+			loaded, err := LoadConfig(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loaded.Sync.Token).To(Equal("secret"))
+		})
+
+		It("should move a legacy token out of the config file on save", func() {
+			err := os.WriteFile(cfgFile, []byte("version: '1'\nsync:\n  gist_id: '123'\n  token: legacy\n"), 0644)
+			Expect(err).NotTo(HaveOccurred())
+
+			cfg, err := LoadConfig(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Sync.Token).To(Equal("legacy"))
+			Expect(SaveConfig(cfgFile, cfg)).To(Succeed())
+
+			data, err := os.ReadFile(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).NotTo(ContainSubstring("legacy"))
+
+			creds, err := LoadCredentials(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(creds.SyncToken).To(Equal("legacy"))
+		})
+
+		It("should keep credentials per config file", func() {
+			Expect(CredentialsPath(filepath.Join(tmpDir, "profiles", "work.yml"))).
+				To(Equal(filepath.Join(tmpDir, "profiles", "credentials", "work.yml")))
+
+			creds, err := LoadCredentials(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(creds.SyncToken).To(BeEmpty())
+		})
+	})
+
+	Describe("ResolveConfig", func() {
+		var home string
+
+		write := func(path, content string) {
+			Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+			Expect(os.WriteFile(path, []byte(content), 0644)).To(Succeed())
+		}
+
+		BeforeEach(func() {
+			home = tmpDir
+			origHome := UserHomeDir
+			UserHomeDir = func() (string, error) { return home, nil }
+			DeferCleanup(func() { UserHomeDir = origHome })
+
+			write(filepath.Join(home, ".config", "via", "config.yml"), `
+version: "1"
+default_command: open {{.File}}
+aliases: {pdf: PDF}
+rules:
+  - name: PDF
+    extensions: [pdf]
+    command: evince {{.File}}
+  - name: Text
+    extensions: [txt]
+    command: less {{.File}}
+`)
+		})
+
+		profilePath := func(name string) string {
+			return filepath.Join(home, ".config", "via", "profiles", name+".yml")
+		}
+
+		ruleNames := func(cfg *Config) []string {
+			var names []string
+			for _, r := range cfg.Rules {
+				names = append(names, r.Name)
+			}
+			return names
+		}
+
+		It("should layer rules, aliases and the default command", func() {
+			write(profilePath("work"), `
+version: "1"
+extends: [default]
+aliases: {md: Markdown}
+rules:
+  - name: Markdown
+    extensions: [md]
+    command: glow {{.File}}
+  - name: Text
+    command: vim {{.File}}
+  - name: Archive
+    extensions: [zip]
+    command: unzip -l {{.File}}
+    merge: append
+`)
+
+			cfg, err := LoadResolvedConfig(profilePath("work"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ruleNames(cfg)).To(Equal([]string{"Markdown", "PDF", "Text", "Archive"}))
+			Expect(cfg.Rules[2].Command).To(Equal("vim {{.File}}"))
+			Expect(cfg.Rules[3].Merge).To(BeEmpty())
+			Expect(cfg.Aliases).To(Equal(map[string]string{"pdf": "PDF", "md": "Markdown"}))
+			Expect(cfg.DefaultCommand).To(Equal("open {{.File}}"))
+			Expect(cfg.Extends).To(BeEmpty())
+		})
+
+		It("should layer multiple parents in order", func() {
+			write(profilePath("base-dev"), `
+version: "1"
+default_command: code {{.File}}
+rules:
+  - name: Go
+    extensions: [go]
+    command: code {{.File}}
+  - name: PDF
+    command: zathura {{.File}}
+    merge: append
+`)
+			write(profilePath("work"), `
+version: "1"
+extends: [default, base-dev]
+rules:
+  - name: Go
+    command: vim {{.File}}
+    merge: override
+`)
+
+			cfg, err := LoadResolvedConfig(profilePath("work"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ruleNames(cfg)).To(Equal([]string{"Go", "Text", "PDF"}))
+			Expect(cfg.Rules[0].Command).To(Equal("vim {{.File}}"))
+			Expect(cfg.Rules[2].Command).To(Equal("zathura {{.File}}"))
+			Expect(cfg.DefaultCommand).To(Equal("code {{.File}}"))
+		})
+
+		It("should resolve paths relative to the extending file", func() {
+			write(filepath.Join(tmpDir, "shared", "base.yml"), `
+version: "1"
+rules:
+  - name: Shared
+    command: cat {{.File}}
+`)
+			write(cfgFile, `
+version: "1"
+extends: [shared/base.yml]
+rules: []
+`)
+
+			cfg, err := LoadResolvedConfig(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ruleNames(cfg)).To(Equal([]string{"Shared"}))
+		})
+
+		It("should detect cycles", func() {
+			write(profilePath("a"), "version: \"1\"\nextends: [b]\nrules: []\n")
+			write(profilePath("b"), "version: \"1\"\nextends: [a]\nrules: []\n")
+
+			_, err := LoadResolvedConfig(profilePath("a"))
+			Expect(err).To(MatchError(ContainSubstring("config inheritance cycle: a -> b -> a")))
+		})
+
+		It("should reject overrides of missing rules", func() {
+			write(profilePath("work"), `
+version: "1"
+extends: [default]
+rules:
+  - name: Missing
+    command: cat {{.File}}
+    merge: override
+`)
+
+			_, err := LoadResolvedConfig(profilePath("work"))
+			Expect(err).To(MatchError(ContainSubstring(`rule "Missing" overrides no inherited rule`)))
+		})
+
+		It("should report missing parents", func() {
+			write(profilePath("work"), "version: \"1\"\nextends: [nope]\nrules: []\n")
+
+			_, err := LoadResolvedConfig(profilePath("work"))
+			Expect(err).To(MatchError(ContainSubstring(`failed to load "nope"`)))
+		})
+	})
+
+	Describe("Includes", func() {
+		write := func(path, content string) {
+			Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+			Expect(os.WriteFile(path, []byte(content), 0644)).To(Succeed())
+		}
+
+		BeforeEach(func() {
+			write(filepath.Join(tmpDir, "conf.d", "20-docs.yml"), `
+version: "1"
+aliases: {md: Markdown}
+rules:
+  - name: Markdown
+    extensions: [md]
+    command: glow {{.File}}
+`)
+			write(filepath.Join(tmpDir, "conf.d", "10-git.yml"), `
+version: "1"
+default_command: less {{.File}}
+rules:
+  - name: Patch
+    extensions: [patch]
+    command: delta {{.File}}
+`)
+		})
+
+		It("should append included rules in a deterministic order", func() {
+			write(cfgFile, `
+version: "1"
+include: [conf.d/*.yml]
+rules:
+  - name: PDF
+    extensions: [pdf]
+    command: evince {{.File}}
+`)
+
+			cfg, err := LoadResolvedConfig(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Include).To(BeEmpty())
+			Expect(cfg.Rules).To(HaveLen(3))
+			Expect(cfg.Rules[0].Name).To(Equal("PDF"))
+			Expect(cfg.Rules[1].Name).To(Equal("Patch"))
+			Expect(cfg.Rules[2].Name).To(Equal("Markdown"))
+			Expect(cfg.Rules[2].Source).To(Equal(&Source{File: filepath.Join(tmpDir, "conf.d", "20-docs.yml"), Line: 5}))
+			Expect(cfg.Aliases).To(HaveKeyWithValue("md", "Markdown"))
+			Expect(cfg.DefaultCommand).To(Equal("less {{.File}}"))
+		})
+
+		It("should list the files and glob directories a config was resolved from", func() {
+			write(filepath.Join(tmpDir, "base.yml"), "version: \"1\"\ninclude: [packs/*/*.yml]\n")
+			write(filepath.Join(tmpDir, "packs", "git", "aliases.yml"), "version: \"1\"\naliases: {g: Git}\n")
+			write(cfgFile, "version: \"1\"\nextends: [base.yml]\ninclude: [conf.d/*.yml]\nrules: []\n")
+
+			_, sources, err := LoadResolvedConfigSources(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sources).To(ConsistOf(
+				cfgFile,
+				filepath.Join(tmpDir, "conf.d"),
+				filepath.Join(tmpDir, "conf.d", "10-git.yml"),
+				filepath.Join(tmpDir, "conf.d", "20-docs.yml"),
+				filepath.Join(tmpDir, "base.yml"),
+				filepath.Join(tmpDir, "packs"),
+				filepath.Join(tmpDir, "packs", "git"),
+				filepath.Join(tmpDir, "packs", "git", "aliases.yml"),
+			))
+		})
+
+		It("should accept globs matching nothing but not missing files", func() {
+			write(cfgFile, "version: \"1\"\ninclude: [packs/*.yml]\nrules: []\n")
+			_, err := LoadResolvedConfig(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+
+			write(cfgFile, "version: \"1\"\ninclude: [packs/git.yml]\nrules: []\n")
+			_, err = LoadResolvedConfig(cfgFile)
+			Expect(err).To(MatchError(ContainSubstring(`file "packs/git.yml" included by`)))
+		})
+
+		It("should detect include cycles", func() {
+			write(cfgFile, "version: \"1\"\ninclude: [conf.d/loop.yml]\nrules: []\n")
+			write(filepath.Join(tmpDir, "conf.d", "loop.yml"), "version: \"1\"\ninclude: [../config.yml]\nrules: []\n")
+
+			_, err := LoadResolvedConfig(cfgFile)
+			Expect(err).To(MatchError(ContainSubstring("config include cycle: config -> loop -> config")))
+		})
+
+		It("should report the file and line of invalid rules", func() {
+			write(filepath.Join(tmpDir, "conf.d", "30-broken.yml"), `
+version: "1"
+rules:
+  - name: Good
+    command: cat {{.File}}
+  - name: Broken
+    regex: "["
+    command: cat {{.File}}
+`)
+			write(cfgFile, "version: \"1\"\ninclude: [conf.d/*.yml]\nrules: []\n")
+
+			cfg, err := LoadResolvedConfig(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+			err = ValidateConfig(cfg)
+			Expect(err).To(MatchError(ContainSubstring(`30-broken.yml:6: rule "Broken": Config.Rules[3].Regex is is-regex`)))
+		})
+
+		It("should report the line of rules that fail to layer", func() {
+			write(cfgFile, `
+version: "1"
+extends: [conf.d/10-git.yml]
+rules:
+  - name: Missing
+    command: cat {{.File}}
+    merge: override
+`)
+
+			_, err := LoadResolvedConfig(cfgFile)
+			Expect(err).To(MatchError(ContainSubstring(`config.yml:5: rule "Missing" overrides no inherited rule`)))
+		})
+	})
+
+	Describe("MigrateDocument", func() {
+		legacy := `# Personal config
+version: "1"
+default: vim {{.File}} # fallback
+sync:
+  backend: dir
+  path: ~/.config/entry/shared
+rules:
+  - name: Open
+    command: ~/.config/entry/scripts/open.sh {{.File}}
+`
+
+		It("should upgrade old documents and keep comments", func() {
+			root, changes, err := MigrateDocument([]byte(legacy))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(changes).To(Equal([]string{
+				"line 3: renamed default to default_command",
+				"line 6: ~/.config/entry/shared -> ~/.config/via/shared",
+				"version 1 -> 2",
+			}))
+
+			data, err := yaml.Marshal(root)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(ContainSubstring("# Personal config"))
+			Expect(string(data)).To(ContainSubstring("default_command: vim {{.File}} # fallback"))
+			Expect(string(data)).To(ContainSubstring(`version: "2"`))
+		})
+
+		It("should migrate on load", func() {
+			Expect(os.WriteFile(cfgFile, []byte(legacy), 0644)).To(Succeed())
+			cfg, err := LoadConfig(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Version).To(Equal(CurrentVersion))
+			Expect(cfg.Default).To(BeEmpty())
+			Expect(cfg.DefaultCommand).To(Equal("vim {{.File}}"))
+			Expect(cfg.Sync.Path).To(Equal("~/.config/via/shared"))
+			Expect(cfg.Rules[0].Command).To(HavePrefix("~/.config/entry/"), "commands are left alone")
+		})
+
+		It("should treat documents without a version as version 1", func() {
+			_, changes, err := MigrateDocument([]byte("default: less {{.File}}\ndefault_command: vim {{.File}}\n"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(changes).To(ContainElement("line 1: removed default, default_command is already set"))
+			Expect(changes).To(ContainElement("version 1 -> 2"))
+		})
+
+		It("should leave current documents alone", func() {
+			_, changes, err := MigrateDocument([]byte("version: \"2\"\ndefault: vim\n"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(changes).To(BeEmpty())
+		})
+
+		It("should reject versions it does not know", func() {
+			_, _, err := MigrateDocument([]byte("version: \"99\"\n"))
+			Expect(err).To(MatchError(ContainSubstring("newer than this version of vv supports")))
+			_, _, err = MigrateDocument([]byte("version: beta\n"))
+			Expect(err).To(MatchError(ContainSubstring(`unsupported config version "beta"`)))
+		})
+
+		It("should back up outdated files before saving over them", func() {
+			Expect(os.WriteFile(cfgFile, []byte(legacy), 0644)).To(Succeed())
+			cfg, err := LoadConfig(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(SaveConfig(cfgFile, cfg)).To(Succeed())
+
+			backup, err := os.ReadFile(filepath.Join(tmpDir, "backups", "config.v1.yml"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(backup)).To(Equal(legacy))
+
+			path, err := BackupOutdated(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(path).To(BeEmpty())
+		})
+	})
+
+	Describe("SaveConfig edits", func() {
+		// edit loads a fixture from testdata/edit, applies fn, saves it and
+		// compares the file with the golden copy
+		edit := func(fixture, golden string, fn func(cfg *Config)) {
+			data, err := os.ReadFile(filepath.Join("testdata", "edit", fixture))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.WriteFile(cfgFile, data, 0644)).To(Succeed())
+
+			cfg, err := LoadConfig(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+			fn(cfg)
+			Expect(SaveConfig(cfgFile, cfg)).To(Succeed())
+
+			saved, err := os.ReadFile(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+			goldenPath := filepath.Join("testdata", "edit", golden)
+			if *updateGolden {
+				Expect(os.WriteFile(goldenPath, saved, 0644)).To(Succeed())
+			}
+			want, err := os.ReadFile(goldenPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(saved)).To(Equal(string(want)))
+
+			// The file must also read back as exactly the edited config
+			reloaded, err := LoadConfig(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+			got, _ := yaml.Marshal(reloaded)
+			expected, _ := yaml.Marshal(cfg)
+			Expect(string(got)).To(Equal(string(expected)))
+		}
+
+		DescribeTable("should keep comments, order and anchors",
+			edit,
+			Entry("unchanged", "config.yml", "unchanged.golden.yml", func(cfg *Config) {}),
+			Entry("adding a rule", "config.yml", "add_rule.golden.yml", func(cfg *Config) {
+				cfg.Rules = append(cfg.Rules, Rule{Name: "Images", Extensions: []string{"png", "jpg"}, Command: "feh {{.File}}"})
+			}),
+			Entry("removing the anchored rule", "config.yml", "remove_rule.golden.yml", func(cfg *Config) {
+				cfg.Rules = cfg.Rules[1:]
+			}),
+			Entry("moving a rule", "config.yml", "move_rule.golden.yml", func(cfg *Config) {
+				cfg.Rules[0], cfg.Rules[1] = cfg.Rules[1], cfg.Rules[0]
+			}),
+			Entry("changing values", "config.yml", "edit_values.golden.yml", func(cfg *Config) {
+				cfg.DefaultCommand = "nvim {{.File}}"
+				cfg.Rules[0].Command = "evince {{.File}}"
+				cfg.Rules[1].Background = true
+				cfg.Aliases["o"] = "open"
+			}),
+			Entry("adding and removing sections", "config.yml", "sections.golden.yml", func(cfg *Config) {
+				cfg.History = nil
+				cfg.Include = []string{"conf.d/*.yml"}
+			}),
+			Entry("migrating an old file", "legacy.yml", "legacy.golden.yml", func(cfg *Config) {}),
+		)
+
+		It("should keep the indentation of the file", func() {
+			Expect(DetectIndent([]byte("rules:\n    - name: a\n      command: b\n"))).To(Equal(4))
+			Expect(DetectIndent([]byte("# top\nrules:\n  - name: a\n"))).To(Equal(2))
+			Expect(DetectIndent([]byte("version: \"2\"\n"))).To(Equal(4))
+		})
+	})
+
+	Describe("Atomic writes and backups", func() {
+		save := func(command string) {
+			Expect(SaveConfig(cfgFile, &Config{Version: CurrentVersion, DefaultCommand: command})).To(Succeed())
+		}
+
+		It("should keep the previous versions, newest first", func() {
+			save("vim")
+			save("nvim")
+			save("code")
+
+			backups, err := ListBackups(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(backups).To(HaveLen(2))
+			data, err := os.ReadFile(backups[0].Path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(ContainSubstring("default_command: nvim"))
+		})
+
+		It("should not back up when nothing changed", func() {
+			save("vim")
+			save("vim")
+			backups, err := ListBackups(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(backups).To(BeEmpty())
+		})
+
+		It("should only keep MaxBackups backups", func() {
+			for i := 0; i < MaxBackups+3; i++ {
+				save(fmt.Sprintf("editor-%d", i))
+			}
+			backups, err := ListBackups(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(backups).To(HaveLen(MaxBackups))
+		})
+
+		It("should not list migration backups", func() {
+			Expect(os.MkdirAll(BackupDir(cfgFile), 0755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(BackupDir(cfgFile), "config.v1.yml"), []byte("rules: []\n"), 0644)).To(Succeed())
+			backups, err := ListBackups(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(backups).To(BeEmpty())
+		})
+
+		It("should restore a backup and back up the current file", func() {
+			save("vim")
+			save("nvim")
+			backups, err := ListBackups(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(RestoreBackup(cfgFile, backups[0].Path)).To(Succeed())
+			cfg, err := LoadConfig(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.DefaultCommand).To(Equal("vim"))
+
+			backups, err = ListBackups(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+			data, err := os.ReadFile(backups[0].Path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(ContainSubstring("default_command: nvim"))
+		})
+
+		It("should refuse to restore an invalid backup", func() {
+			bad := filepath.Join(tmpDir, "bad.yml")
+			Expect(os.WriteFile(bad, []byte("version: \"99\"\n"), 0644)).To(Succeed())
+			Expect(RestoreBackup(cfgFile, bad)).To(MatchError(ContainSubstring("is not a valid config")))
+		})
+
+		It("should replace files atomically and follow symlinks", func() {
+			target := filepath.Join(tmpDir, "dotfiles.yml")
+			Expect(os.WriteFile(target, []byte("old\n"), 0600)).To(Succeed())
+			Expect(os.Symlink(target, cfgFile)).To(Succeed())
+
+			Expect(WriteFileAtomic(cfgFile, []byte("new\n"), 0644)).To(Succeed())
+
+			info, err := os.Lstat(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.Mode() & os.ModeSymlink).NotTo(BeZero())
+			data, err := os.ReadFile(target)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(Equal("new\n"))
+			info, err = os.Stat(target)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+
+			entries, err := os.ReadDir(tmpDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(HaveLen(2))
+		})
+
+		It("should not let two writers hold the lock", func() {
+			origTimeout := LockTimeout
+			LockTimeout = 100 * time.Millisecond
+			DeferCleanup(func() { LockTimeout = origTimeout })
+
+			unlock, err := Lock(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(SaveConfig(cfgFile, &Config{Version: CurrentVersion})).To(MatchError(ContainSubstring("being changed by another vv process")))
+
+			unlock()
+			Expect(SaveConfig(cfgFile, &Config{Version: CurrentVersion})).To(Succeed())
+		})
+
+		It("should not lose concurrent updates", func() {
+			Expect(SaveConfig(cfgFile, &Config{Version: CurrentVersion})).To(Succeed())
+
+			var wg gosync.WaitGroup
+			for i := range 10 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer GinkgoRecover()
+					Expect(UpdateConfig(cfgFile, func(cfg *Config) error {
+						cfg.Rules = append(cfg.Rules, Rule{Name: fmt.Sprintf("Rule %d", i), Command: "true"})
+						return nil
+					})).To(Succeed())
+				}()
+			}
+			wg.Wait()
+
+			cfg, err := LoadConfig(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Rules).To(HaveLen(10))
+		})
+
+		It("should create missing files and write nothing when the update fails", func() {
+			Expect(UpdateConfig(cfgFile, func(cfg *Config) error {
+				return fmt.Errorf("rejected")
+			})).To(MatchError("rejected"))
+			Expect(cfgFile).NotTo(BeAnExistingFile())
+
+			Expect(UpdateConfig(cfgFile, func(cfg *Config) error {
+				cfg.DefaultCommand = "vim {{.File}}"
+				return nil
+			})).To(Succeed())
+			cfg, err := LoadConfig(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.DefaultCommand).To(Equal("vim {{.File}}"))
+		})
+
+		It("should refuse to save over a change made after loading", func() {
+			Expect(SaveConfig(cfgFile, &Config{Version: CurrentVersion})).To(Succeed())
+			cfg, loaded, err := LoadConfigFingerprint(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+
+			// Another process adds a rule meanwhile
+			Expect(UpdateConfig(cfgFile, func(other *Config) error {
+				other.Rules = append(other.Rules, Rule{Name: "Other", Command: "true"})
+				return nil
+			})).To(Succeed())
+
+			cfg.DefaultCommand = "vim {{.File}}"
+			_, err = SaveConfigFingerprint(cfgFile, cfg, loaded)
+			Expect(err).To(MatchError(ErrConfigChanged))
+
+			cfg, loaded, err = LoadConfigFingerprint(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+			cfg.DefaultCommand = "vim {{.File}}"
+			saved, err := SaveConfigFingerprint(cfgFile, cfg, loaded)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(saved).NotTo(Equal(loaded))
+
+			// The returned fingerprint allows saving again
+			cfg.DefaultCommand = "nvim {{.File}}"
+			_, err = SaveConfigFingerprint(cfgFile, cfg, saved)
+			Expect(err).NotTo(HaveOccurred())
+			reloaded, err := LoadConfig(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reloaded.Rules).To(HaveLen(1))
+			Expect(reloaded.DefaultCommand).To(Equal("nvim {{.File}}"))
+		})
+	})
+
+	Describe("Formats", func() {
+		fixture := filepath.Join("testdata", "formats", "config.yml")
+
+		DescribeTable("should convert without losing settings",
+			func(format Format) {
+				cfg, err := LoadConfig(fixture)
+				Expect(err).NotTo(HaveOccurred())
+
+				data, err := Encode(cfg, format)
+				Expect(err).NotTo(HaveOccurred())
+				golden := filepath.Join("testdata", "formats", "config"+format.Ext())
+				if *updateGolden {
+					Expect(os.WriteFile(golden, data, 0644)).To(Succeed())
+				}
+				want, err := os.ReadFile(golden)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(data)).To(Equal(string(want)))
+
+				converted, err := LoadConfig(golden)
+				Expect(err).NotTo(HaveOccurred())
+				got, _ := yaml.Marshal(converted)
+				expected, _ := yaml.Marshal(cfg)
+				Expect(string(got)).To(Equal(string(expected)))
+			},
+			Entry("TOML", FormatTOML),
+			Entry("JSON", FormatJSON),
+		)
+
+		It("should read JSONC comments and trailing commas", func() {
+			path := filepath.Join(tmpDir, "config.jsonc")
+			Expect(os.WriteFile(path, []byte(`{
+  // Personal config
+  "version": "2",
+  "rules": [
+    {
+      "command": "open https://example.com/*{{.File}}*/", /* keeps strings intact */
+      "extensions": ["url",],
+    },
+  ],
+}
+`), 0644)).To(Succeed())
+
+			cfg, err := LoadConfig(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Rules).To(HaveLen(1))
+			Expect(cfg.Rules[0].Command).To(Equal("open https://example.com/*{{.File}}*/"))
+			Expect(cfg.Rules[0].Extensions).To(Equal([]string{"url"}))
+			Expect(cfg.Rules[0].Label()).To(Equal(path + `: unnamed rule`))
+		})
+
+		It("should find and save configs in other formats", func() {
+			home := GinkgoT().TempDir()
+			origHome := UserHomeDir
+			UserHomeDir = func() (string, error) { return home, nil }
+			DeferCleanup(func() { UserHomeDir = origHome })
+
+			dir := filepath.Join(home, ".config", "via")
+			Expect(os.MkdirAll(filepath.Join(dir, "profiles"), 0755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(dir, "config.toml"), []byte("version = \"2\"\ndefault_command = \"vim\"\n"), 0644)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(dir, "profiles", "work.json"), []byte(`{"version": "2"}`), 0644)).To(Succeed())
+
+			path, err := GetConfigPath("")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(path).To(Equal(filepath.Join(dir, "config.toml")))
+			profiles, err := ListProfiles()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(profiles).To(Equal([]string{"work"}))
+
+			cfg, err := LoadConfig("")
+			Expect(err).NotTo(HaveOccurred())
+			cfg.DefaultCommand = "nvim"
+			Expect(SaveConfig("", cfg)).To(Succeed())
+			data, err := os.ReadFile(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(Equal("version = \"2\"\ndefault_command = \"nvim\"\nrules = []\n"))
+		})
+
+		It("should restore a backup taken before a conversion", func() {
+			Expect(os.WriteFile(cfgFile, []byte("version: \"2\"\ndefault_command: vim\n"), 0644)).To(Succeed())
+			backup, err := SaveBackup(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+
+			tomlFile := filepath.Join(tmpDir, "config.toml")
+			Expect(os.WriteFile(tomlFile, []byte("version = \"2\"\n"), 0644)).To(Succeed())
+			Expect(RestoreBackup(tomlFile, backup)).To(Succeed())
+			data, err := os.ReadFile(tomlFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(ContainSubstring(`default_command = "vim"`))
+		})
+	})
+
+	Describe("Expand", func() {
+		BeforeEach(func() {
+			origLookup, origHome := LookupEnv, UserHomeDir
+			env := map[string]string{"EDITOR": "nvim", "EMPTY": "", "WAIT": "code --wait", "EVIL": "a; rm $(x) 'q' {{.Dir}}"}
+			LookupEnv = func(key string) (string, bool) {
+				v, ok := env[key]
+				return v, ok
+			}
+			UserHomeDir = func() (string, error) { return "/home/user", nil }
+			DeferCleanup(func() { LookupEnv, UserHomeDir = origLookup, origHome })
+		})
+
+		DescribeTable("ExpandValue",
+			func(value, expected string) {
+				Expect(ExpandValue(value)).To(Equal(expected))
+			},
+			Entry("variable", "${EDITOR} {{.File}}", "nvim {{.File}}"),
+			Entry("default when unset", "${VIEWER:-less} {{.File}}", "less {{.File}}"),
+			Entry("default when empty", "${EMPTY:-less}", "less"),
+			Entry("set variable ignores default", "${EDITOR:-vim}", "nvim"),
+			Entry("unset variable without default", "${VIEWER} {{.File}}", "${VIEWER} {{.File}}"),
+			Entry("escaped", "echo $${EDITOR}", "echo ${EDITOR}"),
+			Entry("plain $VAR is left to the shell", "echo $EDITOR", "echo $EDITOR"),
+			Entry("leading tilde", "~/bin/open {{.File}}", "/home/user/bin/open {{.File}}"),
+			Entry("tilde after space", "open -a ~/Apps/Viewer", "open -a /home/user/Apps/Viewer"),
+			Entry("tilde after =", "PATH=~/bin", "PATH=/home/user/bin"),
+			Entry("tilde inside a word", "cp a~/b ~user/c", "cp a~/b ~user/c"),
+		)
+
+		DescribeTable("ExpandCommand",
+			func(command, expected string) {
+				Expect(ExpandCommand(command)).To(Equal(expected))
+			},
+			Entry("plain value", "${EDITOR} {{.File}}", "nvim {{.File}}"),
+			Entry("words are split", "${WAIT} {{.File}}", "code --wait {{.File}}"),
+			Entry("empty value", "echo ${EMPTY:-}", "echo "),
+			Entry("shell and template syntax is quoted", "echo ${EVIL}", `echo 'a;' rm '$(x)' ''\''q'\''' '{{"{{"}}.Dir}}'`),
+			Entry("default is quoted", "${VIEWER:-less -R;} {{.File}}", "less '-R;' {{.File}}"),
+			Entry("unset variable without default", "${VIEWER} {{.File}}", "${VIEWER} {{.File}}"),
+			Entry("tilde", "~/bin/open {{.File}}", "/home/user/bin/open {{.File}}"),
+		)
+
+		It("should not let shell or template syntax in variables run", func() {
+			cfg := Expand(&Config{Rules: []Rule{{Command: "echo ${EVIL} {{.Base}}"}}})
+			tmpl, err := template.New("command").Parse(cfg.Rules[0].Command)
+			Expect(err).NotTo(HaveOccurred())
+			var rendered strings.Builder
+			Expect(tmpl.Execute(&rendered, map[string]string{"Base": "a.txt", "Dir": "/tmp"})).To(Succeed())
+
+			out, err := exec.Command("sh", "-c", rendered.String()).Output()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(out)).To(Equal("a; rm $(x) 'q' {{.Dir}} a.txt\n"))
+		})
+
+		It("should expand resolved configs and keep the raw values through layering", func() {
+			Expect(os.WriteFile(cfgFile, []byte("version: \"2\"\nrules:\n  - name: Edit\n    command: ${EDITOR} {{.File}}\n"), 0644)).To(Succeed())
+			projectDir := filepath.Join(tmpDir, "project")
+			Expect(os.Mkdir(projectDir, 0755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(projectDir, ProjectConfigName), []byte("version: \"2\"\nrules:\n  - name: View\n    command: ${VIEWER:-less} {{.File}}\n"), 0644)).To(Succeed())
+
+			cfg, err := LoadResolvedConfig(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Rules[0].Command).To(Equal("nvim {{.File}}"))
+			Expect(cfg.Raw.Rules[0].Command).To(Equal("${EDITOR} {{.File}}"))
+
+			layered, _, err := ApplyProjectConfigs(cfg, projectDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(layered.Rules[0].Command).To(Equal("less {{.File}}"))
+			Expect(layered.Rules[1].Command).To(Equal("nvim {{.File}}"))
+			Expect(layered.Raw.Rules[0].Command).To(Equal("${VIEWER:-less} {{.File}}"))
+			Expect(layered.Raw.Rules[1].Command).To(Equal("${EDITOR} {{.File}}"))
+
+			raw, err := LoadConfig(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(raw.Rules[0].Command).To(Equal("${EDITOR} {{.File}}"))
+		})
+
+		It("should expand commands and env values and keep the raw config", func() {
+			cfg := &Config{
+				DefaultCommand: "${EDITOR}",
+				Aliases:        map[string]string{"edit": "${EDITOR} {{.File}}"},
+				Rules: []Rule{{
+					Name:    "Text",
+					Regex:   `\.txt$`,
+					Script:  "return `${file}`",
+					Command: "~/bin/view {{.File}}",
+					Env:     map[string]string{"PAGER": "${PAGER:-less}"},
+				}},
+			}
+
+			expanded := Expand(cfg)
+			Expect(expanded.DefaultCommand).To(Equal("nvim"))
+			Expect(expanded.Aliases["edit"]).To(Equal("nvim {{.File}}"))
+			Expect(expanded.Rules[0].Command).To(Equal("/home/user/bin/view {{.File}}"))
+			Expect(expanded.Rules[0].Env["PAGER"]).To(Equal("less"))
+			Expect(expanded.Rules[0].Regex).To(Equal(`\.txt$`))
+			Expect(expanded.Rules[0].Script).To(Equal("return `${file}`"))
+
+			Expect(expanded.Raw).To(BeIdenticalTo(cfg))
+			Expect(cfg.Rules[0].Command).To(Equal("~/bin/view {{.File}}"))
+			Expect(cfg.Rules[0].Env["PAGER"]).To(Equal("${PAGER:-less}"))
+		})
+	})
+
+	Describe("Lint", func() {
+		BeforeEach(func() {
+			origLookPath := LookPath
+			LookPath = func(file string) (string, error) {
+				if file == "missing-viewer" {
+					return "", exec.ErrNotFound
+				}
+				return "/usr/bin/" + file, nil
+			}
+			DeferCleanup(func() { LookPath = origLookPath })
+		})
+
+		checks := func(problems []Problem) []string {
+			var result []string
+			for _, p := range problems {
+				result = append(result, p.Check)
+			}
+			return result
+		}
+
+		It("should report nothing for a clean config", func() {
+			cfg := &Config{
+				Version:        "2",
+				DefaultCommand: "vim {{.File}}",
+				Rules: []Rule{
+					{Name: "Images", Extensions: []string{"png"}, Command: "feh {{.File}}", Fallthrough: true},
+					{Name: "PNG", Extensions: []string{"png"}, Command: "optipng {{.File}}"},
+					{Name: "Web", Scheme: "https", Command: "firefox {{.File}}"},
+					{Name: "Logs", Regex: `\.log$`, OS: []string{"linux"}, Command: "PAGER=less ${EDITOR:-vim} {{.Base}}"},
+				},
+			}
+			Expect(Lint(cfg)).To(BeEmpty())
+		})
+
+		DescribeTable("semantic checks",
+			func(rules []Rule, check string, message string) {
+				problems := Lint(&Config{Version: "2", Rules: rules})
+				Expect(checks(problems)).To(Equal([]string{check}))
+				Expect(problems[0].Rule).To(BeIdenticalTo(&rules[len(rules)-1]))
+				Expect(problems[0].Message).To(ContainSubstring(message))
+			},
+			Entry("rule without conditions",
+				[]Rule{{Name: "Nothing", OS: []string{"linux"}, Command: "cat"}},
+				"no-conditions", "never matches"),
+			Entry("rule shadowed by extensions",
+				[]Rule{
+					{Name: "Docs", Extensions: []string{"md", "TXT"}, Command: "cat"},
+					{Name: "Text", Extensions: []string{"txt"}, Command: "less"},
+				},
+				"shadowed", `rule "Docs" matches everything it matches first`),
+			Entry("rule shadowed by scheme",
+				[]Rule{
+					{Name: "Web", Scheme: "https", Command: "firefox"},
+					{Name: "GitHub", Scheme: "HTTPS", Regex: "github.com", Command: "gh browse"},
+				},
+				"shadowed", `rule "Web"`),
+			Entry("rule shadowed on its OS",
+				[]Rule{
+					{Name: "Unix", Regex: `\.log$`, OS: []string{"linux", "darwin"}, Command: "less"},
+					{Name: "Mac", Regex: `\.log$`, OS: []string{"darwin"}, Command: "open"},
+				},
+				"shadowed", `rule "Unix"`),
+			Entry("duplicate names",
+				[]Rule{
+					{Name: "PDF", Extensions: []string{"pdf"}, Command: "zathura"},
+					{Name: "PDF", Extensions: []string{"epub"}, Command: "zathura"},
+				},
+				"duplicate-name", `same name as rule "PDF"`),
+			Entry("unknown OS",
+				[]Rule{{Name: "Mac", Extensions: []string{"pdf"}, OS: []string{"macos"}, Command: "open"}},
+				"unknown-os", `unknown OS "macos", use "darwin"`),
+			Entry("binary not on $PATH",
+				[]Rule{{Name: "PDF", Extensions: []string{"pdf"}, Command: "missing-viewer {{.File}}"}},
+				"command-not-found", `"missing-viewer", which is not on $PATH`),
+			Entry("unparsable template",
+				[]Rule{{Name: "PDF", Extensions: []string{"pdf"}, Command: "open {{.File}"}},
+				"template", "command is not a valid template"),
+			Entry("unknown template field",
+				[]Rule{{Name: "PDF", Extensions: []string{"pdf"}, Command: "open {{.Path}}"}},
+				"template", "can't evaluate field Path"),
+		)
+
+		It("should not report rules that are reachable", func() {
+			cfg := &Config{Version: "2", Rules: []Rule{
+				{Name: "Docs", Extensions: []string{"md"}, Command: "cat"},
+				{Name: "Text", Extensions: []string{"md", "txt"}, Command: "less"},
+				{Name: "Linux", Regex: "x", OS: []string{"linux"}, Command: "a"},
+				{Name: "Any", Regex: "x", Command: "b"},
+				{Name: "Web", Scheme: "https", Command: "firefox"},
+				{Name: "Links", Regex: "^https://", Command: "curl"},
+			}}
+			Expect(Lint(cfg)).To(BeEmpty())
+		})
+
+		It("should not look up binaries of rules for another OS", func() {
+			other := "windows"
+			if runtime.GOOS == "windows" {
+				other = "linux"
+			}
+			cfg := &Config{Version: "2", Rules: []Rule{
+				{Name: "Other", Extensions: []string{"pdf"}, OS: []string{other}, Command: "missing-viewer {{.File}}"},
+			}}
+			Expect(Lint(cfg)).To(BeEmpty())
+		})
+
+		It("should report every schema violation with the rule position", func() {
+			Expect(os.WriteFile(cfgFile, []byte(`version: "2"
+rules:
+    - name: First
+      extensions: [txt]
+    - name: Second
+      regex: "["
+      merge: sideways
+      command: cat
+`), 0644)).To(Succeed())
+			cfg, err := LoadConfig(cfgFile)
+			Expect(err).NotTo(HaveOccurred())
+
+			var messages []string
+			for _, p := range Lint(cfg) {
+				Expect(p.Severity).To(Equal(SeverityError))
+				messages = append(messages, p.String())
+			}
+			Expect(messages).To(ConsistOf(
+				cfgFile+`:3: rule "First": error: command is required (schema)`,
+				HavePrefix(cfgFile+`:5: rule "Second": error: regex is not a valid regular expression: `),
+				cfgFile+`:5: rule "Second": error: merge must be one of prepend, append, override, not "sideways" (schema)`,
+			))
+		})
+
+		It("should report schema violations outside rules by path", func() {
+			cfg := &Config{Version: "2", Profiles: &ProfilesConfig{Auto: []AutoProfile{{Dir: "~/work"}}}}
+			problems := Lint(cfg)
+			Expect(problems).To(HaveLen(1))
+			Expect(problems[0].String()).To(Equal("error: profiles.auto[0].profile is required (schema)"))
+		})
+	})
+
+	Describe("MatchAutoProfile", func() {
+		BeforeEach(func() {
+			origHome, origHostname, origRemotes := UserHomeDir, Hostname, GitRemotes
+			UserHomeDir = func() (string, error) { return tmpDir, nil }
+			Hostname = func() (string, error) { return "work-laptop", nil }
+			GitRemotes = func(dir string) []string {
+				if filepath.Base(dir) == "oss" {
+					return []string{"git@github.com:myorg/oss.git"}
+				}
+				return nil
+			}
+			DeferCleanup(func() { UserHomeDir, Hostname, GitRemotes = origHome, origHostname, origRemotes })
+		})
+
+		entries := []AutoProfile{
+			{Profile: "oss", GitRemote: "*github.com*myorg/*"},
+			{Profile: "work", Dir: "~/work/*"},
+			{Profile: "ci", Env: "VIA_TEST_CI=true"},
+			{Profile: "laptop", Hostname: "*-laptop", Env: "VIA_TEST_LAPTOP"},
+		}
+
+		It("should match directories and their parents", func() {
+			Expect(MatchAutoProfile(entries, filepath.Join(tmpDir, "work", "api", "internal"))).To(Equal("work"))
+			Expect(MatchAutoProfile(entries, filepath.Join(tmpDir, "home"))).To(BeEmpty())
+		})
+
+		It("should prefer earlier entries", func() {
+			Expect(MatchAutoProfile(entries, filepath.Join(tmpDir, "work", "oss"))).To(Equal("oss"))
+		})
+
+		It("should require every condition to match", func() {
+			GinkgoT().Setenv("VIA_TEST_CI", "false")
+			Expect(MatchAutoProfile(entries, tmpDir)).To(BeEmpty())
+
+			GinkgoT().Setenv("VIA_TEST_LAPTOP", "1")
+			Expect(MatchAutoProfile(entries, tmpDir)).To(Equal("laptop"))
+
+			GinkgoT().Setenv("VIA_TEST_CI", "true")
+			Expect(MatchAutoProfile(entries, tmpDir)).To(Equal("ci"))
+		})
+
+		It("should never match entries without conditions", func() {
+			Expect(MatchAutoProfile([]AutoProfile{{Profile: "any"}}, tmpDir)).To(BeEmpty())
+		})
+	})
+
+	Describe("ApplyProjectConfigs", func() {
+		It("should layer project files from the outermost to the nearest", func() {
+			project := filepath.Join(tmpDir, "project")
+			nested := filepath.Join(project, "docs", "guide")
+			Expect(os.MkdirAll(nested, 0755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(project, ProjectConfigName), []byte(`
+version: "1"
+default_command: code {{.File}}
+rules:
+  - name: Markdown
+    extensions: [md]
+    command: glow {{.File}}
+`), 0644)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(project, "docs", ProjectConfigName), []byte(`
+version: "1"
+rules:
+  - name: Markdown
+    command: mdcat {{.File}}
+sync:
+  backend: dir
+  path: /tmp/elsewhere
+`), 0644)).To(Succeed())
+
+			base := &Config{
+				Version:  "1",
+				Rules:    []Rule{{Name: "PDF", Extensions: []string{"pdf"}, Command: "evince {{.File}}"}},
+				Sync:     &SyncConfig{Backend: "gist"},
+				Profiles: &ProfilesConfig{ProjectFiles: true},
+			}
+
+			cfg, paths, err := ApplyProjectConfigs(base, nested)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(paths).To(HaveLen(2))
+			Expect(cfg.Rules).To(HaveLen(2))
+			Expect(cfg.Rules[0].Command).To(Equal("mdcat {{.File}}"))
+			Expect(cfg.Rules[1].Name).To(Equal("PDF"))
+			Expect(cfg.DefaultCommand).To(Equal("code {{.File}}"))
+			Expect(cfg.Sync.Backend).To(Equal("gist"))
+		})
+
+		It("should return the config unchanged without project files", func() {
+			base := &Config{Version: "1"}
+			cfg, paths, err := ApplyProjectConfigs(base, tmpDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(paths).To(BeEmpty())
+			Expect(cfg).To(BeIdenticalTo(base))
+		})
+	})
+
 	Describe("ValidateRegex", func() {
 		It("should pass for valid regex", func() {
 			err := ValidateRegex("^test$")
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Credentials holds secrets that must never be written to (or synced with) the config file
+type Credentials struct {
+	SyncToken string `yaml:"sync_token,omitempty"`
+}
+
+// CredentialsPath returns the credentials file for the given config file.
+// Each config file (including profiles) has its own credentials file.
+func CredentialsPath(configPath string) string {
+	name := strings.TrimSuffix(filepath.Base(configPath), filepath.Ext(configPath))
+	return filepath.Join(filepath.Dir(configPath), "credentials", name+".yml")
+}
+
+// LoadCredentials reads the credentials for a config file. It returns empty credentials if none are stored.
+func LoadCredentials(configPath string) (*Credentials, error) {
+	data, err := os.ReadFile(CredentialsPath(configPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Credentials{}, nil
+		}
+		return nil, fmt.Errorf("failed to read credentials: %w", err)
+	}
+
+	var creds Credentials
+	if err := yaml.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("failed to parse credentials: %w", err)
+	}
+	return &creds, nil
+}
+
+// SaveCredentials writes the credentials for a config file, readable only by the current user
+func SaveCredentials(configPath string, creds *Credentials) error {
+	data, err := yaml.Marshal(creds)
+	if err != nil {
+		return fmt.Errorf("failed to marshal credentials: %w", err)
+	}
+
+	path := CredentialsPath(configPath)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create credentials directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write credentials: %w", err)
+	}
+	// WriteFile keeps the mode of an existing file, so enforce it explicitly
+	if err := os.Chmod(path, 0600); err != nil {
+		return fmt.Errorf("failed to set credentials permissions: %w", err)
+	}
+	return nil
+}
+
+// MarshalYAML omits the token so it never ends up in a config file or a sync upload
+func (s SyncConfig) MarshalYAML() (any, error) {
+	type plain SyncConfig
+	stripped := plain(s)
+	stripped.Token = ""
+	return stripped, nil
+}
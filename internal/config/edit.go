@@ -0,0 +1,281 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultIndent matches yaml.Marshal, which wrote every config before edits
+// were applied to the existing document
+const defaultIndent = 4
+
+// UpdateDocument renders cfg as YAML on top of the original document. Nodes whose
+// value did not change are reused as they are, so comments, key order, quoting,
+// anchors and aliases survive an edit; changed values are updated in place and
+// new keys are inserted after their predecessor. Without a usable original it
+// falls back to a plain encoding of cfg.
+func UpdateDocument(original []byte, cfg *Config) ([]byte, error) {
+	var updated yaml.Node
+	if err := updated.Encode(cfg); err != nil {
+		return nil, err
+	}
+	indent := DetectIndent(original)
+
+	old, _, err := MigrateDocument(original)
+	if err != nil || len(old.Content) == 0 || old.Content[0].Kind != yaml.MappingNode {
+		return EncodeDocument(&yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{&updated}}, indent)
+	}
+
+	markBlankLines(old, strings.Split(string(original), "\n"))
+	doc := *old
+	doc.Content = []*yaml.Node{mergeNode(old.Content[0], &updated)}
+	resolveAliases(&doc, map[*yaml.Node]bool{})
+	data, err := EncodeDocument(&doc, indent)
+	if err != nil {
+		return nil, err
+	}
+	return blankIndent.ReplaceAll(data, nil), nil
+}
+
+// blankIndent matches the indentation the encoder writes on the blank lines
+// restored inside sequences
+var blankIndent = regexp.MustCompile(`(?m)^ +$`)
+
+// EncodeDocument writes a YAML node tree with the given indentation
+func EncodeDocument(root *yaml.Node, indent int) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(indent)
+	if err := enc.Encode(root); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DetectIndent returns the indentation used by a YAML document: the smallest
+// indent of any content line, or the yaml.Marshal default if nothing is indented
+func DetectIndent(data []byte) int {
+	indent := 0
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if n := len(line) - len(trimmed); n > 0 && (indent == 0 || n < indent) {
+			indent = n
+		}
+	}
+	if indent < 2 || indent > 9 {
+		return defaultIndent
+	}
+	return indent
+}
+
+// markBlankLines records the blank lines that separate keys and items in the
+// original text, which yaml.v3 does not keep, as a leading newline of their head
+// comment so that the encoder writes them back
+func markBlankLines(n *yaml.Node, lines []string) {
+	var items []*yaml.Node
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i < len(n.Content); i += 2 {
+			items = append(items, n.Content[i])
+		}
+	case yaml.SequenceNode:
+		items = n.Content
+	}
+	for _, item := range items {
+		start := item.Line
+		if item.HeadComment != "" {
+			start -= strings.Count(item.HeadComment, "\n") + 1
+		}
+		if start >= 2 && start-2 < len(lines) && strings.TrimSpace(lines[start-2]) == "" {
+			item.HeadComment = "\n" + item.HeadComment
+		}
+	}
+	for _, c := range n.Content {
+		markBlankLines(c, lines)
+	}
+}
+
+// mergeNode returns the node to write for upd, reusing old where possible.
+// old is never modified, so comparisons always see the original document.
+func mergeNode(old, upd *yaml.Node) *yaml.Node {
+	if sameValue(old, upd) {
+		return old
+	}
+	if old.Kind != upd.Kind || old.Kind == yaml.AliasNode {
+		result := *upd
+		copyComments(&result, old)
+		return &result
+	}
+
+	result := *old
+	switch old.Kind {
+	case yaml.MappingNode:
+		result.Content = mergeMapping(old, upd)
+	case yaml.SequenceNode:
+		result.Content = mergeSequence(old, upd)
+	case yaml.ScalarNode:
+		if result.Tag != upd.Tag {
+			result.Style = upd.Style
+		}
+		result.Tag = upd.Tag
+		result.Value = upd.Value
+	default:
+		return upd
+	}
+	return &result
+}
+
+// mergeMapping keeps the keys of old that are still present in their original
+// order, dropping the others, and inserts new keys after the key that precedes
+// them in upd
+func mergeMapping(old, upd *yaml.Node) []*yaml.Node {
+	var content []*yaml.Node
+	for i := 0; i+1 < len(old.Content); i += 2 {
+		if j := mappingIndex(upd, old.Content[i].Value); j >= 0 {
+			content = append(content, old.Content[i], mergeNode(old.Content[i+1], upd.Content[j+1]))
+		}
+	}
+
+	for j := 0; j+1 < len(upd.Content); j += 2 {
+		if mappingIndex(old, upd.Content[j].Value) >= 0 {
+			continue
+		}
+		pos := 0
+		if j > 0 {
+			pos = mappingIndex(&yaml.Node{Content: content}, upd.Content[j-2].Value) + 2
+		}
+		content = append(content[:pos], append([]*yaml.Node{upd.Content[j], upd.Content[j+1]}, content[pos:]...)...)
+	}
+	return content
+}
+
+// mergeSequence follows the order of upd, pairing each item with the old item it
+// most likely came from so that moved or edited items keep their comments
+func mergeSequence(old, upd *yaml.Node) []*yaml.Node {
+	used := make([]bool, len(old.Content))
+	content := make([]*yaml.Node, 0, len(upd.Content))
+	for i, item := range upd.Content {
+		k := matchItem(old.Content, used, item, i)
+		if k < 0 {
+			content = append(content, item)
+			continue
+		}
+		used[k] = true
+		content = append(content, mergeNode(old.Content[k], item))
+	}
+	trimLeadingBlank(content)
+	return content
+}
+
+// matchItem finds the unused old item for item: an identical one, else one with
+// the same name, else the one at the same position
+func matchItem(old []*yaml.Node, used []bool, item *yaml.Node, index int) int {
+	for k, o := range old {
+		if !used[k] && sameValue(o, item) {
+			return k
+		}
+	}
+	if name := itemName(item); name != "" {
+		for k, o := range old {
+			if !used[k] && itemName(o) == name {
+				return k
+			}
+		}
+	}
+	if index < len(old) && !used[index] && itemName(old[index]) == "" && old[index].Kind == item.Kind {
+		return index
+	}
+	return -1
+}
+
+// trimLeadingBlank drops the blank line before the first entry of a collection,
+// which an entry that moved to the front may have brought along
+func trimLeadingBlank(content []*yaml.Node) {
+	for len(content) > 0 {
+		first := content[0]
+		if strings.HasPrefix(first.HeadComment, "\n") {
+			first.HeadComment = strings.TrimPrefix(first.HeadComment, "\n")
+			return
+		}
+		if first.Kind != yaml.MappingNode {
+			return
+		}
+		content = first.Content
+	}
+}
+
+func itemName(n *yaml.Node) string {
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	if n.Kind != yaml.MappingNode {
+		return ""
+	}
+	if v := mappingValue(n, "name"); v != nil && v.Kind == yaml.ScalarNode {
+		return v.Value
+	}
+	return ""
+}
+
+// sameValue reports whether two nodes decode to the same value, resolving aliases
+// and merge keys, so differences in style or comments do not count
+func sameValue(a, b *yaml.Node) bool {
+	var va, vb any
+	if err := a.Decode(&va); err != nil {
+		return false
+	}
+	if err := b.Decode(&vb); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+func copyComments(dst, src *yaml.Node) {
+	dst.HeadComment = src.HeadComment
+	dst.LineComment = src.LineComment
+	dst.FootComment = src.FootComment
+}
+
+// resolveAliases replaces aliases whose anchor no longer precedes them in the
+// document, because the anchored value was changed, removed or moved after the
+// alias, with a copy of the value they stood for
+func resolveAliases(n *yaml.Node, seen map[*yaml.Node]bool) {
+	if n.Anchor != "" {
+		seen[n] = true
+	}
+	for i, c := range n.Content {
+		if c.Kind == yaml.AliasNode && !seen[c.Alias] {
+			expanded := cloneNode(c.Alias)
+			copyComments(expanded, c)
+			n.Content[i] = expanded
+			c = expanded
+		}
+		resolveAliases(c, seen)
+	}
+}
+
+// cloneNode deep-copies a node without its anchors, which stay with the original
+func cloneNode(n *yaml.Node) *yaml.Node {
+	c := *n
+	c.Anchor = ""
+	if n.Content != nil {
+		c.Content = make([]*yaml.Node, len(n.Content))
+		for i, child := range n.Content {
+			c.Content[i] = cloneNode(child)
+		}
+	}
+	return &c
+}
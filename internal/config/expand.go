@@ -0,0 +1,130 @@
+package config
+
+import (
+	"maps"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// LookupEnv is a variable to allow mocking in tests
+var LookupEnv = os.LookupEnv
+
+// variablePattern matches ${VAR} and ${VAR:-default}, and $${ which escapes them
+var variablePattern = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-[^}]*)?\}`)
+
+// tildePattern matches a ~ starting a path: at the start of the value or after a
+// space, = or : (as in PATH lists), followed by / or the end of the word
+var tildePattern = regexp.MustCompile(`(^|[\s=:])~(/|\s|$)`)
+
+// ExpandValue expands ${VAR}, ${VAR:-default} and ~ in a config value. The
+// default is used when VAR is unset or empty. A variable that is unset and has no
+// default is left as written, so a shell running the command still sees it;
+// $${VAR} produces a literal ${VAR}.
+func ExpandValue(value string) string {
+	return expand(value, identity, identity)
+}
+
+// ExpandCommand is ExpandValue for commands, which go through text/template and
+// sh -c. Substituted values are quoted so neither parses them again: a variable
+// is split into words like an unquoted shell variable, and the home directory
+// stays one word.
+func ExpandCommand(command string) string {
+	return expand(command, quoteWords, quoteWord)
+}
+
+// expand implements ExpandValue, passing variable values through variable and
+// the home directory through home
+func expand(value string, variable, home func(string) string) string {
+	expanded := variablePattern.ReplaceAllStringFunc(value, func(m string) string {
+		if m == "$${" {
+			return "${"
+		}
+		sub := variablePattern.FindStringSubmatch(m)
+		v, ok := LookupEnv(sub[1])
+		switch {
+		case sub[2] != "" && v == "":
+			return variable(strings.TrimPrefix(sub[2], ":-"))
+		case ok:
+			return variable(v)
+		}
+		return m
+	})
+
+	if strings.Contains(expanded, "~") {
+		if dir, err := UserHomeDir(); err == nil {
+			expanded = tildePattern.ReplaceAllStringFunc(expanded, func(m string) string {
+				return strings.Replace(m, "~", home(dir), 1)
+			})
+		}
+	}
+	return expanded
+}
+
+func identity(s string) string {
+	return s
+}
+
+// safeWordPattern matches words that mean the same to sh and text/template unquoted
+var safeWordPattern = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// quoteWord quotes s as a single shell word that text/template leaves alone
+func quoteWord(s string) string {
+	if safeWordPattern.MatchString(s) {
+		return s
+	}
+	quoted := "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+	return strings.ReplaceAll(quoted, "{{", `{{"{{"}}`)
+}
+
+// quoteWords splits s on whitespace and quotes each word, as the shell splits an
+// unquoted variable without parsing its value
+func quoteWords(s string) string {
+	words := strings.Fields(s)
+	for i, w := range words {
+		words[i] = quoteWord(w)
+	}
+	return strings.Join(words, " ")
+}
+
+// Expand returns cfg with ExpandCommand applied to the default command and rule
+// commands, and ExpandValue to alias commands, which are run without a shell, and
+// rule env values. Regexes and scripts are left alone because $ and ${ mean
+// something else there. The unexpanded config is
+// kept in Raw so that --explain can show both. A config that is already expanded
+// is expanded again from Raw, in the current environment.
+func Expand(cfg *Config) *Config {
+	cfg = rawConfig(cfg)
+	result := *cfg
+	result.Raw = cfg
+	result.DefaultCommand = ExpandCommand(cfg.DefaultCommand)
+	result.Default = ExpandCommand(cfg.Default)
+
+	if cfg.Aliases != nil {
+		result.Aliases = make(map[string]string, len(cfg.Aliases))
+		for name, command := range cfg.Aliases {
+			result.Aliases[name] = ExpandValue(command)
+		}
+	}
+
+	result.Rules = make([]Rule, len(cfg.Rules))
+	for i, rule := range cfg.Rules {
+		rule.Command = ExpandCommand(rule.Command)
+		if rule.Env != nil {
+			rule.Env = maps.Clone(rule.Env)
+			for k, v := range rule.Env {
+				rule.Env[k] = ExpandValue(v)
+			}
+		}
+		result.Rules[i] = rule
+	}
+	return &result
+}
+
+// rawConfig returns the config cfg was expanded from, or cfg if it was not expanded
+func rawConfig(cfg *Config) *Config {
+	if cfg.Raw != nil {
+		return cfg.Raw
+	}
+	return cfg
+}
@@ -0,0 +1,278 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Rule merge modes for layering a rule over inherited rules
+const (
+	MergePrepend  = "prepend"  // Put the rule before the inherited rules (default for new rules)
+	MergeAppend   = "append"   // Put the rule after the inherited rules
+	MergeOverride = "override" // Replace the inherited rule with the same name in place
+)
+
+// DefaultProfile is the name under which profiles extend the main config file
+const DefaultProfile = "default"
+
+// LoadResolvedConfig loads a config, layers it over the configs it extends and
+// expands it. Use LoadConfig instead when the config is going to be edited and saved.
+func LoadResolvedConfig(path string) (*Config, error) {
+	cfg, _, err := LoadResolvedConfigSources(path)
+	return cfg, err
+}
+
+// LoadResolvedConfigSources is LoadResolvedConfig that also returns the absolute paths
+// the config was resolved from: every file it read, and every directory an include glob
+// was matched in, so callers caching the config can tell when it needs to be loaded again.
+func LoadResolvedConfigSources(path string) (*Config, []string, error) {
+	configPath, err := GetConfigPath(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sources := &sourceSet{}
+	sources.add(configPath)
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	resolved, err := resolve(cfg, configPath, sources)
+	if err != nil {
+		return nil, nil, err
+	}
+	return Expand(resolved), sources.paths, nil
+}
+
+// ResolveConfig returns cfg layered over the configs listed in its extends,
+// which are resolved recursively. configPath is the file cfg was loaded from.
+func ResolveConfig(cfg *Config, configPath string) (*Config, error) {
+	return resolve(cfg, configPath, nil)
+}
+
+func resolve(cfg *Config, configPath string, sources *sourceSet) (*Config, error) {
+	resolved, err := resolveConfig(cfg, configPath, []string{absPath(configPath)}, sources)
+	if err != nil {
+		return nil, err
+	}
+	result := *resolved
+	result.Extends = nil
+	result.Include = nil
+	result.Rules = stripMerge(resolved.Rules)
+	return &result, nil
+}
+
+// resolveConfig merges the files cfg includes and layers it over its parents. A config
+// without parents is returned as is, so its merge modes still apply when it is layered
+// over an earlier parent.
+func resolveConfig(cfg *Config, configPath string, chain []string, sources *sourceSet) (*Config, error) {
+	cfg, err := applyIncludes(cfg, configPath, chain, sources)
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.Extends) == 0 {
+		return cfg, nil
+	}
+
+	var base *Config
+	for _, name := range cfg.Extends {
+		parentPath, err := extendsPath(name, configPath)
+		if err != nil {
+			return nil, err
+		}
+
+		key := absPath(parentPath)
+		if slices.Contains(chain, key) {
+			return nil, fmt.Errorf("config inheritance cycle: %s", formatChain(append(chain, key)))
+		}
+
+		sources.add(parentPath)
+		parent, err := LoadConfig(parentPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %q extended by %s: %w", name, configPath, err)
+		}
+		parent, err = resolveConfig(parent, parentPath, append(slices.Clone(chain), key), sources)
+		if err != nil {
+			return nil, err
+		}
+
+		if base == nil {
+			base = parent
+			continue
+		}
+		if base, err = Layer(base, parent); err != nil {
+			return nil, fmt.Errorf("failed to layer %q: %w", name, err)
+		}
+	}
+
+	resolved, err := Layer(base, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to layer %s: %w", configPath, err)
+	}
+	return resolved, nil
+}
+
+// Layer returns child layered over base. Rules with the name of an inherited rule
+// replace it in place, other rules are put before the inherited ones unless their
+// merge mode says otherwise. Aliases are combined with the child winning, and the
+// default command, history and profile settings are inherited when the child has none.
+// Sync settings are specific to each file and never inherited.
+func Layer(base, child *Config) (*Config, error) {
+	result := &Config{
+		Version:        child.Version,
+		DefaultCommand: child.DefaultCommand,
+		Default:        child.Default,
+		History:        child.History,
+		Sync:           child.Sync,
+		Profiles:       child.Profiles,
+	}
+	if result.Version == "" {
+		result.Version = base.Version
+	}
+	if result.DefaultCommand == "" {
+		result.DefaultCommand = base.DefaultCommand
+		result.Default = base.Default
+	}
+	if result.History == nil {
+		result.History = base.History
+	}
+	if result.Profiles == nil {
+		result.Profiles = base.Profiles
+	}
+
+	if len(base.Aliases)+len(child.Aliases) > 0 {
+		result.Aliases = map[string]string{}
+		for k, v := range base.Aliases {
+			result.Aliases[k] = v
+		}
+		for k, v := range child.Aliases {
+			result.Aliases[k] = v
+		}
+	}
+
+	rules, err := layerRules(base.Rules, child.Rules)
+	if err != nil {
+		return nil, err
+	}
+	result.Rules = rules
+
+	// Layering expanded configs keeps the values as written alongside, in the same order
+	if base.Raw != nil || child.Raw != nil {
+		if result.Raw, err = Layer(rawConfig(base), rawConfig(child)); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func layerRules(base, child []Rule) ([]Rule, error) {
+	inherited := stripMerge(base)
+	var prepend, appendRules []Rule
+
+	for _, rule := range child {
+		idx := -1
+		if rule.Name != "" {
+			idx = slices.IndexFunc(inherited, func(r Rule) bool { return r.Name == rule.Name })
+		}
+		mode := rule.Merge
+		rule.Merge = ""
+
+		switch mode {
+		case "", MergeOverride:
+			if idx >= 0 {
+				inherited[idx] = rule
+				continue
+			}
+			if mode == MergeOverride {
+				return nil, fmt.Errorf("%s overrides no inherited rule", rule.Label())
+			}
+			prepend = append(prepend, rule)
+		case MergePrepend, MergeAppend:
+			if idx >= 0 {
+				inherited = slices.Delete(inherited, idx, idx+1)
+			}
+			if mode == MergePrepend {
+				prepend = append(prepend, rule)
+			} else {
+				appendRules = append(appendRules, rule)
+			}
+		default:
+			return nil, fmt.Errorf("unknown merge mode %q for %s", mode, rule.Label())
+		}
+	}
+
+	return slices.Concat(prepend, inherited, appendRules), nil
+}
+
+// extendsPath resolves an extends entry: "default" is the main config file, entries
+// containing a path separator or a config file extension are paths relative to the
+// extending file, and anything else is a profile name.
+func extendsPath(name, configPath string) (string, error) {
+	switch {
+	case name == DefaultProfile:
+		return GetConfigPath("")
+	case strings.ContainsRune(name, '/') || hasConfigExt(name):
+		if strings.HasPrefix(name, "~/") {
+			home, err := UserHomeDir()
+			if err != nil {
+				return "", fmt.Errorf("failed to get user home dir: %w", err)
+			}
+			return filepath.Join(home, name[2:]), nil
+		}
+		if filepath.IsAbs(name) {
+			return name, nil
+		}
+		return filepath.Join(filepath.Dir(configPath), name), nil
+	default:
+		return GetConfigPathWithProfile("", name)
+	}
+}
+
+// stripMerge returns a copy of rules without merge modes, which only apply to the file they are written in
+func stripMerge(rules []Rule) []Rule {
+	if rules == nil {
+		return nil
+	}
+	stripped := slices.Clone(rules)
+	for i := range stripped {
+		stripped[i].Merge = ""
+	}
+	return stripped
+}
+
+// sourceSet collects the paths a config was resolved from. A nil set collects nothing.
+type sourceSet struct {
+	paths []string
+}
+
+func (s *sourceSet) add(path string) {
+	if s == nil {
+		return
+	}
+	if p := absPath(path); !slices.Contains(s.paths, p) {
+		s.paths = append(s.paths, p)
+	}
+}
+
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// formatChain renders an inheritance chain for error messages
+func formatChain(chain []string) string {
+	names := make([]string, len(chain))
+	for i, p := range chain {
+		names[i] = strings.TrimSuffix(filepath.Base(p), filepath.Ext(p))
+	}
+	return strings.Join(names, " -> ")
+}
+
+func hasConfigExt(name string) bool {
+	_, ok := TrimConfigExt(name)
+	return ok
+}
@@ -0,0 +1,416 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is the file format of a config file, chosen by its extension
+type Format string
+
+const (
+	FormatYAML  Format = "yaml"
+	FormatTOML  Format = "toml"
+	FormatJSON  Format = "json"
+	FormatJSONC Format = "jsonc" // JSON with comments and trailing commas
+)
+
+// configExts are the extensions of config files, in the order they are looked up
+var configExts = []string{".yml", ".yaml", ".toml", ".json", ".jsonc"}
+
+// FormatOf returns the format of a config file from its extension. Files with an
+// unknown extension are YAML.
+func FormatOf(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		return FormatTOML
+	case ".json":
+		return FormatJSON
+	case ".jsonc":
+		return FormatJSONC
+	}
+	return FormatYAML
+}
+
+// ParseFormat parses a format name as given on the command line
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(strings.TrimPrefix(name, ".")) {
+	case "yaml", "yml":
+		return FormatYAML, nil
+	case "toml":
+		return FormatTOML, nil
+	case "json":
+		return FormatJSON, nil
+	case "jsonc":
+		return FormatJSONC, nil
+	}
+	return "", fmt.Errorf("unknown config format %q (use yaml, toml, json or jsonc)", name)
+}
+
+// Ext returns the file extension used for new files of the format
+func (f Format) Ext() string {
+	if f == FormatYAML {
+		return ".yml"
+	}
+	return "." + string(f)
+}
+
+// TrimConfigExt returns a file name without its config extension, and false if
+// the name does not have one
+func TrimConfigExt(name string) (string, bool) {
+	ext := filepath.Ext(name)
+	if !slices.Contains(configExts, strings.ToLower(ext)) {
+		return name, false
+	}
+	return strings.TrimSuffix(name, ext), true
+}
+
+// findConfigFile returns the existing config file named base with any of the
+// supported extensions, or base.yml if there is none
+func findConfigFile(base string) string {
+	for _, ext := range configExts {
+		if info, err := os.Stat(base + ext); err == nil && !info.IsDir() {
+			return base + ext
+		}
+	}
+	return base + ".yml"
+}
+
+// ToYAML converts a config document to YAML, so that every format goes through the
+// same migrations and decoding
+func ToYAML(data []byte, format Format) ([]byte, error) {
+	var value map[string]any
+	switch format {
+	case FormatYAML:
+		return data, nil
+	case FormatTOML:
+		if err := toml.Unmarshal(data, &value); err != nil {
+			return nil, err
+		}
+	case FormatJSON, FormatJSONC:
+		if err := json.Unmarshal(stripJSONC(data), &value); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown config format %q", format)
+	}
+	if value == nil {
+		return nil, nil
+	}
+	return yaml.Marshal(value)
+}
+
+// Decode parses a config document in the given format, migrating it to the
+// current schema version
+func Decode(data []byte, format Format) (*Config, error) {
+	converted, err := ToYAML(data, format)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(converted)
+}
+
+// Encode renders cfg in the given format. Keys keep the order of the Config
+// fields; TOML moves tables after the plain values as the format requires.
+func Encode(cfg *Config, format Format) ([]byte, error) {
+	var root yaml.Node
+	if err := root.Encode(cfg); err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case FormatYAML:
+		return EncodeDocument(&yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{&root}}, defaultIndent)
+	case FormatTOML:
+		var b strings.Builder
+		if err := writeTOMLTable(&b, nil, &root); err != nil {
+			return nil, err
+		}
+		return []byte(strings.TrimPrefix(b.String(), "\n")), nil
+	case FormatJSON, FormatJSONC:
+		var b strings.Builder
+		if err := writeJSON(&b, &root, ""); err != nil {
+			return nil, err
+		}
+		b.WriteString("\n")
+		return []byte(b.String()), nil
+	}
+	return nil, fmt.Errorf("unknown config format %q", format)
+}
+
+// writeTOMLTable writes the entries of a mapping: plain values first, then
+// sub-tables as [path] and lists of mappings as [[path]]
+func writeTOMLTable(b *strings.Builder, path []string, n *yaml.Node) error {
+	var tables, arrays []int
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		value := resolveAlias(n.Content[i+1])
+		switch {
+		case value.Kind == yaml.MappingNode:
+			tables = append(tables, i)
+		case isTableArray(value):
+			arrays = append(arrays, i)
+		case value.Tag == "!!null":
+			// TOML has no null, a missing key means the same
+		default:
+			s, err := tomlValue(value)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(b, "%s = %s\n", tomlKey(n.Content[i].Value), s)
+		}
+	}
+
+	for _, i := range tables {
+		p := append(slices.Clone(path), n.Content[i].Value)
+		table := resolveAlias(n.Content[i+1])
+		// A table holding only other tables is implied by their headers
+		if len(table.Content) == 0 || hasPlainValues(table) {
+			fmt.Fprintf(b, "\n[%s]\n", tomlPath(p))
+		}
+		if err := writeTOMLTable(b, p, table); err != nil {
+			return err
+		}
+	}
+	for _, i := range arrays {
+		p := append(slices.Clone(path), n.Content[i].Value)
+		for _, item := range resolveAlias(n.Content[i+1]).Content {
+			fmt.Fprintf(b, "\n[[%s]]\n", tomlPath(p))
+			if err := writeTOMLTable(b, p, resolveAlias(item)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// hasPlainValues reports whether a mapping has values written as key = value
+func hasPlainValues(n *yaml.Node) bool {
+	for i := 1; i < len(n.Content); i += 2 {
+		if v := resolveAlias(n.Content[i]); v.Kind != yaml.MappingNode && !isTableArray(v) {
+			return true
+		}
+	}
+	return false
+}
+
+func isTableArray(n *yaml.Node) bool {
+	if n.Kind != yaml.SequenceNode || len(n.Content) == 0 {
+		return false
+	}
+	for _, item := range n.Content {
+		if resolveAlias(item).Kind != yaml.MappingNode {
+			return false
+		}
+	}
+	return true
+}
+
+// tomlValue renders a value inline
+func tomlValue(n *yaml.Node) (string, error) {
+	n = resolveAlias(n)
+	switch n.Kind {
+	case yaml.SequenceNode:
+		items := make([]string, len(n.Content))
+		for i, item := range n.Content {
+			s, err := tomlValue(item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = s
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	case yaml.MappingNode:
+		items := make([]string, 0, len(n.Content)/2)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			s, err := tomlValue(n.Content[i+1])
+			if err != nil {
+				return "", err
+			}
+			items = append(items, tomlKey(n.Content[i].Value)+" = "+s)
+		}
+		return "{ " + strings.Join(items, ", ") + " }", nil
+	}
+
+	switch n.Tag {
+	case "!!bool", "!!int", "!!float":
+		return n.Value, nil
+	case "!!null":
+		return "", fmt.Errorf("null values cannot be written as TOML")
+	}
+	return quoteString(n.Value), nil
+}
+
+var bareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func tomlKey(key string) string {
+	if bareKey.MatchString(key) {
+		return key
+	}
+	return quoteString(key)
+}
+
+func tomlPath(path []string) string {
+	keys := make([]string, len(path))
+	for i, k := range path {
+		keys[i] = tomlKey(k)
+	}
+	return strings.Join(keys, ".")
+}
+
+// writeJSON writes a node as indented JSON, keeping the key order
+func writeJSON(b *strings.Builder, n *yaml.Node, indent string) error {
+	n = resolveAlias(n)
+	inner := indent + "  "
+	switch n.Kind {
+	case yaml.MappingNode:
+		if len(n.Content) == 0 {
+			b.WriteString("{}")
+			return nil
+		}
+		b.WriteString("{\n")
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			b.WriteString(inner + quoteString(n.Content[i].Value) + ": ")
+			if err := writeJSON(b, n.Content[i+1], inner); err != nil {
+				return err
+			}
+			if i+2 < len(n.Content) {
+				b.WriteString(",")
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString(indent + "}")
+	case yaml.SequenceNode:
+		if len(n.Content) == 0 {
+			b.WriteString("[]")
+			return nil
+		}
+		// Lists of plain values stay on one line
+		if !slices.ContainsFunc(n.Content, func(item *yaml.Node) bool { return resolveAlias(item).Kind != yaml.ScalarNode }) {
+			items := make([]string, len(n.Content))
+			for i, item := range n.Content {
+				var s strings.Builder
+				if err := writeJSON(&s, item, inner); err != nil {
+					return err
+				}
+				items[i] = s.String()
+			}
+			b.WriteString("[" + strings.Join(items, ", ") + "]")
+			return nil
+		}
+		b.WriteString("[\n")
+		for i, item := range n.Content {
+			b.WriteString(inner)
+			if err := writeJSON(b, item, inner); err != nil {
+				return err
+			}
+			if i+1 < len(n.Content) {
+				b.WriteString(",")
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString(indent + "]")
+	case yaml.ScalarNode:
+		switch n.Tag {
+		case "!!bool", "!!int", "!!float":
+			b.WriteString(n.Value)
+		case "!!null":
+			b.WriteString("null")
+		default:
+			b.WriteString(quoteString(n.Value))
+		}
+	default:
+		return fmt.Errorf("cannot write YAML node kind %d as JSON", n.Kind)
+	}
+	return nil
+}
+
+func resolveAlias(n *yaml.Node) *yaml.Node {
+	for n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	return n
+}
+
+// quoteString quotes a string for JSON and TOML, whose basic strings share the escapes
+func quoteString(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// stripJSONC removes // and /* */ comments and trailing commas outside of strings
+func stripJSONC(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	inString, escaped := false, false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		if inString {
+			out = append(out, c)
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch {
+		case c == '"':
+			inString = true
+			out = append(out, c)
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i+1 < len(data) && data[i+1] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			end := bytes.Index(data[i+2:], []byte("*/"))
+			if end < 0 {
+				i = len(data)
+			} else {
+				i += end + 3
+			}
+		case c == ',' && closesNext(data[i+1:]):
+			// Trailing comma
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// closesNext reports whether the next token, skipping whitespace and comments,
+// closes an object or array
+func closesNext(rest []byte) bool {
+	for i := 0; i < len(rest); i++ {
+		switch c := rest[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+		case c == '/' && i+1 < len(rest) && rest[i+1] == '/':
+			for i+1 < len(rest) && rest[i+1] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(rest) && rest[i+1] == '*':
+			end := bytes.Index(rest[i+2:], []byte("*/"))
+			if end < 0 {
+				return false
+			}
+			i += end + 3
+		default:
+			return c == '}' || c == ']'
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// includePaths expands include entries to files. Entries are taken in the order
+// they are listed and glob matches in name order, so the result is deterministic.
+// A glob matching nothing is fine, a missing plain path is an error. The directories
+// globs are matched in go to sources, as files added to them change the result.
+func includePaths(entries []string, configPath string, sources *sourceSet) ([]string, error) {
+	var paths []string
+	for _, entry := range entries {
+		pattern := entry
+		if strings.HasPrefix(pattern, "~/") {
+			home, err := UserHomeDir()
+			if err != nil {
+				return nil, fmt.Errorf("failed to get user home dir: %w", err)
+			}
+			pattern = filepath.Join(home, pattern[2:])
+		} else if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(configPath), pattern)
+		}
+
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid include pattern %q in %s: %w", entry, configPath, err)
+		}
+		if len(matches) == 0 && !hasGlobMeta(entry) {
+			return nil, fmt.Errorf("file %q included by %s not found", entry, configPath)
+		}
+		slices.Sort(matches)
+		if hasGlobMeta(entry) {
+			for _, dir := range globDirs(pattern) {
+				sources.add(dir)
+			}
+		}
+
+		for _, m := range matches {
+			if info, err := os.Stat(m); err != nil || info.IsDir() {
+				continue
+			}
+			if !slices.Contains(paths, m) {
+				paths = append(paths, m)
+			}
+		}
+	}
+	return paths, nil
+}
+
+func hasGlobMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[")
+}
+
+// globDirs returns the existing directories a glob pattern is matched in, including
+// those whose entries decide which directories a pattern like conf.d/*/*.yml looks in
+func globDirs(pattern string) []string {
+	dir := filepath.Dir(pattern)
+	if !hasGlobMeta(dir) {
+		return []string{dir}
+	}
+	matches, _ := filepath.Glob(dir)
+	return append(globDirs(dir), matches...)
+}
+
+// applyIncludes returns cfg with the files it includes merged in. Their rules are
+// appended after the rules of cfg, in include order, so the including file's own
+// rules match first. Aliases and settings of the including file win; among included
+// files the later one wins. Included files may include others but not extend.
+func applyIncludes(cfg *Config, configPath string, chain []string, sources *sourceSet) (*Config, error) {
+	if len(cfg.Include) == 0 {
+		return cfg, nil
+	}
+
+	paths, err := includePaths(cfg.Include, configPath, sources)
+	if err != nil {
+		return nil, err
+	}
+
+	result := *cfg
+	result.Include = nil
+	result.Rules = slices.Clone(cfg.Rules)
+	aliases := map[string]string{}
+
+	for _, p := range paths {
+		key := absPath(p)
+		if slices.Contains(chain, key) {
+			return nil, fmt.Errorf("config include cycle: %s", formatChain(append(slices.Clone(chain), key)))
+		}
+
+		sources.add(p)
+		included, err := LoadConfig(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s included by %s: %w", p, configPath, err)
+		}
+		if len(included.Extends) > 0 {
+			return nil, fmt.Errorf("%s: included files cannot use extends", p)
+		}
+		if included, err = applyIncludes(included, p, append(slices.Clone(chain), key), sources); err != nil {
+			return nil, err
+		}
+
+		result.Rules = append(result.Rules, included.Rules...)
+		for k, v := range included.Aliases {
+			aliases[k] = v
+		}
+		if cfg.DefaultCommand == "" && included.DefaultCommand != "" {
+			result.DefaultCommand = included.DefaultCommand
+			result.Default = included.Default
+		}
+		if cfg.History == nil && included.History != nil {
+			result.History = included.History
+		}
+	}
+
+	if len(aliases) > 0 {
+		for k, v := range cfg.Aliases {
+			aliases[k] = v
+		}
+		result.Aliases = aliases
+	}
+	return &result, nil
+}
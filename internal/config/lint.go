@@ -0,0 +1,265 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"reflect"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Severity tells whether a problem breaks the config or only looks wrong
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Problem is an issue found by Lint
+type Problem struct {
+	Check    string // Name of the check that found it, e.g. "shadowed"
+	Severity Severity
+	Rule     *Rule // The rule the problem is about, nil if it is about the config as a whole
+	Message  string
+}
+
+func (p Problem) String() string {
+	if p.Rule == nil {
+		return fmt.Sprintf("%s: %s (%s)", p.Severity, p.Message, p.Check)
+	}
+	return fmt.Sprintf("%s: %s: %s (%s)", p.Rule.Label(), p.Severity, p.Message, p.Check)
+}
+
+// LookPath is a variable to allow mocking in tests
+var LookPath = exec.LookPath
+
+// knownOS are the values of runtime.GOOS a rule can be limited to
+var knownOS = []string{
+	"aix", "android", "darwin", "dragonfly", "freebsd", "hurd", "illumos", "ios", "js",
+	"linux", "netbsd", "openbsd", "plan9", "solaris", "wasip1", "windows", "zos",
+}
+
+// osNames maps common names of operating systems to their runtime.GOOS value
+var osNames = map[string]string{
+	"mac":   "darwin",
+	"macos": "darwin",
+	"osx":   "darwin",
+	"win":   "windows",
+	"win32": "windows",
+	"win64": "windows",
+}
+
+// shellWords are builtins and syntax that can start a command without a binary
+var shellWords = []string{"cd", "command", "eval", "exec", "export", "source", ".", "set", "if", "for", "while", "case", "{", "(", "!"}
+
+// envAssignment matches a NAME=value word in front of a command
+var envAssignment = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`)
+
+// Lint checks cfg and returns every problem found, in rule order: schema
+// violations and invalid command templates are errors; rules without conditions,
+// rules shadowed by an earlier rule, duplicate names, unknown OS values and
+// commands whose binary is not on $PATH are warnings.
+func Lint(cfg *Config) []Problem {
+	problems := lintSchema(cfg)
+
+	names := make(map[string]int)
+	for i := range cfg.Rules {
+		rule := &cfg.Rules[i]
+
+		if !rule.hasConditions() {
+			problems = append(problems, Problem{"no-conditions", SeverityWarning, rule,
+				"has no extensions, regex, mime, scheme or script, so it never matches"})
+		} else if j := shadowedBy(cfg.Rules[:i], rule); j >= 0 {
+			problems = append(problems, Problem{"shadowed", SeverityWarning, rule,
+				fmt.Sprintf("is never reached, %s matches everything it matches first", ruleRef(cfg.Rules, j))})
+		}
+
+		if rule.Name != "" {
+			if j, ok := names[rule.Name]; ok {
+				problems = append(problems, Problem{"duplicate-name", SeverityWarning, rule,
+					fmt.Sprintf("has the same name as %s", ruleRef(cfg.Rules, j))})
+			} else {
+				names[rule.Name] = i
+			}
+		}
+
+		for _, name := range rule.OS {
+			if slices.Contains(knownOS, strings.ToLower(name)) {
+				continue
+			}
+			message := fmt.Sprintf("unknown OS %q", name)
+			if goos, ok := osNames[strings.ToLower(name)]; ok {
+				message += fmt.Sprintf(", use %q", goos)
+			}
+			problems = append(problems, Problem{"unknown-os", SeverityWarning, rule, message})
+		}
+
+		if rule.Command != "" {
+			// A binary for another OS is not expected to be installed here
+			checkPath := len(rule.OS) == 0 || slices.ContainsFunc(rule.OS, func(name string) bool {
+				return strings.EqualFold(name, runtime.GOOS)
+			})
+			problems = append(problems, lintCommand(rule, "command", rule.Command, checkPath)...)
+		}
+	}
+
+	if cfg.DefaultCommand != "" {
+		problems = append(problems, lintCommand(nil, "default_command", cfg.DefaultCommand, true)...)
+	}
+	return problems
+}
+
+// lintSchema reports every schema violation, naming fields as they are written
+// in the config file
+func lintSchema(cfg *Config) []Problem {
+	validate := newValidator()
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	err := validate.Struct(cfg)
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if !ok {
+		if err != nil {
+			return []Problem{{"schema", SeverityError, nil, err.Error()}}
+		}
+		return nil
+	}
+
+	problems := make([]Problem, len(validationErrors))
+	for i, e := range validationErrors {
+		field := strings.TrimPrefix(e.Namespace(), "Config.")
+		rule := failedRule(cfg, e.StructNamespace())
+		if rule != nil {
+			field = e.Field()
+		}
+
+		var message string
+		switch e.Tag() {
+		case "required":
+			message = fmt.Sprintf("%s is required", field)
+		case "is-regex":
+			message = fmt.Sprintf("%s is not a valid regular expression: %v", field, ValidateRegex(e.Value().(string)))
+		case "oneof":
+			message = fmt.Sprintf("%s must be one of %s, not %q", field, strings.ReplaceAll(e.Param(), " ", ", "), e.Value())
+		default:
+			message = fmt.Sprintf("%s is %s", field, e.Tag())
+		}
+		problems[i] = Problem{"schema", SeverityError, rule, message}
+	}
+	return problems
+}
+
+// templateData has the fields of executor.CommandData, which commands are rendered with
+type templateData struct {
+	File string
+	Dir  string
+	Base string
+	Name string
+	Ext  string
+}
+
+// lintCommand checks that a command is a valid template and, if checkPath is
+// set, that the program it runs is installed
+func lintCommand(rule *Rule, field, command string, checkPath bool) []Problem {
+	tmpl, err := template.New("command").Parse(command)
+	if err == nil {
+		// Unknown fields only show up when the template is executed
+		err = tmpl.Execute(io.Discard, templateData{})
+	}
+	if err != nil {
+		return []Problem{{"template", SeverityError, rule, fmt.Sprintf("%s is not a valid template: %v", field, err)}}
+	}
+
+	if !checkPath {
+		return nil
+	}
+	if name := commandBinary(command); name != "" {
+		if _, err := LookPath(name); err != nil {
+			return []Problem{{"command-not-found", SeverityWarning, rule, fmt.Sprintf("%s runs %q, which is not on $PATH", field, name)}}
+		}
+	}
+	return nil
+}
+
+// commandBinary returns the program a command runs, or "" if it cannot be told
+// without running a shell
+func commandBinary(command string) string {
+	fields := strings.Fields(ExpandValue(command))
+	for len(fields) > 0 && envAssignment.MatchString(fields[0]) {
+		fields = fields[1:]
+	}
+	if len(fields) == 0 {
+		return ""
+	}
+	name := fields[0]
+	if strings.ContainsAny(name, "${}()`'\"|;&<>*?") || slices.Contains(shellWords, name) {
+		return ""
+	}
+	return name
+}
+
+func (r *Rule) hasConditions() bool {
+	return len(r.Extensions) > 0 || r.Regex != "" || r.Mime != "" || r.Scheme != "" || r.Script != ""
+}
+
+// shadowedBy returns the index of the first earlier rule that stops matching
+// and matches everything rule matches, or -1
+func shadowedBy(earlier []Rule, rule *Rule) int {
+	for i := range earlier {
+		if !earlier[i].Fallthrough && covers(&earlier[i], rule) {
+			return i
+		}
+	}
+	return -1
+}
+
+// covers reports whether a matches every file or URL that b matches. It follows
+// the matcher: a scheme rule only matches by scheme, otherwise any matching
+// extension, regex, MIME type or script is enough. Only conditions a shares
+// with b are taken into account, so it errs on the side of false.
+func covers(a, b *Rule) bool {
+	if len(a.OS) > 0 && (len(b.OS) == 0 || !subsetFold(b.OS, a.OS)) {
+		return false
+	}
+	if b.Scheme != "" || a.Scheme != "" {
+		return strings.EqualFold(a.Scheme, b.Scheme)
+	}
+	return subsetFold(b.Extensions, a.Extensions) &&
+		(b.Regex == "" || b.Regex == a.Regex) &&
+		(b.Mime == "" || b.Mime == a.Mime) &&
+		(b.Script == "" || b.Script == a.Script)
+}
+
+// subsetFold reports whether every value in sub is in set, ignoring case
+func subsetFold(sub, set []string) bool {
+	for _, s := range sub {
+		if !slices.ContainsFunc(set, func(v string) bool { return strings.EqualFold(s, v) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// ruleRef names the rule at index i for a message about another rule
+func ruleRef(rules []Rule, i int) string {
+	ref := fmt.Sprintf("rule %d", i+1)
+	if rules[i].Name != "" {
+		ref = fmt.Sprintf("rule %q", rules[i].Name)
+	}
+	if rules[i].Source != nil {
+		ref += " (" + rules[i].Source.String() + ")"
+	}
+	return ref
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package config
+
+import "os"
+
+// lockFile always succeeds where flock is unavailable; writes are still atomic,
+// only concurrent edits are not serialised
+func lockFile(f *os.File) (bool, error) {
+	return true, nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
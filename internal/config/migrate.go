@@ -0,0 +1,238 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentVersion is the config schema version written by this build
+const CurrentVersion = "2"
+
+// Migration upgrades a config document from one schema version to the next
+type Migration struct {
+	From        int    // Version the migration applies to; it produces From+1
+	Description string // What the migration changes, shown by :config migrate
+	// Apply rewrites the top-level mapping of the document in place and
+	// describes each change it made
+	Apply func(doc *yaml.Node) []string
+}
+
+// migrations holds one entry per schema version, oldest first
+var migrations = []Migration{
+	{
+		From:        1,
+		Description: "rename default to default_command and move ~/.config/entry paths in extends, include and sync to ~/.config/via",
+		Apply:       migrateV1,
+	},
+}
+
+// LegacyConfigDir is the config directory used before the project was renamed
+const LegacyConfigDir = ".config/entry"
+
+// LegacyConfigPath returns where the main config lived before the project was renamed
+func LegacyConfigPath() (string, error) {
+	home, err := UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home dir: %w", err)
+	}
+	return filepath.Join(home, filepath.FromSlash(LegacyConfigDir), "config.yml"), nil
+}
+
+// ParseVersion returns the schema version of a config document. Documents
+// without a version predate versioning and are version 1.
+func ParseVersion(version string) (int, error) {
+	if version == "" {
+		return 1, nil
+	}
+	n, err := strconv.Atoi(version)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("unsupported config version %q", version)
+	}
+	return n, nil
+}
+
+// MigrateDocument parses a config document and upgrades it to CurrentVersion.
+// It returns the migrated document and a description of each change, which is
+// empty if the document was already current. Comments and key order are kept.
+func MigrateDocument(data []byte) (*yaml.Node, []string, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, nil, err
+	}
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return &root, nil, nil
+	}
+	doc := root.Content[0]
+
+	versionNode := mappingValue(doc, "version")
+	raw := ""
+	if versionNode != nil {
+		raw = versionNode.Value
+	}
+	version, err := ParseVersion(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	current, _ := ParseVersion(CurrentVersion)
+	if version > current {
+		return nil, nil, fmt.Errorf("config version %d is newer than this version of vv supports (%d)", version, current)
+	}
+	if version == current {
+		return &root, nil, nil
+	}
+
+	var changes []string
+	for _, m := range migrations {
+		if m.From >= version {
+			changes = append(changes, m.Apply(doc)...)
+		}
+	}
+
+	if versionNode == nil {
+		versionNode = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str"}
+		key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "version"}
+		if len(doc.Content) > 0 {
+			// Keep a comment at the top of the file above the new key
+			key.HeadComment, doc.Content[0].HeadComment = doc.Content[0].HeadComment, ""
+		}
+		doc.Content = append([]*yaml.Node{key, versionNode}, doc.Content...)
+	}
+	versionNode.Value = CurrentVersion
+	versionNode.Tag = "!!str"
+	versionNode.Style = yaml.DoubleQuotedStyle
+	changes = append(changes, fmt.Sprintf("version %d -> %s", version, CurrentVersion))
+
+	return &root, changes, nil
+}
+
+// legacyPath matches home-relative paths into the old config directory
+var legacyPath = regexp.MustCompile(`(~|\$HOME|\$\{HOME\})/\.config/entry\b`)
+
+func migrateV1(doc *yaml.Node) []string {
+	var changes []string
+
+	if idx := mappingIndex(doc, "default"); idx >= 0 {
+		key := doc.Content[idx]
+		if mappingIndex(doc, "default_command") >= 0 {
+			doc.Content = append(doc.Content[:idx], doc.Content[idx+2:]...)
+			changes = append(changes, fmt.Sprintf("line %d: removed default, default_command is already set", key.Line))
+		} else {
+			key.Value = "default_command"
+			changes = append(changes, fmt.Sprintf("line %d: renamed default to default_command", key.Line))
+		}
+	}
+
+	// Only fields that name config files move; commands, env values and scripts
+	// may refer to files that stay where they are
+	for _, field := range legacyPathFields(doc) {
+		walkScalars(field, func(n *yaml.Node) {
+			if updated := legacyPath.ReplaceAllString(n.Value, "$1/.config/via"); updated != n.Value {
+				changes = append(changes, fmt.Sprintf("line %d: %s -> %s", n.Line, n.Value, updated))
+				n.Value = updated
+			}
+		})
+	}
+	return changes
+}
+
+// legacyPathFields returns the values of the fields holding paths into the config directory
+func legacyPathFields(doc *yaml.Node) []*yaml.Node {
+	var fields []*yaml.Node
+	for _, key := range []string{"extends", "include"} {
+		if n := mappingValue(doc, key); n != nil {
+			fields = append(fields, n)
+		}
+	}
+	if sync := mappingValue(doc, "sync"); sync != nil && sync.Kind == yaml.MappingNode {
+		for _, key := range []string{"path", "repo"} {
+			if n := mappingValue(sync, key); n != nil {
+				fields = append(fields, n)
+			}
+		}
+	}
+	return fields
+}
+
+// walkScalars calls fn for every scalar value below n, skipping mapping keys
+func walkScalars(n *yaml.Node, fn func(*yaml.Node)) {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		fn(n)
+	case yaml.MappingNode:
+		for i := 1; i < len(n.Content); i += 2 {
+			walkScalars(n.Content[i], fn)
+		}
+	case yaml.SequenceNode, yaml.DocumentNode:
+		for _, c := range n.Content {
+			walkScalars(c, fn)
+		}
+	}
+}
+
+// mappingIndex returns the index of key in a mapping node's content, or -1
+func mappingIndex(n *yaml.Node, key string) int {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	if i := mappingIndex(n, key); i >= 0 {
+		return n.Content[i+1]
+	}
+	return nil
+}
+
+// BackupDir returns the directory holding backups of the given config file
+func BackupDir(configPath string) string {
+	return filepath.Join(filepath.Dir(configPath), "backups")
+}
+
+// BackupOutdated copies a config file written with an older schema version into
+// BackupDir before it is overwritten, so a migration can be undone by hand. It
+// returns the backup path, or "" if the file is missing or already current.
+func BackupOutdated(configPath string) (string, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to read config for backup: %w", err)
+	}
+
+	var header struct {
+		Version string `yaml:"version"`
+	}
+	label := "unknown" // Unparsable files are backed up too, they are about to be replaced
+	if converted, err := ToYAML(data, FormatOf(configPath)); err == nil && yaml.Unmarshal(converted, &header) == nil {
+		if version, err := ParseVersion(header.Version); err == nil {
+			if current, _ := ParseVersion(CurrentVersion); version >= current {
+				return "", nil
+			}
+			label = strconv.Itoa(version)
+		}
+	}
+
+	name := strings.TrimSuffix(filepath.Base(configPath), filepath.Ext(configPath))
+	backup := filepath.Join(BackupDir(configPath), fmt.Sprintf("%s.v%s%s", name, label, FormatOf(configPath).Ext()))
+	if _, err := os.Stat(backup); err == nil {
+		return backup, nil
+	}
+
+	if err := os.MkdirAll(BackupDir(configPath), 0755); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	if err := WriteFileAtomic(backup, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write backup: %w", err)
+	}
+	return backup, nil
+}
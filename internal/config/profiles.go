@@ -0,0 +1,112 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ProfilesDir returns the directory holding the profile config files
+func ProfilesDir() (string, error) {
+	home, err := UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home dir: %w", err)
+	}
+	return filepath.Join(home, ".config", "via", "profiles"), nil
+}
+
+// ListProfiles returns the names of the existing profiles, sorted
+func ListProfiles() ([]string, error) {
+	dir, err := ProfilesDir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read profiles directory: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if name, ok := TrimConfigExt(entry.Name()); ok && !entry.IsDir() && !strings.HasPrefix(name, ".") {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return slices.Compact(names), nil
+}
+
+// ProfilePath returns the config file of a profile, the main config file for "default"
+func ProfilePath(name string) (string, error) {
+	if name == "" || name == DefaultProfile {
+		return GetConfigPath("")
+	}
+	return GetConfigPathWithProfile("", name)
+}
+
+// ValidateProfileName checks that a name can be used for a new profile
+func ValidateProfileName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("profile name must not be empty")
+	case name == DefaultProfile:
+		return fmt.Errorf("%q is the main config and cannot be used as a profile name", DefaultProfile)
+	case strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, "."):
+		return fmt.Errorf("invalid profile name %q", name)
+	}
+	return nil
+}
+
+// ActiveProfilePath returns the file remembering the profile selected with "profile use"
+func ActiveProfilePath() (string, error) {
+	home, err := UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home dir: %w", err)
+	}
+	return filepath.Join(home, ".config", "via", "active_profile"), nil
+}
+
+// LoadActiveProfile returns the profile selected with "profile use", or "" if none is
+func LoadActiveProfile() (string, error) {
+	path, err := ActiveProfilePath()
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to read active profile: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// SaveActiveProfile remembers the profile to use by default. "" or "default" selects the main config.
+func SaveActiveProfile(name string) error {
+	path, err := ActiveProfilePath()
+	if err != nil {
+		return err
+	}
+
+	if name == "" || name == DefaultProfile {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to reset active profile: %w", err)
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(name+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to save active profile: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// ProjectConfigName is the name of project-local config files
+const ProjectConfigName = ".via.yml"
+
+// FindProjectConfigs returns the project-local config files in dir and its
+// parents, outermost first so that nearer files are layered last.
+func FindProjectConfigs(dir string) []string {
+	var found []string
+	for d := absPath(dir); ; d = filepath.Dir(d) {
+		p := filepath.Join(d, ProjectConfigName)
+		if info, err := os.Stat(p); err == nil && !info.IsDir() {
+			found = append(found, p)
+		}
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+	slices.Reverse(found)
+	return found
+}
+
+// ApplyProjectConfigs layers the project-local config files found above dir over
+// cfg and returns the result with the paths of the files that were applied.
+// Sync and profile settings always come from cfg.
+func ApplyProjectConfigs(cfg *Config, dir string) (*Config, []string, error) {
+	paths := FindProjectConfigs(dir)
+	result := cfg
+	for _, p := range paths {
+		project, err := LoadResolvedConfig(p)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load project config %s: %w", p, err)
+		}
+		if result, err = Layer(result, project); err != nil {
+			return nil, nil, fmt.Errorf("failed to layer project config %s: %w", p, err)
+		}
+	}
+
+	if len(paths) > 0 {
+		result.Extends = nil
+		result.Sync = cfg.Sync
+		result.Profiles = cfg.Profiles
+		result.Rules = stripMerge(result.Rules)
+		if result.Raw != nil {
+			result.Raw.Extends = nil
+			result.Raw.Sync = rawConfig(cfg).Sync
+			result.Raw.Profiles = cfg.Profiles
+			result.Raw.Rules = stripMerge(result.Raw.Rules)
+		}
+	}
+	return result, paths, nil
+}
@@ -0,0 +1,261 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Built-in operations, handled by the server itself
+const (
+	OpPing = "ping"
+	OpStop = "stop"
+)
+
+// Request is sent by the CLI for one invocation. Requests are handled in the
+// working directory and environment of the caller.
+type Request struct {
+	Op     string          `json:"op"`
+	Dir    string          `json:"dir"`
+	Env    []string        `json:"env"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// Response carries the result of a request, or the error it failed with
+type Response struct {
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// Status is the result of OpPing
+type Status struct {
+	PID     int       `json:"pid"`
+	Started time.Time `json:"started"`
+	Served  int       `json:"served"`
+}
+
+// Handler answers a request with a value to send back as JSON
+type Handler func(req *Request) (any, error)
+
+// ErrUnavailable is returned by Call when no daemon answers on the socket
+var ErrUnavailable = errors.New("daemon is not running")
+
+// Timeouts for connecting to the daemon and for a whole request, can be changed for testing
+var (
+	DialTimeout    = 200 * time.Millisecond
+	RequestTimeout = 30 * time.Second
+)
+
+// SocketPath returns where the daemon listens: $VIA_DAEMON_SOCKET, via/daemon.sock
+// in $XDG_RUNTIME_DIR, or a directory of the current user in the temp dir
+func SocketPath() string {
+	if path := os.Getenv("VIA_DAEMON_SOCKET"); path != "" {
+		return path
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "via", "daemon.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("via-%d", os.Getuid()), "daemon.sock")
+}
+
+// NewRequest builds a request for op from the current working directory and environment
+func NewRequest(op string, params any) (*Request, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get working directory: %w", err)
+	}
+	req := &Request{Op: op, Dir: dir, Env: os.Environ()}
+	if params != nil {
+		if req.Params, err = json.Marshal(params); err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+// Call sends req to the daemon listening on path and decodes its result into
+// result. It returns an error wrapping ErrUnavailable if the daemon could not be
+// reached or did not answer, so the caller can do the work itself.
+func Call(path string, req *Request, result any) error {
+	conn, err := net.DialTimeout("unix", path, DialTimeout)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(RequestTimeout))
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+
+	if resp.Error != "" {
+		return errors.New(resp.Error)
+	}
+	if result == nil || len(resp.Result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(resp.Result, result)
+}
+
+// Listen creates the socket at path, readable by the current user only. A socket
+// left behind by a daemon that is gone is replaced.
+func Listen(path string) (net.Listener, error) {
+	if err := Call(path, &Request{Op: OpPing}, nil); err == nil {
+		return nil, fmt.Errorf("daemon already running on %s", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create socket directory: %w", err)
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("failed to restrict socket permissions: %w", err)
+	}
+	return ln, nil
+}
+
+// Server answers requests on a listener, one at a time
+type Server struct {
+	handler Handler
+	started time.Time
+
+	mu       sync.Mutex // Held while a request runs, it changes the working directory and environment
+	served   int
+	listener net.Listener
+	closed   bool
+}
+
+func NewServer(handler Handler) *Server {
+	return &Server{handler: handler, started: time.Now()}
+}
+
+// Serve accepts connections until the listener is closed or a stop request is received
+func (s *Server) Serve(ln net.Listener) error {
+	s.mu.Lock()
+	s.listener = ln
+	closed := s.closed
+	s.mu.Unlock()
+	if closed {
+		return ln.Close()
+	}
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.serveConn(conn)
+	}
+}
+
+// Close stops accepting connections
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(RequestTimeout))
+
+	var req Request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		return
+	}
+
+	resp := s.handle(&req)
+	json.NewEncoder(conn).Encode(resp)
+
+	if req.Op == OpStop {
+		s.Close()
+	}
+}
+
+func (s *Server) handle(req *Request) Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.served++
+
+	var result any
+	var err error
+	switch req.Op {
+	case OpPing:
+		result = Status{PID: os.Getpid(), Started: s.started, Served: s.served}
+	case OpStop:
+	default:
+		result, err = inContext(req, func() (any, error) {
+			return s.handler(req)
+		})
+	}
+	if err != nil {
+		return Response{Error: err.Error()}
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return Response{Error: fmt.Sprintf("failed to encode result: %v", err)}
+	}
+	return Response{Result: data}
+}
+
+// inContext runs fn in the working directory and with the environment of the
+// request, and restores those of the daemon afterwards
+func inContext(req *Request, fn func() (any, error)) (result any, err error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get working directory: %w", err)
+	}
+	env := os.Environ()
+
+	if req.Dir != "" {
+		if err := os.Chdir(req.Dir); err != nil {
+			return nil, fmt.Errorf("failed to change to %s: %w", req.Dir, err)
+		}
+		defer os.Chdir(wd)
+	}
+	if req.Env != nil {
+		setEnv(req.Env)
+		defer setEnv(env)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("daemon failed: %v", r)
+		}
+	}()
+	return fn()
+}
+
+func setEnv(env []string) {
+	os.Clearenv()
+	for _, kv := range env {
+		if k, v, ok := strings.Cut(kv, "="); ok && k != "" {
+			os.Setenv(k, v)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package daemon_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SuzumiyaAoba/via/internal/daemon"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestDaemon(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Daemon Suite")
+}
+
+var _ = Describe("Daemon", func() {
+	var (
+		socket string
+		server *daemon.Server
+		done   chan error
+	)
+
+	// serve starts a server with handler on the socket
+	serve := func(handler daemon.Handler) {
+		ln, err := daemon.Listen(socket)
+		Expect(err).NotTo(HaveOccurred())
+		server = daemon.NewServer(handler)
+		done = make(chan error, 1)
+		go func() { done <- server.Serve(ln) }()
+	}
+
+	BeforeEach(func() {
+		// Socket paths are limited to about 100 bytes, so stay clear of long test directories
+		dir, err := os.MkdirTemp("", "via")
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(os.RemoveAll, dir)
+		socket = filepath.Join(dir, "run", "daemon.sock")
+		server = nil
+	})
+
+	AfterEach(func() {
+		if server != nil {
+			server.Close()
+			Eventually(done).Should(Receive(BeNil()))
+		}
+	})
+
+	It("should handle requests in the directory and environment of the caller", func() {
+		serve(func(req *daemon.Request) (any, error) {
+			wd, err := os.Getwd()
+			return map[string]string{"op": req.Op, "dir": wd, "value": os.Getenv("VIA_DAEMON_TEST")}, err
+		})
+		wd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+
+		callerDir, err := filepath.EvalSymlinks(GinkgoT().TempDir())
+		Expect(err).NotTo(HaveOccurred())
+		req := &daemon.Request{Op: "test", Dir: callerDir, Env: []string{"VIA_DAEMON_TEST=caller"}}
+
+		var result map[string]string
+		Expect(daemon.Call(socket, req, &result)).To(Succeed())
+		Expect(result).To(Equal(map[string]string{"op": "test", "dir": callerDir, "value": "caller"}))
+
+		// The daemon's own are back afterwards
+		Expect(os.Getwd()).To(Equal(wd))
+		_, ok := os.LookupEnv("VIA_DAEMON_TEST")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("should build requests from the current directory and environment", func() {
+		GinkgoT().Setenv("VIA_DAEMON_TEST", "caller")
+
+		req, err := daemon.NewRequest("open", map[string]int{"n": 1})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(req.Op).To(Equal("open"))
+		Expect(req.Dir).To(Equal(must(os.Getwd())))
+		Expect(req.Env).To(ContainElement("VIA_DAEMON_TEST=caller"))
+		Expect(string(req.Params)).To(Equal(`{"n":1}`))
+	})
+
+	It("should return handler errors", func() {
+		serve(func(req *daemon.Request) (any, error) {
+			return nil, errors.New("no rule matched")
+		})
+
+		err := daemon.Call(socket, &daemon.Request{Op: "open"}, nil)
+		Expect(err).To(MatchError("no rule matched"))
+		Expect(errors.Is(err, daemon.ErrUnavailable)).To(BeFalse())
+	})
+
+	It("should report a daemon that is not running as unavailable", func() {
+		err := daemon.Call(socket, &daemon.Request{Op: daemon.OpPing}, nil)
+		Expect(err).To(MatchError(daemon.ErrUnavailable))
+	})
+
+	It("should answer pings and stop on request", func() {
+		serve(func(req *daemon.Request) (any, error) { return nil, nil })
+
+		var status daemon.Status
+		Expect(daemon.Call(socket, &daemon.Request{Op: daemon.OpPing}, &status)).To(Succeed())
+		Expect(status.PID).To(Equal(os.Getpid()))
+		Expect(status.Served).To(Equal(1))
+
+		Expect(daemon.Call(socket, &daemon.Request{Op: daemon.OpStop}, nil)).To(Succeed())
+		Eventually(done).Should(Receive(BeNil()))
+		server = nil
+		Expect(daemon.Call(socket, &daemon.Request{Op: daemon.OpPing}, nil)).To(MatchError(daemon.ErrUnavailable))
+	})
+
+	It("should refuse to start twice and replace stale sockets", func() {
+		serve(func(req *daemon.Request) (any, error) { return nil, nil })
+		_, err := daemon.Listen(socket)
+		Expect(err).To(MatchError(ContainSubstring("already running")))
+
+		server.Close()
+		Eventually(done).Should(Receive(BeNil()))
+		server = nil
+
+		// A socket file nothing listens on
+		Expect(os.WriteFile(socket, nil, 0600)).To(Succeed())
+		ln, err := daemon.Listen(socket)
+		Expect(err).NotTo(HaveOccurred())
+		ln.Close()
+	})
+
+	It("should only let the current user connect", func() {
+		serve(func(req *daemon.Request) (any, error) { return nil, nil })
+
+		info, err := os.Stat(socket)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+	})
+})
+
+var _ = Describe("SocketPath", func() {
+	It("should prefer VIA_DAEMON_SOCKET", func() {
+		GinkgoT().Setenv("VIA_DAEMON_SOCKET", "/tmp/custom.sock")
+		Expect(daemon.SocketPath()).To(Equal("/tmp/custom.sock"))
+	})
+
+	It("should use XDG_RUNTIME_DIR", func() {
+		GinkgoT().Setenv("VIA_DAEMON_SOCKET", "")
+		GinkgoT().Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+		Expect(daemon.SocketPath()).To(Equal("/run/user/1000/via/daemon.sock"))
+	})
+})
+
+func must[T any](v T, err error) T {
+	Expect(err).NotTo(HaveOccurred())
+	return v
+}
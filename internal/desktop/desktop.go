@@ -0,0 +1,271 @@
+package desktop
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// EntryID is the name of the desktop entry that runs vv
+const EntryID = "via.desktop"
+
+// HandlerEnv is set to 1 in the environment of vv when the desktop entry runs it.
+// vv must not hand what it was given back to xdg-open then, which would run it again.
+const HandlerEnv = "VIA_HANDLER"
+
+// Groups of mimeapps.list that associate types with applications
+const (
+	defaultGroup = "Default Applications"
+	addedGroup   = "Added Associations"
+)
+
+// UserHomeDir is a variable to allow mocking in tests
+var UserHomeDir = os.UserHomeDir
+
+var (
+	mimeTypePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9!#$&^_.+-]*/[a-z0-9][a-z0-9!#$&^_.+-]*$`)
+	schemePattern   = regexp.MustCompile(`^[a-z][a-z0-9+.-]*$`)
+)
+
+// DataHome returns $XDG_DATA_HOME, ~/.local/share by default
+func DataHome() (string, error) {
+	return xdgDir("XDG_DATA_HOME", ".local", "share")
+}
+
+// ConfigHome returns $XDG_CONFIG_HOME, ~/.config by default
+func ConfigHome() (string, error) {
+	return xdgDir("XDG_CONFIG_HOME", ".config")
+}
+
+func xdgDir(env string, fallback ...string) (string, error) {
+	if dir := os.Getenv(env); filepath.IsAbs(dir) {
+		return dir, nil
+	}
+	home, err := UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home dir: %w", err)
+	}
+	return filepath.Join(append([]string{home}, fallback...)...), nil
+}
+
+// EntryPath returns where the desktop entry is installed
+func EntryPath() (string, error) {
+	dir, err := DataHome()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "applications", EntryID), nil
+}
+
+// MimeAppsPath returns the mimeapps.list of the user
+func MimeAppsPath() (string, error) {
+	dir, err := ConfigHome()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "mimeapps.list"), nil
+}
+
+// NormalizeType turns a URL scheme such as https into x-scheme-handler/https and
+// checks that anything else is a MIME type
+func NormalizeType(t string) (string, error) {
+	t = strings.ToLower(strings.TrimSpace(t))
+	if strings.Contains(t, "/") {
+		if !mimeTypePattern.MatchString(t) {
+			return "", fmt.Errorf("invalid MIME type %q", t)
+		}
+		return t, nil
+	}
+	t = strings.TrimSuffix(t, ":")
+	if !schemePattern.MatchString(t) {
+		return "", fmt.Errorf("invalid URL scheme %q", t)
+	}
+	return "x-scheme-handler/" + t, nil
+}
+
+// Entry renders a desktop entry that opens its argument with exe
+func Entry(exe string, types []string) string {
+	var b strings.Builder
+	b.WriteString("[Desktop Entry]\n")
+	b.WriteString("Type=Application\n")
+	b.WriteString("Name=Via\n")
+	b.WriteString("Comment=Open files and links with the matching via rule\n")
+	fmt.Fprintf(&b, "Exec=env %s=1 %s %%u\n", HandlerEnv, quoteExec(exe))
+	b.WriteString("Terminal=false\n")
+	b.WriteString("NoDisplay=true\n")
+	fmt.Fprintf(&b, "MimeType=%s\n", joinList(types))
+	return b.String()
+}
+
+// EntryTypes returns the types listed in the MimeType key of a desktop entry
+func EntryTypes(entry string) []string {
+	for _, line := range strings.Split(entry, "\n") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(line), "MimeType="); ok {
+			return splitList(value)
+		}
+	}
+	return nil
+}
+
+// quoteExec quotes an Exec argument as the Desktop Entry Specification requires
+func quoteExec(arg string) string {
+	if !strings.ContainsAny(arg, " \t\n\"'\\><~|&;$*?#()`%=") {
+		return arg
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range arg {
+		switch r {
+		case '"', '`', '$', '\\':
+			b.WriteByte('\\')
+		case '%':
+			b.WriteByte('%')
+		}
+		b.WriteRune(r)
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// AddDefaults returns the content of a mimeapps.list with id as the default
+// application for types. Applications that were the default before are kept
+// after it, so they take over again when it is removed.
+func AddDefaults(mimeapps, id string, types []string) string {
+	file := parseMimeApps(mimeapps)
+	for _, t := range types {
+		for _, group := range []string{defaultGroup, addedGroup} {
+			file.update(group, t, func(apps []string) []string {
+				return append([]string{id}, slices.DeleteFunc(apps, func(app string) bool { return app == id })...)
+			})
+		}
+	}
+	return file.String()
+}
+
+// RemoveDefaults returns the content of a mimeapps.list without id for types,
+// or for every type if types is nil. Keys left without applications are removed.
+func RemoveDefaults(mimeapps, id string, types []string) string {
+	file := parseMimeApps(mimeapps)
+	for _, group := range []string{defaultGroup, addedGroup} {
+		for _, key := range file.keys(group) {
+			if types != nil && !slices.Contains(types, key) {
+				continue
+			}
+			file.update(group, key, func(apps []string) []string {
+				return slices.DeleteFunc(apps, func(app string) bool { return app == id })
+			})
+		}
+	}
+	return file.String()
+}
+
+// mimeApps is a mimeapps.list kept line by line, so that comments, groups and
+// keys it does not touch are written back as they were
+type mimeApps struct {
+	lines []string
+}
+
+func parseMimeApps(data string) *mimeApps {
+	data = strings.TrimRight(data, "\n")
+	if data == "" {
+		return &mimeApps{}
+	}
+	return &mimeApps{lines: strings.Split(data, "\n")}
+}
+
+func (m *mimeApps) String() string {
+	if len(m.lines) == 0 {
+		return ""
+	}
+	return strings.Join(m.lines, "\n") + "\n"
+}
+
+// group returns the range of lines after the header of a group, or -1 if it is missing
+func (m *mimeApps) group(name string) (start, end int) {
+	start = -1
+	for i, line := range m.lines {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "[") {
+			continue
+		}
+		if start >= 0 {
+			return start, i
+		}
+		if line == "["+name+"]" {
+			start = i + 1
+		}
+	}
+	return start, len(m.lines)
+}
+
+// keys returns the keys of a group in file order
+func (m *mimeApps) keys(group string) []string {
+	start, end := m.group(group)
+	if start < 0 {
+		return nil
+	}
+	var keys []string
+	for _, line := range m.lines[start:end] {
+		if key, _, ok := strings.Cut(line, "="); ok && !strings.HasPrefix(strings.TrimSpace(line), "#") {
+			keys = append(keys, strings.TrimSpace(key))
+		}
+	}
+	return keys
+}
+
+// update replaces the applications of key in group with fn of the current ones,
+// adding the group and key when missing and removing the key when none are left
+func (m *mimeApps) update(group, key string, fn func([]string) []string) {
+	start, end := m.group(group)
+	if start < 0 {
+		if apps := fn(nil); len(apps) > 0 {
+			if len(m.lines) > 0 && strings.TrimSpace(m.lines[len(m.lines)-1]) != "" {
+				m.lines = append(m.lines, "")
+			}
+			m.lines = append(m.lines, "["+group+"]", key+"="+joinList(apps))
+		}
+		return
+	}
+
+	for i := start; i < end; i++ {
+		k, value, ok := strings.Cut(m.lines[i], "=")
+		if !ok || strings.TrimSpace(k) != key {
+			continue
+		}
+		if apps := fn(splitList(value)); len(apps) > 0 {
+			m.lines[i] = key + "=" + joinList(apps)
+		} else {
+			m.lines = slices.Delete(m.lines, i, i+1)
+		}
+		return
+	}
+
+	if apps := fn(nil); len(apps) > 0 {
+		// After the last key of the group, before the blank lines separating it from the next
+		at := end
+		for at > start && strings.TrimSpace(m.lines[at-1]) == "" {
+			at--
+		}
+		m.lines = slices.Insert(m.lines, at, key+"="+joinList(apps))
+	}
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ";") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func joinList(items []string) string {
+	if len(items) == 0 {
+		return ""
+	}
+	return strings.Join(items, ";") + ";"
+}
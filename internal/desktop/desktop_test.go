@@ -0,0 +1,141 @@
+package desktop_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/SuzumiyaAoba/via/internal/desktop"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestDesktop(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Desktop Suite")
+}
+
+var _ = Describe("Desktop", func() {
+	Describe("paths", func() {
+		It("should follow the XDG base directories", func() {
+			GinkgoT().Setenv("XDG_DATA_HOME", "/data")
+			GinkgoT().Setenv("XDG_CONFIG_HOME", "/config")
+			Expect(desktop.EntryPath()).To(Equal("/data/applications/via.desktop"))
+			Expect(desktop.MimeAppsPath()).To(Equal("/config/mimeapps.list"))
+		})
+
+		It("should fall back to the home directory", func() {
+			GinkgoT().Setenv("XDG_DATA_HOME", "")
+			GinkgoT().Setenv("XDG_CONFIG_HOME", "relative")
+			saved := desktop.UserHomeDir
+			desktop.UserHomeDir = func() (string, error) { return "/home/user", nil }
+			DeferCleanup(func() { desktop.UserHomeDir = saved })
+
+			Expect(desktop.EntryPath()).To(Equal(filepath.FromSlash("/home/user/.local/share/applications/via.desktop")))
+			Expect(desktop.MimeAppsPath()).To(Equal(filepath.FromSlash("/home/user/.config/mimeapps.list")))
+		})
+	})
+
+	DescribeTable("NormalizeType",
+		func(input, expected, message string) {
+			t, err := desktop.NormalizeType(input)
+			if message != "" {
+				Expect(err).To(MatchError(ContainSubstring(message)))
+				return
+			}
+			Expect(err).NotTo(HaveOccurred())
+			Expect(t).To(Equal(expected))
+		},
+		Entry("scheme", "https", "x-scheme-handler/https", ""),
+		Entry("scheme with colon", "mailto:", "x-scheme-handler/mailto", ""),
+		Entry("MIME type", "Application/PDF", "application/pdf", ""),
+		Entry("scheme handler", "x-scheme-handler/ftp", "x-scheme-handler/ftp", ""),
+		Entry("invalid scheme", "1http", "", "invalid URL scheme"),
+		Entry("invalid MIME type", "text/", "", "invalid MIME type"),
+	)
+
+	Describe("Entry", func() {
+		It("should render a desktop entry for the types", func() {
+			entry := desktop.Entry("/usr/bin/vv", []string{"x-scheme-handler/https", "text/plain"})
+			Expect(entry).To(ContainSubstring("[Desktop Entry]\nType=Application\n"))
+			Expect(entry).To(ContainSubstring("\nExec=env VIA_HANDLER=1 /usr/bin/vv %u\n"))
+			Expect(entry).To(ContainSubstring("\nMimeType=x-scheme-handler/https;text/plain;\n"))
+			Expect(desktop.EntryTypes(entry)).To(Equal([]string{"x-scheme-handler/https", "text/plain"}))
+		})
+
+		It("should quote executables with reserved characters", func() {
+			entry := desktop.Entry(`/opt/my apps/v$v`, nil)
+			Expect(entry).To(ContainSubstring("\nExec=env VIA_HANDLER=1 \"/opt/my apps/v\\$v\" %u\n"))
+		})
+	})
+
+	Describe("AddDefaults", func() {
+		It("should create the groups when missing", func() {
+			Expect(desktop.AddDefaults("", "via.desktop", []string{"x-scheme-handler/https"})).To(Equal(`[Default Applications]
+x-scheme-handler/https=via.desktop;
+
+[Added Associations]
+x-scheme-handler/https=via.desktop;
+`))
+		})
+
+		It("should put the entry first and keep everything else", func() {
+			existing := `# Set by the desktop
+[Default Applications]
+x-scheme-handler/https=firefox.desktop;via.desktop;
+text/plain=gedit.desktop;
+
+[Removed Associations]
+text/html=via.desktop;
+`
+			Expect(desktop.AddDefaults(existing, "via.desktop", []string{"x-scheme-handler/https", "x-scheme-handler/http"})).To(Equal(`# Set by the desktop
+[Default Applications]
+x-scheme-handler/https=via.desktop;firefox.desktop;
+text/plain=gedit.desktop;
+x-scheme-handler/http=via.desktop;
+
+[Removed Associations]
+text/html=via.desktop;
+
+[Added Associations]
+x-scheme-handler/https=via.desktop;
+x-scheme-handler/http=via.desktop;
+`))
+		})
+	})
+
+	Describe("RemoveDefaults", func() {
+		existing := `[Default Applications]
+x-scheme-handler/https=via.desktop;firefox.desktop;
+x-scheme-handler/http=via.desktop;
+text/plain=gedit.desktop;
+
+[Added Associations]
+x-scheme-handler/https=via.desktop;
+`
+
+		It("should remove the entry from the given types", func() {
+			Expect(desktop.RemoveDefaults(existing, "via.desktop", []string{"x-scheme-handler/https"})).To(Equal(`[Default Applications]
+x-scheme-handler/https=firefox.desktop;
+x-scheme-handler/http=via.desktop;
+text/plain=gedit.desktop;
+
+[Added Associations]
+`))
+		})
+
+		It("should remove the entry from every type", func() {
+			Expect(desktop.RemoveDefaults(existing, "via.desktop", nil)).To(Equal(`[Default Applications]
+x-scheme-handler/https=firefox.desktop;
+text/plain=gedit.desktop;
+
+[Added Associations]
+`))
+		})
+
+		It("should undo AddDefaults", func() {
+			before := "[Default Applications]\ntext/plain=gedit.desktop;\n"
+			added := desktop.AddDefaults(before, "via.desktop", []string{"text/plain"})
+			Expect(desktop.RemoveDefaults(added, "via.desktop", nil)).To(Equal(before + "\n[Added Associations]\n"))
+		})
+	})
+})
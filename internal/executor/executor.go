@@ -4,10 +4,12 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"maps"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
 	"syscall"
 	"text/template"
@@ -28,11 +30,45 @@ type ExecutionOptions struct {
 	Background bool
 	Terminal   bool
 	Env        map[string]string
+	NoHistory  bool
 }
 
 type Executor struct {
 	Out    io.Writer
 	DryRun bool
+
+	// Record, if set, receives every command instead of it being run or printed
+	Record func(Invocation)
+}
+
+// Kinds of Invocation
+const (
+	InvokeTemplate = "template" // Execute
+	InvokeCommand  = "command"  // ExecuteCommand
+	InvokeSystem   = "system"   // OpenSystem
+)
+
+// Invocation is a command an Executor was asked to run, so that it can be run
+// later, possibly by another process, with Replay
+type Invocation struct {
+	Kind    string           `json:"kind"`
+	Command string           `json:"command,omitempty"` // The command template, or the program for InvokeCommand
+	File    string           `json:"file,omitempty"`
+	Args    []string         `json:"args,omitempty"`
+	Options ExecutionOptions `json:"options"`
+}
+
+// Replay runs a recorded invocation
+func (e *Executor) Replay(inv Invocation) error {
+	switch inv.Kind {
+	case InvokeTemplate:
+		return e.Execute(inv.Command, inv.File, inv.Options)
+	case InvokeCommand:
+		return e.ExecuteCommand(inv.Command, inv.Args)
+	case InvokeSystem:
+		return e.OpenSystem(inv.File)
+	}
+	return fmt.Errorf("unknown invocation kind %q", inv.Kind)
 }
 
 func NewExecutor(out io.Writer, dryRun bool) *Executor {
@@ -43,6 +79,11 @@ func NewExecutor(out io.Writer, dryRun bool) *Executor {
 }
 
 func (e *Executor) Execute(commandTmpl string, file string, opts ExecutionOptions) error {
+	if e.Record != nil {
+		e.Record(Invocation{Kind: InvokeTemplate, Command: commandTmpl, File: file, Options: opts})
+		return nil
+	}
+
 	var cmdBuf bytes.Buffer
 	tmpl, err := template.New("command").Parse(commandTmpl)
 	if err != nil {
@@ -81,8 +122,8 @@ func (e *Executor) Execute(commandTmpl string, file string, opts ExecutionOption
 		envStr := ""
 		if len(opts.Env) > 0 {
 			var parts []string
-			for k, v := range opts.Env {
-				parts = append(parts, fmt.Sprintf("%s=%s", k, v))
+			for _, k := range slices.Sorted(maps.Keys(opts.Env)) {
+				parts = append(parts, fmt.Sprintf("%s=%s", k, opts.Env[k]))
 			}
 			envStr = fmt.Sprintf(" [%s]", strings.Join(parts, ", "))
 		}
@@ -131,7 +172,7 @@ func (e *Executor) Execute(commandTmpl string, file string, opts ExecutionOption
 	}
 
 	// Record history
-	if !e.DryRun {
+	if !e.DryRun && !opts.NoHistory {
 		_ = history.AddEntry(file, "")
 	}
 
@@ -139,6 +180,11 @@ func (e *Executor) Execute(commandTmpl string, file string, opts ExecutionOption
 }
 
 func (e *Executor) ExecuteCommand(command string, args []string) error {
+	if e.Record != nil {
+		e.Record(Invocation{Kind: InvokeCommand, Command: command, Args: args})
+		return nil
+	}
+
 	if e.DryRun {
 		fmt.Fprintf(e.Out, "%s %s\n", command, strings.Join(args, " "))
 		return nil
@@ -157,6 +203,11 @@ func (e *Executor) ExecuteCommand(command string, args []string) error {
 }
 
 func (e *Executor) OpenSystem(path string) error {
+	if e.Record != nil {
+		e.Record(Invocation{Kind: InvokeSystem, File: path})
+		return nil
+	}
+
 	var cmdName string
 	var args []string
 
@@ -1,8 +1,12 @@
 package executor_test
 
 import (
+	"bytes"
+	"reflect"
+	"runtime"
 	"testing"
 
+	"github.com/SuzumiyaAoba/via/internal/config"
 	. "github.com/SuzumiyaAoba/via/internal/executor"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -83,6 +87,17 @@ var _ = Describe("Execute", func() {
 	})
 })
 
+var _ = Describe("CommandData", func() {
+	It("should only have fields the config linter accepts", func() {
+		fields := reflect.TypeOf(CommandData{})
+		for i := range fields.NumField() {
+			command := "cat {{." + fields.Field(i).Name + "}}"
+			rules := []config.Rule{{Name: "Text", Extensions: []string{"txt"}, Command: command}}
+			Expect(config.Lint(&config.Config{Version: "2", Rules: rules})).To(BeEmpty(), command)
+		}
+	})
+})
+
 var _ = Describe("ExecuteCommand", func() {
 	It("should execute raw command", func() {
 		exec := NewExecutor(GinkgoWriter, false)
@@ -151,3 +166,41 @@ var _ = Describe("OpenSystem", func() {
 		Expect(err).NotTo(HaveOccurred())
 	})
 })
+
+var _ = Describe("Record", func() {
+	It("should record commands instead of running them and replay them", func() {
+		var recorded []Invocation
+		recorder := &Executor{Record: func(inv Invocation) { recorded = append(recorded, inv) }}
+
+		opts := ExecutionOptions{Background: true, Env: map[string]string{"A": "1"}}
+		Expect(recorder.Execute("false {{.File}}", "test.txt", opts)).To(Succeed())
+		Expect(recorder.ExecuteCommand("false", []string{"x"})).To(Succeed())
+		Expect(recorder.OpenSystem("test.txt")).To(Succeed())
+		Expect(recorded).To(Equal([]Invocation{
+			{Kind: InvokeTemplate, Command: "false {{.File}}", File: "test.txt", Options: opts},
+			{Kind: InvokeCommand, Command: "false", Args: []string{"x"}},
+			{Kind: InvokeSystem, File: "test.txt"},
+		}))
+
+		var out bytes.Buffer
+		exec := NewExecutor(&out, true)
+		for _, inv := range recorded {
+			Expect(exec.Replay(inv)).To(Succeed())
+		}
+		Expect(out.String()).To(Equal("false test.txt (background) [A=1]\nfalse x\n" + systemOpener() + " test.txt\n"))
+	})
+
+	It("should reject unknown invocations", func() {
+		Expect(NewExecutor(GinkgoWriter, true).Replay(Invocation{Kind: "other"})).To(MatchError(ContainSubstring("other")))
+	})
+})
+
+func systemOpener() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return "open"
+	case "windows":
+		return "cmd /c start "
+	}
+	return "xdg-open"
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -22,13 +24,107 @@ func SetHistoryPath(path string) {
 	customHistoryPath = path
 }
 
+var (
+	disabled        bool
+	excludePatterns []string
+)
+
+// SetDisabled turns history recording off entirely (private mode)
+func SetDisabled(v bool) {
+	disabled = v
+}
+
+// SetExcludePatterns sets glob patterns for paths that must never be recorded
+func SetExcludePatterns(patterns []string) {
+	excludePatterns = patterns
+}
+
+// IsExcluded reports whether the given file or command matches an exclude pattern.
+// Patterns without a path separator are matched against the base name,
+// others against the absolute path. A trailing "/**" matches everything below a directory.
+func IsExcluded(command string) bool {
+	if len(excludePatterns) == 0 {
+		return false
+	}
+
+	absPath, err := filepath.Abs(command)
+	if err != nil {
+		absPath = command
+	}
+	base := filepath.Base(command)
+
+	for _, pattern := range excludePatterns {
+		pattern = expandHome(pattern)
+
+		if dir, ok := strings.CutSuffix(pattern, "/**"); ok {
+			if absPath == dir || strings.HasPrefix(absPath, dir+string(filepath.Separator)) {
+				return true
+			}
+			continue
+		}
+
+		target := absPath
+		if !strings.ContainsRune(pattern, '/') {
+			target = base
+		}
+		if matched, _ := filepath.Match(pattern, target); matched {
+			return true
+		}
+		// Also allow matching the raw argument (e.g. URLs)
+		if matched, _ := filepath.Match(pattern, command); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// expandHome replaces a leading "~" with the user's home directory
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
+
 // UserHomeDir is a variable to allow mocking in tests
 var UserHomeDir = os.UserHomeDir
 
+// GetHistoryPath returns history.json next to the default config. Until
+// :config migrate moves it, a history left in the directory used before the
+// rename is used instead.
 func GetHistoryPath() (string, error) {
 	if customHistoryPath != "" {
 		return customHistoryPath, nil
 	}
+	path, err := DefaultHistoryPath()
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if legacy, err := LegacyHistoryPath(); err == nil {
+			if _, err := os.Stat(legacy); err == nil {
+				return legacy, nil
+			}
+		}
+	}
+	return path, nil
+}
+
+// DefaultHistoryPath returns history.json next to the default config
+func DefaultHistoryPath() (string, error) {
+	home, err := UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home dir: %w", err)
+	}
+	return filepath.Join(home, ".config", "via", "history.json"), nil
+}
+
+// LegacyHistoryPath returns where the history lived before the project was renamed
+func LegacyHistoryPath() (string, error) {
 	home, err := UserHomeDir()
 	if err != nil {
 		return "", fmt.Errorf("failed to get user home dir: %w", err)
@@ -59,6 +155,10 @@ func LoadHistory() ([]HistoryEntry, error) {
 }
 
 func AddEntry(command, ruleName string) error {
+	if disabled || IsExcluded(command) {
+		return nil
+	}
+
 	entries, err := LoadHistory()
 	if err != nil {
 		// If error loading, start fresh
@@ -111,3 +211,30 @@ func ClearHistory() error {
 	// Just write empty array
 	return saveHistory([]HistoryEntry{})
 }
+
+// MergeEntries combines two histories (e.g. from different machines), newest first.
+// Entries present in both are kept once, and the result is trimmed to MaxHistorySize.
+func MergeEntries(a, b []HistoryEntry) []HistoryEntry {
+	type key struct {
+		timestamp int64
+		command   string
+	}
+	seen := make(map[key]bool, len(a)+len(b))
+	merged := make([]HistoryEntry, 0, len(a)+len(b))
+	for _, entry := range append(append([]HistoryEntry{}, a...), b...) {
+		k := key{entry.Timestamp.UnixNano(), entry.Command}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		merged = append(merged, entry)
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Timestamp.After(merged[j].Timestamp)
+	})
+	if len(merged) > MaxHistorySize {
+		merged = merged[:MaxHistorySize]
+	}
+	return merged
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/SuzumiyaAoba/via/internal/history"
 	. "github.com/onsi/ginkgo/v2"
@@ -30,6 +31,8 @@ var _ = Describe("History", func() {
 
 	AfterEach(func() {
 		history.SetHistoryPath("")
+		history.SetDisabled(false)
+		history.SetExcludePatterns(nil)
 	})
 
 	It("should add and load entries", func() {
@@ -107,12 +110,102 @@ var _ = Describe("History", func() {
 		Expect(err).To(HaveOccurred())
 	})
 
+	Describe("Private mode", func() {
+		It("should not record entries when disabled", func() {
+			history.SetDisabled(true)
+
+			err := history.AddEntry("secret.txt", "rule")
+			Expect(err).NotTo(HaveOccurred())
+
+			entries, err := history.LoadHistory()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(BeEmpty())
+		})
+
+		It("should not record entries matching exclude patterns", func() {
+			history.SetExcludePatterns([]string{"*.kdbx", filepath.Join(tmpDir, "hr") + "/**"})
+
+			Expect(history.AddEntry("passwords.kdbx", "")).To(Succeed())
+			Expect(history.AddEntry(filepath.Join(tmpDir, "hr", "reviews", "2025.pdf"), "")).To(Succeed())
+			Expect(history.AddEntry("notes.txt", "")).To(Succeed())
+
+			entries, err := history.LoadHistory()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(HaveLen(1))
+			Expect(entries[0].Command).To(Equal("notes.txt"))
+		})
+	})
+
+	Describe("MergeEntries", func() {
+		It("should combine histories newest first without duplicates", func() {
+			now := time.Now()
+			shared := history.HistoryEntry{Timestamp: now.Add(-time.Hour), Command: "shared.txt"}
+			a := []history.HistoryEntry{{Timestamp: now, Command: "a.txt"}, shared}
+			b := []history.HistoryEntry{{Timestamp: now.Add(-time.Minute), Command: "b.txt"}, shared}
+
+			merged := history.MergeEntries(a, b)
+			Expect(merged).To(HaveLen(3))
+			Expect(merged[0].Command).To(Equal("a.txt"))
+			Expect(merged[1].Command).To(Equal("b.txt"))
+			Expect(merged[2].Command).To(Equal("shared.txt"))
+		})
+
+		It("should trim to the maximum size", func() {
+			var a, b []history.HistoryEntry
+			for i := 0; i < history.MaxHistorySize; i++ {
+				a = append(a, history.HistoryEntry{Timestamp: time.Unix(int64(2*i), 0), Command: "a"})
+				b = append(b, history.HistoryEntry{Timestamp: time.Unix(int64(2*i+1), 0), Command: "b"})
+			}
+			Expect(history.MergeEntries(a, b)).To(HaveLen(history.MaxHistorySize))
+		})
+	})
+
+	Describe("IsExcluded", func() {
+		BeforeEach(func() {
+			history.SetExcludePatterns([]string{"*.pem", "~/secrets/*", "https://bank.example.com/*"})
+		})
+
+		It("should match base name patterns", func() {
+			Expect(history.IsExcluded("/some/dir/key.pem")).To(BeTrue())
+			Expect(history.IsExcluded("key.pub")).To(BeFalse())
+		})
+
+		It("should expand home directory", func() {
+			origUserHomeDir := history.UserHomeDir
+			history.UserHomeDir = func() (string, error) { return "/home/user", nil }
+			defer func() { history.UserHomeDir = origUserHomeDir }()
+
+			Expect(history.IsExcluded("/home/user/secrets/token.txt")).To(BeTrue())
+			Expect(history.IsExcluded("/home/user/public/token.txt")).To(BeFalse())
+		})
+
+		It("should match URLs", func() {
+			Expect(history.IsExcluded("https://bank.example.com/login")).To(BeTrue())
+			Expect(history.IsExcluded("https://example.com/login")).To(BeFalse())
+		})
+	})
+
 	Describe("GetHistoryPath", func() {
 		It("should return default path", func() {
 			history.SetHistoryPath("")
+			home := GinkgoT().TempDir()
+			origUserHomeDir := history.UserHomeDir
+			history.UserHomeDir = func() (string, error) { return home, nil }
+			defer func() { history.UserHomeDir = origUserHomeDir }()
+
 			path, err := history.GetHistoryPath()
 			Expect(err).NotTo(HaveOccurred())
-			Expect(path).To(ContainSubstring(".config/entry/history.json"))
+			Expect(path).To(Equal(filepath.Join(home, ".config", "via", "history.json")))
+
+			// A history from before the rename is used until it is moved
+			legacy := filepath.Join(home, ".config", "entry", "history.json")
+			Expect(os.MkdirAll(filepath.Dir(legacy), 0755)).To(Succeed())
+			Expect(os.WriteFile(legacy, []byte("[]"), 0644)).To(Succeed())
+			Expect(history.GetHistoryPath()).To(Equal(legacy))
+
+			Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+			Expect(os.WriteFile(path, []byte("[]"), 0644)).To(Succeed())
+			Expect(history.GetHistoryPath()).To(Equal(path))
 		})
 
 		It("should return error if home dir fails", func() {
@@ -14,105 +14,114 @@ import (
 )
 
 func Match(rules []config.Rule, filename string) ([]*config.Rule, error) {
-	var matches []*config.Rule
-	
-	for i := range rules {
-		rule := &rules[i]
-		matched, err := matchRule(rule, filename)
-		if err != nil {
-			return nil, err
-		}
-
-		if matched {
-			matches = append(matches, rule)
-			if !rule.Fallthrough {
-				break
-			}
-		}
+	trace := TraceMatch(rules, filename)
+	if trace.Err != nil {
+		return nil, trace.Err
 	}
+	return trace.Matches, nil
+}
 
-	return matches, nil
+func MatchAll(rules []config.Rule, filename string) ([]*config.Rule, error) {
+	trace := traceRules(rules, filename, true)
+	if trace.Err != nil {
+		return nil, trace.Err
+	}
+	return trace.Matches, nil
 }
 
+// input is a file or URL being matched, with the values rules are checked against
+type input struct {
+	name string
+	url  *url.URL // nil for files
+	ext  string   // Lower case, without the dot
 
-func MatchAll(rules []config.Rule, filename string) ([]*config.Rule, error) {
-	var matches []*config.Rule
+	mime         string
+	mimeDetected bool
+}
 
-	for i := range rules {
-		rule := &rules[i]
-		matched, err := matchRule(rule, filename)
-		if err != nil {
-			return nil, err
-		}
+func newInput(filename string) *input {
+	in := &input{name: filename}
+	if u, err := url.Parse(filename); err == nil && u.Scheme != "" {
+		in.url = u
+		in.ext = filepath.Ext(u.Path)
+	} else {
+		in.ext = filepath.Ext(filename)
+	}
+	in.ext = strings.ToLower(strings.TrimPrefix(in.ext, "."))
+	return in
+}
 
-		if matched {
-			matches = append(matches, rule)
+// mimeType detects the MIME type of the file once, "" if it cannot be read
+func (in *input) mimeType() string {
+	if !in.mimeDetected {
+		in.mimeDetected = true
+		if mtype, err := mimetype.DetectFile(in.name); err == nil {
+			in.mime = mtype.String()
 		}
 	}
-
-	return matches, nil
+	return in.mime
 }
 
-func matchRule(rule *config.Rule, filename string) (bool, error) {
-	// Parse URL once if needed? 
-	// Actually, we can parse it inside here. It's cheap enough.
-	u, err := url.Parse(filename)
-	isURL := err == nil && u.Scheme != ""
+// matchRule evaluates a rule, recording each condition it checks in trace. The
+// OS and scheme must match; otherwise any matching extension, regex, MIME type
+// or script is enough, checked in that order.
+func matchRule(rule *config.Rule, in *input, trace *RuleTrace) (bool, error) {
+	record := func(c Condition) bool {
+		trace.Conditions = append(trace.Conditions, c)
+		return c.Matched
+	}
 
 	// Check OS
 	if len(rule.OS) > 0 {
-		if !lo.ContainsBy(rule.OS, func(osName string) bool {
+		if !record(Condition{Type: ConditionOS, Expected: rule.OS, Actual: runtime.GOOS, Matched: lo.ContainsBy(rule.OS, func(osName string) bool {
 			return strings.EqualFold(osName, runtime.GOOS)
-		}) {
+		})}) {
+			trace.Result = ResultSkip
 			return false, nil
 		}
 	}
 
 	// Check Scheme
 	if rule.Scheme != "" {
-		if isURL && strings.EqualFold(u.Scheme, rule.Scheme) {
-			return true, nil
+		c := Condition{Type: ConditionScheme, Expected: []string{rule.Scheme}}
+		if in.url != nil {
+			c.Actual = in.url.Scheme
+			c.Matched = strings.EqualFold(in.url.Scheme, rule.Scheme)
 		}
 		// If scheme is specified but doesn't match, this rule is not a match
-		return false, nil
+		if !record(c) {
+			trace.Result = ResultSkip
+		}
+		return c.Matched, nil
 	}
 
 	// Check extensions
+	// If extensions are specified but none matched, we continue to check other conditions (Regex, MIME, etc.)
+	// This allows a rule to match EITHER by extension OR by regex/mime.
 	if len(rule.Extensions) > 0 {
-		var pathExt string
-		if isURL {
-			pathExt = filepath.Ext(u.Path)
-		} else {
-			pathExt = filepath.Ext(filename)
-		}
-		pathExt = strings.ToLower(strings.TrimPrefix(pathExt, "."))
-
-		if lo.ContainsBy(rule.Extensions, func(ruleExt string) bool {
-			return strings.EqualFold(ruleExt, pathExt)
-		}) {
+		if record(Condition{Type: ConditionExtension, Expected: rule.Extensions, Actual: in.ext, Matched: lo.ContainsBy(rule.Extensions, func(ruleExt string) bool {
+			return strings.EqualFold(ruleExt, in.ext)
+		})}) {
 			return true, nil
 		}
-		// If extensions are specified but none matched, we continue to check other conditions (Regex, MIME, etc.)
-		// This allows a rule to match EITHER by extension OR by regex/mime.
 	}
 
 	// Check regex
 	if rule.Regex != "" {
-		regexMatched, err := regexp.MatchString(rule.Regex, filename)
+		regexMatched, err := regexp.MatchString(rule.Regex, in.name)
+		if record(Condition{Type: ConditionRegex, Expected: []string{rule.Regex}, Matched: regexMatched, Err: err}) {
+			return true, nil
+		}
 		if err != nil {
 			return false, err
 		}
-		if regexMatched {
-			return true, nil
-		}
 	}
 
 	// Check MIME type
-	if rule.Mime != "" && !isURL {
-		mtype, err := mimetype.DetectFile(filename)
-		if err == nil {
-			mimeMatched, err := regexp.MatchString(rule.Mime, mtype.String())
-			if err == nil && mimeMatched {
+	if rule.Mime != "" && in.url == nil {
+		if mime := in.mimeType(); mime != "" {
+			mimeMatched, err := regexp.MatchString(rule.Mime, mime)
+			if record(Condition{Type: ConditionMIME, Expected: []string{rule.Mime}, Actual: mime, Matched: err == nil && mimeMatched}) {
 				return true, nil
 			}
 		}
@@ -120,20 +129,24 @@ func matchRule(rule *config.Rule, filename string) (bool, error) {
 
 	// Check Script
 	if rule.Script != "" {
-		return matchScript(rule.Script, filename)
+		result, matched, err := matchScript(rule.Script, in.name)
+		record(Condition{Type: ConditionScript, Expected: []string{rule.Script}, Actual: result, Matched: matched, Err: err})
+		return matched, err
 	}
 
 	return false, nil
 }
 
-func matchScript(script string, filename string) (bool, error) {
+// matchScript runs a script and returns what it evaluated to, and whether that
+// is truthy
+func matchScript(script string, filename string) (string, bool, error) {
 	vm := goja.New()
 	vm.Set("file", filename)
-	
+
 	val, err := vm.RunString(script)
 	if err != nil {
-		return false, err
+		return "", false, err
 	}
-	
-	return val.ToBoolean(), nil
+
+	return val.String(), val.ToBoolean(), nil
 }
@@ -190,3 +190,134 @@ var _ = Describe("Match", func() {
 		})
 	})
 })
+
+var _ = Describe("RunTests", func() {
+	rules := func() []config.Rule {
+		return []config.Rule{
+			{
+				Name:    "Go Test Runner",
+				Regex:   `_test\.go$`,
+				Command: "go test",
+				Tests:   []config.RuleTest{{Input: "foo_test.go"}, {Input: "main.go"}},
+			},
+			{
+				Name:       "Go",
+				Extensions: []string{"go"},
+				Command:    "vim",
+				Tests: []config.RuleTest{
+					{Input: "main.go"},
+					{Input: "foo_test.go", Expect: "Go Test Runner"},
+					{Input: "notes.txt", ExpectNone: true},
+					{Input: "main.go", ExpectNone: true},
+				},
+			},
+		}
+	}
+
+	It("should run every test case in rule order", func() {
+		rules := rules()
+		results := matcher.RunTests(rules)
+		Expect(results).To(HaveLen(6))
+
+		passed := make([]bool, len(results))
+		for i, r := range results {
+			passed[i] = r.Passed()
+		}
+		Expect(passed).To(Equal([]bool{true, false, true, true, true, false}))
+
+		Expect(results[1].Rule).To(BeIdenticalTo(&rules[0]))
+		Expect(results[1].Matched).To(Equal([]*config.Rule{&rules[1]}))
+	})
+
+	It("should pass when the expected rule is reached through fallthrough", func() {
+		rules := rules()
+		rules[0].Fallthrough = true
+		rules[1].Tests = []config.RuleTest{{Input: "foo_test.go"}}
+
+		results := matcher.RunTests(rules)
+		Expect(results[len(results)-1].Passed()).To(BeTrue())
+	})
+
+	It("should expect rules named none by name", func() {
+		results := matcher.RunTests([]config.Rule{
+			{Name: "none", Extensions: []string{"txt"}, Command: "cat", Tests: []config.RuleTest{{Input: "a.txt", Expect: "none"}}},
+		})
+		Expect(results).To(HaveLen(1))
+		Expect(results[0].Passed()).To(BeTrue())
+	})
+
+	It("should fail on match errors", func() {
+		results := matcher.RunTests([]config.Rule{
+			{Name: "Broken", Script: "syntax error(", Command: "cat", Tests: []config.RuleTest{{Input: "a.txt"}}},
+		})
+		Expect(results).To(HaveLen(1))
+		Expect(results[0].Err).To(HaveOccurred())
+		Expect(results[0].Passed()).To(BeFalse())
+	})
+})
+
+var _ = Describe("TraceMatch", func() {
+	It("should record every condition of the rules looked at", func() {
+		rules := []config.Rule{
+			{Name: "Go", Extensions: []string{"go"}, Command: "vim"},
+			{Name: "Tests", Regex: `_test\.go$`, Script: `file.endsWith("_test.go")`, Command: "go test"},
+			{Name: "Web", Scheme: "https", Command: "open"},
+		}
+
+		trace := matcher.TraceMatch(rules, "foo_test.txt")
+		Expect(trace.Err).NotTo(HaveOccurred())
+		Expect(trace.Matches).To(BeEmpty())
+		Expect(trace.Rules).To(HaveLen(3))
+
+		Expect(trace.Rules[0].Result).To(Equal(matcher.ResultNoMatch))
+		Expect(trace.Rules[0].Conditions).To(Equal([]matcher.Condition{
+			{Type: matcher.ConditionExtension, Expected: []string{"go"}, Actual: "txt"},
+		}))
+
+		Expect(trace.Rules[1].Result).To(Equal(matcher.ResultNoMatch))
+		Expect(trace.Rules[1].Conditions).To(Equal([]matcher.Condition{
+			{Type: matcher.ConditionRegex, Expected: []string{`_test\.go$`}},
+			{Type: matcher.ConditionScript, Expected: []string{`file.endsWith("_test.go")`}, Actual: "false"},
+		}))
+
+		Expect(trace.Rules[2].Result).To(Equal(matcher.ResultSkip))
+		Expect(trace.Rules[2].Conditions).To(Equal([]matcher.Condition{
+			{Type: matcher.ConditionScheme, Expected: []string{"https"}},
+		}))
+	})
+
+	It("should record the fallthrough chain", func() {
+		rules := []config.Rule{
+			{Name: "Log", Regex: ".*", Command: "echo", Fallthrough: true},
+			{Name: "Text", Extensions: []string{"txt"}, Command: "cat"},
+			{Name: "Any", Regex: ".*", Command: "less"},
+		}
+
+		trace := matcher.TraceMatch(rules, "a.txt")
+		Expect(trace.Matches).To(Equal([]*config.Rule{&rules[0], &rules[1]}))
+		Expect(trace.Rules).To(HaveLen(2))
+	})
+
+	It("should record the script return value", func() {
+		rules := []config.Rule{{Name: "Size", Script: `file.length`, Command: "cat"}}
+
+		trace := matcher.TraceMatch(rules, "a.txt")
+		Expect(trace.Rules[0].Result).To(Equal(matcher.ResultMatch))
+		Expect(trace.Rules[0].Conditions[0].Actual).To(Equal("5"))
+		Expect(trace.Rules[0].Conditions[0].Matched).To(BeTrue())
+	})
+
+	It("should stop at the rule that fails", func() {
+		rules := []config.Rule{
+			{Name: "Broken", Script: "syntax error(", Command: "cat"},
+			{Name: "Any", Regex: ".*", Command: "less"},
+		}
+
+		trace := matcher.TraceMatch(rules, "a.txt")
+		Expect(trace.Err).To(MatchError(ContainSubstring("Broken")))
+		Expect(trace.Rules).To(HaveLen(1))
+		Expect(trace.Rules[0].Result).To(Equal(matcher.ResultError))
+		Expect(trace.Rules[0].Conditions[0].Err).To(HaveOccurred())
+		Expect(trace.Matches).To(BeEmpty())
+	})
+})
@@ -0,0 +1,43 @@
+package matcher
+
+import (
+	"slices"
+
+	"github.com/SuzumiyaAoba/via/internal/config"
+)
+
+// TestResult is the outcome of a test case declared in a rule
+type TestResult struct {
+	Rule    *config.Rule // The rule that declares the test
+	Test    config.RuleTest
+	Matched []*config.Rule // The rules Match returned for the input
+	Err     error
+}
+
+// Passed reports whether the expected rule was among the matched ones, or
+// nothing matched if none was expected
+func (r TestResult) Passed() bool {
+	switch {
+	case r.Err != nil:
+		return false
+	case r.Test.ExpectNone:
+		return len(r.Matched) == 0
+	case r.Test.Expect == "":
+		return slices.Contains(r.Matched, r.Rule)
+	}
+	return slices.ContainsFunc(r.Matched, func(rule *config.Rule) bool {
+		return rule.Name == r.Test.Expect
+	})
+}
+
+// RunTests runs the test cases of every rule through Match, in rule order
+func RunTests(rules []config.Rule) []TestResult {
+	var results []TestResult
+	for i := range rules {
+		for _, test := range rules[i].Tests {
+			matched, err := Match(rules, test.Input)
+			results = append(results, TestResult{Rule: &rules[i], Test: test, Matched: matched, Err: err})
+		}
+	}
+	return results
+}
@@ -0,0 +1,86 @@
+package matcher
+
+import (
+	"fmt"
+
+	"github.com/SuzumiyaAoba/via/internal/config"
+)
+
+// Condition types, in the order they are checked
+const (
+	ConditionOS        = "os"
+	ConditionScheme    = "scheme"
+	ConditionExtension = "extension"
+	ConditionRegex     = "regex"
+	ConditionMIME      = "mime"
+	ConditionScript    = "script"
+)
+
+// Outcomes of a rule
+const (
+	ResultMatch   = "match"
+	ResultNoMatch = "no-match"
+	ResultSkip    = "skip" // The OS or scheme rules it out
+	ResultError   = "error"
+)
+
+// Condition is the outcome of one condition of a rule
+type Condition struct {
+	Type     string
+	Expected []string // The values or patterns of the rule, or the script
+	Actual   string   // The value of the input compared, or what the script returned
+	Matched  bool
+	Err      error // Invalid regex or failed script
+}
+
+// RuleTrace records how a rule was evaluated
+type RuleTrace struct {
+	Rule       *config.Rule
+	Index      int // Position of the rule in the rules matched against
+	Conditions []Condition
+	Result     string
+}
+
+// Trace records how the rules were evaluated for an input: every rule looked
+// at, up to the first match without fallthrough, and the rules that matched
+type Trace struct {
+	Input   string
+	Rules   []RuleTrace
+	Matches []*config.Rule // The fallthrough chain, in the order the rules run
+	Err     error          // Why matching stopped early, as returned by Match
+}
+
+// TraceMatch matches like Match and returns how each rule was decided
+func TraceMatch(rules []config.Rule, filename string) *Trace {
+	return traceRules(rules, filename, false)
+}
+
+// traceRules evaluates rules in order, stopping at the first match without
+// fallthrough unless all is set
+func traceRules(rules []config.Rule, filename string, all bool) *Trace {
+	in := newInput(filename)
+	trace := &Trace{Input: filename}
+	for i := range rules {
+		rule := &rules[i]
+		result := RuleTrace{Rule: rule, Index: i, Result: ResultNoMatch}
+		matched, err := matchRule(rule, in, &result)
+		if err != nil {
+			result.Result = ResultError
+			trace.Rules = append(trace.Rules, result)
+			trace.Err = fmt.Errorf("%s: %w", rule.Label(), err)
+			return trace
+		}
+		if matched {
+			result.Result = ResultMatch
+		}
+		trace.Rules = append(trace.Rules, result)
+
+		if matched {
+			trace.Matches = append(trace.Matches, rule)
+			if !all && !rule.Fallthrough {
+				break
+			}
+		}
+	}
+	return trace
+}
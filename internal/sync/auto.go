@@ -0,0 +1,91 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/SuzumiyaAoba/via/internal/config"
+)
+
+// Automatic sync modes for SyncConfig.Auto
+const (
+	AutoPullOnStart  = "pull-on-start"  // Check the remote before opening a file
+	AutoPushOnChange = "push-on-change" // Push after the config is changed by a command
+)
+
+// DefaultAutoInterval is the minimum time between remote checks when no interval is configured
+const DefaultAutoInterval = 15 * time.Minute
+
+// AutoInterval returns the minimum time between automatic remote checks
+func AutoInterval(cfg *config.SyncConfig) time.Duration {
+	if cfg == nil || cfg.Interval <= 0 {
+		return DefaultAutoInterval
+	}
+	return time.Duration(cfg.Interval) * time.Minute
+}
+
+// ValidateAuto checks that only known automatic sync modes are configured
+func ValidateAuto(cfg *config.SyncConfig) error {
+	if cfg == nil || cfg.Auto == "" {
+		return nil
+	}
+	for _, mode := range strings.Split(cfg.Auto, ",") {
+		switch strings.TrimSpace(mode) {
+		case AutoPullOnStart, AutoPushOnChange:
+		default:
+			return fmt.Errorf("unknown automatic sync mode: %s", strings.TrimSpace(mode))
+		}
+	}
+	return nil
+}
+
+// AutoState records the last automatic remote check of a config file
+type AutoState struct {
+	CheckedAt time.Time `json:"checked_at"`
+	Revision  string    `json:"revision,omitempty"` // Remote revision (ETag, commit or Gist version) seen at the last check
+}
+
+// AutoStatePath returns the automatic sync state file for the given config file
+func AutoStatePath(configPath string) string {
+	name := strings.TrimSuffix(filepath.Base(configPath), filepath.Ext(configPath))
+	return filepath.Join(StateDir(configPath), name+".auto.json")
+}
+
+// LoadAutoState reads the automatic sync state. It returns an empty state if the remote was never checked.
+func LoadAutoState(configPath string) (*AutoState, error) {
+	data, err := os.ReadFile(AutoStatePath(configPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &AutoState{}, nil
+		}
+		return nil, fmt.Errorf("failed to read automatic sync state: %w", err)
+	}
+
+	var state AutoState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse automatic sync state: %w", err)
+	}
+	return &state, nil
+}
+
+// SaveAutoState writes the automatic sync state
+func SaveAutoState(configPath string, state *AutoState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(StateDir(configPath), 0755); err != nil {
+		return fmt.Errorf("failed to create sync state directory: %w", err)
+	}
+	return os.WriteFile(AutoStatePath(configPath), data, 0644)
+}
+
+// Due reports whether the remote may be checked again at now
+func (s *AutoState) Due(interval time.Duration, now time.Time) bool {
+	return s.CheckedAt.IsZero() || now.Sub(s.CheckedAt) >= interval
+}
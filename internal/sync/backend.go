@@ -0,0 +1,150 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/SuzumiyaAoba/via/internal/config"
+)
+
+// ConfigFileName is the name of the configuration file stored on the remote
+const ConfigFileName = "config.yml"
+
+// FilesBundleName is the file holding the extra synced files on backends without directories
+const FilesBundleName = "files.json"
+
+// Supported backend names for SyncConfig.Backend
+const (
+	BackendGist   = "gist"
+	BackendGit    = "git"
+	BackendDir    = "dir"
+	BackendWebDAV = "webdav"
+)
+
+// ErrNotConfigured is returned when the sync settings lack the backend location
+var ErrNotConfigured = errors.New("sync backend not configured")
+
+// ErrRemoteNotFound is returned by Pull when nothing has been pushed to the backend yet
+var ErrRemoteNotFound = errors.New(ConfigFileName + " not found")
+
+// Status describes the state of the remote configuration
+type Status struct {
+	Backend   string    // Backend name (gist, git, dir, webdav)
+	Location  string    // Human readable remote location
+	Exists    bool      // Whether a configuration has been pushed yet
+	Revision  string    // Remote revision (commit, gist version, ETag or content hash)
+	UpdatedAt time.Time // Last modification time, zero if unknown
+}
+
+// Backend is a remote location the configuration can be synchronized with
+type Backend interface {
+	// Name returns a display name for messages (e.g. "Gist")
+	Name() string
+	// Pull fetches the remote configuration
+	Pull() (*config.Config, error)
+	// Push replaces the remote configuration
+	Push(cfg *config.Config) error
+	// Status reports the current remote revision
+	Status() (*Status, error)
+}
+
+// NewBackend creates the backend selected by the sync settings
+func NewBackend(cfg *config.SyncConfig) (Backend, error) {
+	switch cfg.BackendName() {
+	case BackendGist:
+		if cfg.GistID == "" {
+			return nil, ErrNotConfigured
+		}
+		return NewGistBackend(cfg.GistID, cfg.Token), nil
+	case BackendGit:
+		if cfg.Repo == "" {
+			return nil, ErrNotConfigured
+		}
+		return NewGitBackend(cfg.Repo, cfg.Branch), nil
+	case BackendDir:
+		if cfg.Path == "" {
+			return nil, ErrNotConfigured
+		}
+		return NewDirBackend(cfg.Path), nil
+	case BackendWebDAV:
+		if cfg.URL == "" {
+			return nil, ErrNotConfigured
+		}
+		return NewWebDAVBackend(cfg.URL, cfg.Username, cfg.Token), nil
+	default:
+		return nil, fmt.Errorf("unknown sync backend: %s", cfg.Backend)
+	}
+}
+
+// GistBackend syncs the configuration with a GitHub Gist
+type GistBackend struct {
+	GistID string
+	client *Client
+}
+
+func NewGistBackend(gistID, token string) *GistBackend {
+	return &GistBackend{
+		GistID: gistID,
+		client: NewClient(token),
+	}
+}
+
+func (b *GistBackend) Name() string { return "Gist" }
+
+func (b *GistBackend) Pull() (*config.Config, error) {
+	return b.client.GetGist(b.GistID)
+}
+
+func (b *GistBackend) Push(cfg *config.Config) error {
+	return b.client.UpdateGist(b.GistID, cfg)
+}
+
+func (b *GistBackend) PullFiles() (map[string][]byte, error) {
+	return b.client.GetGistFiles(b.GistID)
+}
+
+func (b *GistBackend) PushFiles(files map[string][]byte) error {
+	if len(files) == 0 {
+		return nil
+	}
+	return b.client.UpdateGistFiles(b.GistID, files)
+}
+
+func (b *GistBackend) Status() (*Status, error) {
+	return b.client.GetGistStatus(b.GistID)
+}
+
+func (b *GistBackend) Revisions() ([]Revision, error) {
+	history, err := b.client.GetGistRevisions(b.GistID)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions := make([]Revision, 0, len(history))
+	for _, h := range history {
+		revisions = append(revisions, Revision{ID: h.Version, CreatedAt: h.CommittedAt})
+	}
+	return revisions, nil
+}
+
+func (b *GistBackend) PullRevision(id string) (*config.Config, error) {
+	revisions, err := b.Revisions()
+	if err != nil {
+		return nil, err
+	}
+	revision, err := findRevision(revisions, id)
+	if err != nil {
+		return nil, err
+	}
+	return b.client.GetGistRevision(b.GistID, revision.ID)
+}
+
+// parseConfig decodes a configuration fetched from the given source
+func parseConfig(data []byte, source string) (*config.Config, error) {
+	cfg, err := config.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config from %s: %w", source, err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,372 @@
+package sync_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+
+	"github.com/SuzumiyaAoba/via/internal/config"
+	"github.com/SuzumiyaAoba/via/internal/sync"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// sharedBackendBehavior runs the common round-trip checks against a backend
+func sharedBackendBehavior(newBackend func() sync.Backend) {
+	It("should report a missing remote config", func() {
+		status, err := newBackend().Status()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(status.Exists).To(BeFalse())
+	})
+
+	It("should push and pull a config", func() {
+		backend := newBackend()
+		cfg := &config.Config{
+			Version: "1",
+			Rules:   []config.Rule{{Name: "PDF", Extensions: []string{"pdf"}, Command: "open {{.File}}"}},
+		}
+		Expect(backend.Push(cfg)).To(Succeed())
+
+		pulled, err := backend.Pull()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pulled.Rules).To(HaveLen(1))
+		Expect(pulled.Rules[0].Name).To(Equal("PDF"))
+	})
+
+	It("should push and pull extra files", func() {
+		backend := newBackend()
+		fileBackend, ok := backend.(sync.FileBackend)
+		Expect(ok).To(BeTrue())
+
+		files, err := fileBackend.PullFiles()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(BeEmpty())
+
+		Expect(backend.Push(&config.Config{Version: "1"})).To(Succeed())
+		Expect(fileBackend.PushFiles(map[string][]byte{
+			"profiles/work.yml": []byte("version: \"1\"\n"),
+			"scripts/open.sh":   []byte("#!/bin/sh\n"),
+		})).To(Succeed())
+
+		files, err = fileBackend.PullFiles()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(HaveKeyWithValue("profiles/work.yml", []byte("version: \"1\"\n")))
+		Expect(files).To(HaveKey("scripts/open.sh"))
+		Expect(files).NotTo(HaveKey(sync.ConfigFileName))
+
+		Expect(fileBackend.PushFiles(map[string][]byte{"scripts/open.sh": nil})).To(Succeed())
+		files, err = fileBackend.PullFiles()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(HaveLen(1))
+
+		// The config itself is unaffected
+		pulled, err := backend.Pull()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pulled.Version).To(Equal(config.CurrentVersion))
+	})
+
+	It("should change revision after push", func() {
+		backend := newBackend()
+		Expect(backend.Push(&config.Config{Version: "1"})).To(Succeed())
+		first, err := backend.Status()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(first.Exists).To(BeTrue())
+		Expect(first.Revision).NotTo(BeEmpty())
+
+		Expect(backend.Push(&config.Config{Version: "1", DefaultCommand: "vim {{.File}}"})).To(Succeed())
+		second, err := backend.Status()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(second.Revision).NotTo(Equal(first.Revision))
+	})
+}
+
+var _ = Describe("Backends", func() {
+	var tmpDir string
+
+	BeforeEach(func() {
+		tmpDir = GinkgoT().TempDir()
+	})
+
+	Describe("NewBackend", func() {
+		It("should default to gist", func() {
+			backend, err := sync.NewBackend(&config.SyncConfig{GistID: "123"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(backend).To(BeAssignableToTypeOf(&sync.GistBackend{}))
+		})
+
+		It("should select backend by name", func() {
+			backend, err := sync.NewBackend(&config.SyncConfig{Backend: "git", Repo: "/tmp/repo.git"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(backend).To(BeAssignableToTypeOf(&sync.GitBackend{}))
+
+			backend, err = sync.NewBackend(&config.SyncConfig{Backend: "dir", Path: "/mnt/shared"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(backend).To(BeAssignableToTypeOf(&sync.DirBackend{}))
+
+			backend, err = sync.NewBackend(&config.SyncConfig{Backend: "webdav", URL: "https://dav.example.com/via/"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(backend).To(BeAssignableToTypeOf(&sync.WebDAVBackend{}))
+			Expect(backend.(*sync.WebDAVBackend).URL).To(Equal("https://dav.example.com/via/config.yml"))
+		})
+
+		It("should return ErrNotConfigured without a location", func() {
+			_, err := sync.NewBackend(&config.SyncConfig{Backend: "dir"})
+			Expect(err).To(MatchError(sync.ErrNotConfigured))
+		})
+
+		It("should reject unknown backends", func() {
+			_, err := sync.NewBackend(&config.SyncConfig{Backend: "ftp"})
+			Expect(err).To(MatchError(ContainSubstring("unknown sync backend")))
+		})
+	})
+
+	Describe("DirBackend", func() {
+		sharedBackendBehavior(func() sync.Backend {
+			return sync.NewDirBackend(filepath.Join(tmpDir, "shared"))
+		})
+
+		It("should fail to pull when nothing was pushed", func() {
+			_, err := sync.NewDirBackend(tmpDir).Pull()
+			Expect(err).To(MatchError(sync.ErrRemoteNotFound))
+		})
+	})
+
+	Describe("GitBackend", func() {
+		var repo string
+
+		BeforeEach(func() {
+			if _, err := exec.LookPath("git"); err != nil {
+				Skip("git is not installed")
+			}
+			repo = filepath.Join(tmpDir, "remote.git")
+			out, err := exec.Command("git", "init", "-q", "--bare", repo).CombinedOutput()
+			Expect(err).NotTo(HaveOccurred(), string(out))
+		})
+
+		sharedBackendBehavior(func() sync.Backend {
+			return sync.NewGitBackend(repo, "")
+		})
+
+		It("should use the configured branch", func() {
+			backend := sync.NewGitBackend(repo, "configs")
+			Expect(backend.Push(&config.Config{Version: "1"})).To(Succeed())
+
+			_, err := sync.NewGitBackend(repo, "main").Pull()
+			Expect(err).To(MatchError(sync.ErrRemoteNotFound))
+
+			_, err = backend.Pull()
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should use relative repositories from outside the temporary clone", func() {
+			Expect(sync.NewGitBackend(sync.ResolveRepo("remote.git", tmpDir), "").Push(&config.Config{Version: "1"})).To(Succeed())
+
+			GinkgoT().Chdir(tmpDir)
+			backend := sync.NewGitBackend("remote.git", "")
+			Expect(backend.Repo).To(Equal(repo))
+			_, err := backend.Pull()
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		DescribeTable("ResolveRepo",
+			func(repo, expected string) {
+				originalHome := config.UserHomeDir
+				config.UserHomeDir = func() (string, error) { return "/home/user", nil }
+				defer func() { config.UserHomeDir = originalHome }()
+
+				Expect(sync.ResolveRepo(repo, "/home/user/.config/via")).To(Equal(expected))
+			},
+			Entry("relative path", "sync.git", "/home/user/.config/via/sync.git"),
+			Entry("parent directory", "../sync.git", "/home/user/.config/sync.git"),
+			Entry("relative path with a colon", "./a:b.git", "/home/user/.config/via/a:b.git"),
+			Entry("home directory", "~/sync/via.git", "/home/user/sync/via.git"),
+			Entry("absolute path", "/srv/sync.git", "/srv/sync.git"),
+			Entry("URL", "https://example.com/sync.git", "https://example.com/sync.git"),
+			Entry("scp-like address", "git@example.com:sync.git", "git@example.com:sync.git"),
+		)
+
+		It("should fail for unreachable repositories", func() {
+			_, err := sync.NewGitBackend(filepath.Join(tmpDir, "missing.git"), "").Status()
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should list and restore past revisions", func() {
+			backend := sync.NewGitBackend(repo, "")
+			Expect(backend.Push(&config.Config{Version: "1", DefaultCommand: "first"})).To(Succeed())
+			Expect(backend.Push(&config.Config{Version: "1", DefaultCommand: "second"})).To(Succeed())
+
+			revisions, err := backend.Revisions()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(revisions).To(HaveLen(2))
+			Expect(revisions[0].Message).To(ContainSubstring("Update via configuration"))
+
+			status, err := backend.Status()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(revisions[0].ID).To(Equal(status.Revision))
+
+			cfg, err := backend.PullRevision(revisions[1].ShortID())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.DefaultCommand).To(Equal("first"))
+
+			_, err = backend.PullRevision("0000000")
+			Expect(err).To(MatchError(ContainSubstring("not found")))
+		})
+	})
+
+	Describe("WebDAVBackend", func() {
+		var (
+			server  *httptest.Server
+			files   map[string][]byte
+			version int
+			auth    string
+		)
+
+		BeforeEach(func() {
+			files = map[string][]byte{}
+			version = 0
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				auth = r.Header.Get("Authorization")
+				switch r.Method {
+				case http.MethodPut:
+					data, _ := io.ReadAll(r.Body)
+					files[r.URL.Path] = data
+					version++
+					w.WriteHeader(http.StatusCreated)
+				case http.MethodGet, http.MethodHead:
+					data, ok := files[r.URL.Path]
+					if !ok {
+						w.WriteHeader(http.StatusNotFound)
+						return
+					}
+					w.Header().Set("ETag", `"v`+strconv.Itoa(version)+`"`)
+					w.WriteHeader(http.StatusOK)
+					if r.Method == http.MethodGet {
+						w.Write(data)
+					}
+				default:
+					w.WriteHeader(http.StatusMethodNotAllowed)
+				}
+			}))
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		sharedBackendBehavior(func() sync.Backend {
+			return sync.NewWebDAVBackend(server.URL+"/via/", "", "")
+		})
+
+		It("should send basic auth credentials", func() {
+			backend := sync.NewWebDAVBackend(server.URL+"/via/config.yml", "alice", "secret")
+			Expect(backend.Push(&config.Config{Version: "1"})).To(Succeed())
+			Expect(auth).To(HavePrefix("Basic "))
+		})
+
+		It("should fail to pull a missing file", func() {
+			_, err := sync.NewWebDAVBackend(server.URL+"/missing.yml", "", "").Pull()
+			Expect(err).To(MatchError(sync.ErrRemoteNotFound))
+		})
+	})
+
+	Describe("GistBackend", func() {
+		var (
+			server  *httptest.Server
+			origURL string
+		)
+
+		BeforeEach(func() {
+			origURL = sync.GitHubAPIURL
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{
+					"updated_at": "2025-01-02T03:04:05Z",
+					"history": [{"version": "abc123", "committed_at": "2025-01-02T03:04:05Z"}],
+					"files": {"config.yml": {"content": "version: \"1\""}}
+				}`))
+			}))
+			sync.GitHubAPIURL = server.URL
+		})
+
+		AfterEach(func() {
+			server.Close()
+			sync.GitHubAPIURL = origURL
+			os.Unsetenv("ENTRY_GITHUB_TOKEN")
+		})
+
+		It("should store extra files as flat gist files", func() {
+			var patch map[string]map[string]*sync.GistFile
+			server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				if r.Method == http.MethodPatch {
+					Expect(json.NewDecoder(r.Body).Decode(&patch)).To(Succeed())
+				}
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"files": {
+					"config.yml": {"content": "version: \"1\""},
+					"profiles__work.yml": {"content": "version: \"2\""}
+				}}`))
+			})
+
+			backend := sync.NewGistBackend("gist123", "token")
+			files, err := backend.PullFiles()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(files).To(Equal(map[string][]byte{"profiles/work.yml": []byte(`version: "2"`)}))
+
+			Expect(backend.PushFiles(map[string][]byte{
+				"scripts/open.sh":   []byte("#!/bin/sh"),
+				"profiles/work.yml": nil,
+			})).To(Succeed())
+			Expect(patch["files"]).To(HaveKeyWithValue("scripts__open.sh", &sync.GistFile{Content: "#!/bin/sh"}))
+			Expect(patch["files"]).To(HaveKeyWithValue("profiles__work.yml", BeNil()))
+		})
+
+		It("should restore past revisions", func() {
+			var requested []string
+			server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requested = append(requested, r.URL.Path)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusOK)
+				if r.URL.Path == "/gists/gist123/def456" {
+					w.Write([]byte(`{"files": {"config.yml": {"content": "version: \"1\"\ndefault_command: old"}}}`))
+					return
+				}
+				w.Write([]byte(`{
+					"history": [
+						{"version": "abc123", "committed_at": "2025-01-02T03:04:05Z"},
+						{"version": "def456", "committed_at": "2025-01-01T03:04:05Z"}
+					],
+					"files": {"config.yml": {"content": "version: \"1\""}}
+				}`))
+			})
+
+			backend := sync.NewGistBackend("gist123", "token")
+			revisions, err := backend.Revisions()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(revisions).To(HaveLen(2))
+			Expect(revisions[1].ID).To(Equal("def456"))
+
+			cfg, err := backend.PullRevision("def")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.DefaultCommand).To(Equal("old"))
+			Expect(requested).To(ContainElement("/gists/gist123/def456"))
+
+			_, err = backend.PullRevision("xyz")
+			Expect(err).To(MatchError(ContainSubstring("revision xyz not found")))
+		})
+
+		It("should report the latest revision", func() {
+			status, err := sync.NewGistBackend("gist123", "token").Status()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(status.Exists).To(BeTrue())
+			Expect(status.Revision).To(Equal("abc123"))
+			Expect(status.UpdatedAt.Year()).To(Equal(2025))
+		})
+	})
+})
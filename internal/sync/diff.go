@@ -0,0 +1,119 @@
+package sync
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/SuzumiyaAoba/via/internal/config"
+)
+
+// diffLines returns a line diff of a and b. Lines are prefixed with "  " when
+// unchanged, "- " when only in a and "+ " when only in b.
+func diffLines(a, b string) []string {
+	x := splitLines(a)
+	y := splitLines(b)
+
+	// Longest common subsequence table, filled from the end
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var out []string
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			out = append(out, "  "+x[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, "- "+x[i])
+			i++
+		default:
+			out = append(out, "+ "+y[j])
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		out = append(out, "- "+x[i])
+	}
+	for ; j < len(y); j++ {
+		out = append(out, "+ "+y[j])
+	}
+	return out
+}
+
+func splitLines(s string) []string {
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// Change is a rule, alias or setting that differs between two configs.
+// Values are rendered as YAML; an empty string means the item does not exist on that side.
+type Change struct {
+	Kind string // "rule", "alias" or "setting"
+	Key  string // Rule name, alias key or setting name
+	Old  string
+	New  string
+}
+
+// Symbol returns "+" for added, "-" for removed and "~" for modified items
+func (c Change) Symbol() string {
+	switch {
+	case c.Old == "":
+		return "+"
+	case c.New == "":
+		return "-"
+	}
+	return "~"
+}
+
+// String renders the change with a line diff of its YAML
+func (c Change) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s %q\n", c.Symbol(), c.Kind, c.Key)
+	for _, line := range diffLines(c.Old, c.New) {
+		b.WriteString("    " + line + "\n")
+	}
+	return b.String()
+}
+
+// Diff returns the changes needed to turn from into to. Settings come first,
+// then aliases by key and rules in the order of to. Sync settings are ignored.
+func Diff(from, to *config.Config) []Change {
+	var changes []Change
+	add := func(kind, key, old, new string) {
+		if old != new {
+			changes = append(changes, Change{Kind: kind, Key: key, Old: old, New: new})
+		}
+	}
+
+	for _, s := range settings {
+		add("setting", s.name, render(s.get(from)), render(s.get(to)))
+	}
+
+	for _, k := range aliasKeys(from, to) {
+		add("alias", k, renderAlias(from.Aliases, k), renderAlias(to.Aliases, k))
+	}
+
+	fromKeys, fromRules := keyRules(from.Rules)
+	toKeys, toRules := keyRules(to.Rules)
+	for _, k := range insertMissing(slices.Clone(toKeys), fromKeys) {
+		add("rule", k, renderRule(fromRules[k]), renderRule(toRules[k]))
+	}
+	return changes
+}
@@ -0,0 +1,51 @@
+package sync_test
+
+import (
+	"github.com/SuzumiyaAoba/via/internal/config"
+	"github.com/SuzumiyaAoba/via/internal/sync"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Diff", func() {
+	It("should report nothing for equal configs", func() {
+		cfg := &config.Config{Version: "1", Rules: []config.Rule{rule("A", "a")}}
+		Expect(sync.Diff(cfg, cfg)).To(BeEmpty())
+	})
+
+	It("should ignore sync settings", func() {
+		from := &config.Config{Version: "1"}
+		to := &config.Config{Version: "1", Sync: &config.SyncConfig{GistID: "123"}}
+		Expect(sync.Diff(from, to)).To(BeEmpty())
+	})
+
+	It("should list changes rule by rule", func() {
+		from := &config.Config{
+			Version: "1",
+			Rules:   []config.Rule{rule("A", "a"), rule("B", "b"), rule("C", "c")},
+			Aliases: map[string]string{"x": "A"},
+		}
+		to := &config.Config{
+			Version:        "1",
+			DefaultCommand: "vim {{.File}}",
+			Rules:          []config.Rule{rule("A", "a2"), rule("C", "c"), rule("D", "d")},
+		}
+
+		changes := sync.Diff(from, to)
+		var summary []string
+		for _, c := range changes {
+			summary = append(summary, c.Symbol()+" "+c.Kind+" "+c.Key)
+		}
+		Expect(summary).To(Equal([]string{
+			"~ setting default_command",
+			"- alias x",
+			"~ rule A",
+			"- rule B",
+			"+ rule D",
+		}))
+
+		Expect(changes[2].String()).To(ContainSubstring("- command: a\n"))
+		Expect(changes[2].String()).To(ContainSubstring("+ command: a2\n"))
+		Expect(changes[2].String()).To(ContainSubstring("  name: A\n"))
+	})
+})
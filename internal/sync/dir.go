@@ -0,0 +1,155 @@
+package sync
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/SuzumiyaAoba/via/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// DirBackend syncs the configuration with a local or shared directory (e.g. a mounted drive)
+type DirBackend struct {
+	Path string
+}
+
+func NewDirBackend(path string) *DirBackend {
+	return &DirBackend{Path: path}
+}
+
+func (b *DirBackend) Name() string { return "directory" }
+
+func (b *DirBackend) file() string {
+	return filepath.Join(b.Path, ConfigFileName)
+}
+
+func (b *DirBackend) Pull() (*config.Config, error) {
+	data, err := os.ReadFile(b.file())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w in %s", ErrRemoteNotFound, b.Path)
+		}
+		return nil, fmt.Errorf("failed to read remote config: %w", err)
+	}
+	return parseConfig(data, "directory")
+}
+
+func (b *DirBackend) Push(cfg *config.Config) error {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(b.file(), data); err != nil {
+		return fmt.Errorf("failed to write remote config: %w", err)
+	}
+	return nil
+}
+
+func (b *DirBackend) PullFiles() (map[string][]byte, error) {
+	files := map[string][]byte{}
+	err := filepath.WalkDir(b.Path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || !d.Type().IsRegular() || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+		rel, err := filepath.Rel(b.Path, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if key == ConfigFileName {
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		files[key] = data
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read remote files: %w", err)
+	}
+	return files, nil
+}
+
+func (b *DirBackend) PushFiles(files map[string][]byte) error {
+	for key, data := range files {
+		p := filepath.Join(b.Path, filepath.FromSlash(key))
+		if data == nil {
+			if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to delete remote %s: %w", key, err)
+			}
+			continue
+		}
+		if err := writeFileAtomic(p, data); err != nil {
+			return fmt.Errorf("failed to write remote %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// writeFileAtomic writes to a temporary file first so readers on other machines never see a partial file
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, ".via-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (b *DirBackend) Status() (*Status, error) {
+	status := &Status{
+		Backend:  BackendDir,
+		Location: b.Path,
+	}
+
+	info, err := os.Stat(b.file())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return status, nil
+		}
+		return nil, fmt.Errorf("failed to stat remote config: %w", err)
+	}
+
+	data, err := os.ReadFile(b.file())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read remote config: %w", err)
+	}
+
+	status.Exists = true
+	status.Revision = contentHash(data)
+	status.UpdatedAt = info.ModTime()
+	return status, nil
+}
+
+// contentHash returns a short hash identifying file content
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:12]
+}
@@ -0,0 +1,363 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/SuzumiyaAoba/via/internal/config"
+	"github.com/SuzumiyaAoba/via/internal/history"
+)
+
+// HistoryFileName is the remote name of the history file
+const HistoryFileName = "history.json"
+
+// DefaultInclude lists the extra files synced when SyncConfig.Include is empty
+var DefaultInclude = []string{"profiles", "scripts"}
+
+// syncedDirs are the directories next to the config file that can be synced
+var syncedDirs = []string{"profiles", "scripts"}
+
+// FileBackend is a backend that can also sync extra files next to the config.
+// Files are keyed by slash separated paths relative to the config directory
+// (e.g. "profiles/work.yml"); a nil content deletes the file.
+type FileBackend interface {
+	// PullFiles fetches all extra files stored on the remote
+	PullFiles() (map[string][]byte, error)
+	// PushFiles writes or deletes the given files on the remote
+	PushFiles(files map[string][]byte) error
+}
+
+// Selected reports whether a file is synced according to the include and exclude settings.
+// A pattern matches a file by glob, or a directory and everything below it.
+func Selected(cfg *config.SyncConfig, key string) bool {
+	include := cfg.Include
+	if len(include) == 0 {
+		include = DefaultInclude
+	}
+	return matchesAny(include, key) && !matchesAny(cfg.Exclude, key)
+}
+
+func matchesAny(patterns []string, key string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.TrimSuffix(pattern, "/")
+		if pattern == key || strings.HasPrefix(key, pattern+"/") {
+			return true
+		}
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// SelectFiles returns the files that are synced according to the sync settings
+func SelectFiles(cfg *config.SyncConfig, files map[string][]byte) map[string][]byte {
+	selected := make(map[string][]byte, len(files))
+	for key, data := range files {
+		if Selected(cfg, key) {
+			selected[key] = data
+		}
+	}
+	return selected
+}
+
+// LocalFiles maps synced files to the local file system
+type LocalFiles struct {
+	Root        string // Directory containing the config file
+	HistoryPath string // Location of the history file
+}
+
+// localPath returns where a synced file lives on this machine
+func (l *LocalFiles) localPath(key string) (string, error) {
+	if key == HistoryFileName {
+		return l.HistoryPath, nil
+	}
+	clean := path.Clean(key)
+	dir, _, _ := strings.Cut(clean, "/")
+	if !slices.Contains(syncedDirs, dir) || clean == dir || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("refusing to sync unexpected file %q", key)
+	}
+	return filepath.Join(l.Root, filepath.FromSlash(clean)), nil
+}
+
+// Collect reads the local files selected by the sync settings
+func (l *LocalFiles) Collect(cfg *config.SyncConfig) (map[string][]byte, error) {
+	files := map[string][]byte{}
+
+	for _, dir := range syncedDirs {
+		root := filepath.Join(l.Root, dir)
+		err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			// Sync state, credentials and backups kept next to profiles are never synced
+			if d.IsDir() && (d.Name() == "sync" || d.Name() == "credentials" || d.Name() == "backups") && p != root {
+				return filepath.SkipDir
+			}
+			if d.IsDir() || !d.Type().IsRegular() {
+				return nil
+			}
+			// Neither are lock files and unfinished atomic writes
+			if strings.HasPrefix(d.Name(), ".") {
+				return nil
+			}
+			rel, err := filepath.Rel(l.Root, p)
+			if err != nil {
+				return err
+			}
+			key := filepath.ToSlash(rel)
+			if !Selected(cfg, key) {
+				return nil
+			}
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			files[key] = data
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+		}
+	}
+
+	if l.HistoryPath != "" && Selected(cfg, HistoryFileName) {
+		data, err := os.ReadFile(l.HistoryPath)
+		if err == nil {
+			files[HistoryFileName] = data
+		} else if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read history: %w", err)
+		}
+	}
+
+	return files, nil
+}
+
+// Apply writes or deletes local files
+func (l *LocalFiles) Apply(files map[string][]byte) error {
+	for key, data := range files {
+		p, err := l.localPath(key)
+		if err != nil {
+			return err
+		}
+		if data == nil {
+			if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to delete %s: %w", key, err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", key, err)
+		}
+		if err := os.WriteFile(p, data, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// Direction is the direction of a sync operation
+type Direction int
+
+const (
+	DirectionPush Direction = iota
+	DirectionPull
+)
+
+// FilePlan describes how to bring the extra files in sync
+type FilePlan struct {
+	Local  map[string][]byte // Files to write locally, nil content deletes
+	Remote map[string][]byte // Files to upload, nil content deletes
+	Base   map[string]string // File hashes to record as the new sync base
+}
+
+// Empty reports whether the plan changes nothing
+func (p *FilePlan) Empty() bool {
+	return len(p.Local) == 0 && len(p.Remote) == 0
+}
+
+// PlanFiles compares the local and remote files against the hashes recorded at
+// the last sync. Files changed on one side are copied in the sync direction only,
+// so a push never overwrites local files and a pull never uploads. Files changed
+// on both sides are passed to resolve and the chosen version is written to both
+// sides; the history file is merged instead. If resolve is nil, conflicts are
+// reported in a *ConflictError.
+func PlanFiles(base map[string]string, local, remote map[string][]byte, direction Direction, resolve Resolver) (*FilePlan, error) {
+	plan := &FilePlan{
+		Local:  map[string][]byte{},
+		Remote: map[string][]byte{},
+		Base:   map[string]string{},
+	}
+
+	var keys []string
+	for _, k := range slices.Concat(mapKeys(base), mapKeys(local), mapKeys(remote)) {
+		if !slices.Contains(keys, k) {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+
+	var conflicts []Conflict
+	for _, k := range keys {
+		localHash, remoteHash, baseHash := fileHash(local, k), fileHash(remote, k), base[k]
+		keepBase := func() {
+			if baseHash != "" {
+				plan.Base[k] = baseHash
+			}
+		}
+
+		switch {
+		case localHash == remoteHash:
+			if localHash != "" {
+				plan.Base[k] = localHash
+			}
+		case remoteHash == baseHash:
+			// Only changed locally
+			if direction == DirectionPush {
+				plan.Remote[k] = local[k]
+				if localHash != "" {
+					plan.Base[k] = localHash
+				}
+			} else {
+				keepBase()
+			}
+		case localHash == baseHash:
+			// Only changed remotely
+			if direction == DirectionPull {
+				plan.Local[k] = remote[k]
+				if remoteHash != "" {
+					plan.Base[k] = remoteHash
+				}
+			} else {
+				keepBase()
+			}
+		default:
+			var result []byte
+			if k == HistoryFileName && local[k] != nil && remote[k] != nil {
+				merged, err := mergeHistoryFiles(local[k], remote[k])
+				if err != nil {
+					return nil, err
+				}
+				result = merged
+			} else {
+				c := Conflict{Kind: "file", Key: k, Local: string(local[k]), Remote: string(remote[k])}
+				if resolve == nil {
+					conflicts = append(conflicts, c)
+					continue
+				}
+				side, err := resolve(c)
+				if err != nil {
+					return nil, err
+				}
+				result = local[k]
+				if side == SideRemote {
+					result = remote[k]
+				}
+			}
+
+			if fileHash(local, k) != hashOf(result) {
+				plan.Local[k] = result
+			}
+			if fileHash(remote, k) != hashOf(result) {
+				plan.Remote[k] = result
+			}
+			if result != nil {
+				plan.Base[k] = hashOf(result)
+			}
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return nil, &ConflictError{Conflicts: conflicts}
+	}
+	return plan, nil
+}
+
+// DiffFiles returns the changes needed to turn the files in from into to
+func DiffFiles(from, to map[string][]byte) []Change {
+	keys := mapKeys(from)
+	for k := range to {
+		if _, ok := from[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+
+	var changes []Change
+	for _, k := range keys {
+		if fileHash(from, k) != fileHash(to, k) {
+			changes = append(changes, Change{Kind: "file", Key: k, Old: string(from[k]), New: string(to[k])})
+		}
+	}
+	return changes
+}
+
+// Overlay returns a copy of files with changes applied, nil content deletes
+func Overlay(files, changes map[string][]byte) map[string][]byte {
+	result := make(map[string][]byte, len(files))
+	for k, data := range files {
+		result[k] = data
+	}
+	for k, data := range changes {
+		if data == nil {
+			delete(result, k)
+		} else {
+			result[k] = data
+		}
+	}
+	return result
+}
+
+// FileHashes returns the hashes of files, as recorded in the sync state
+func FileHashes(files map[string][]byte) map[string]string {
+	hashes := make(map[string]string, len(files))
+	for k := range files {
+		hashes[k] = fileHash(files, k)
+	}
+	return hashes
+}
+
+func fileHash(files map[string][]byte, key string) string {
+	data, ok := files[key]
+	if !ok {
+		return ""
+	}
+	return hashOf(data)
+}
+
+// hashOf hashes file content; nil means the file does not exist
+func hashOf(data []byte) string {
+	if data == nil {
+		return ""
+	}
+	return contentHash(data)
+}
+
+func mapKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// mergeHistoryFiles combines two history files instead of picking one
+func mergeHistoryFiles(a, b []byte) ([]byte, error) {
+	var left, right []history.HistoryEntry
+	if err := json.Unmarshal(a, &left); err != nil {
+		return nil, fmt.Errorf("failed to parse local history: %w", err)
+	}
+	if err := json.Unmarshal(b, &right); err != nil {
+		return nil, fmt.Errorf("failed to parse remote history: %w", err)
+	}
+	return json.MarshalIndent(history.MergeEntries(left, right), "", "  ")
+}
@@ -0,0 +1,256 @@
+package sync_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/SuzumiyaAoba/via/internal/config"
+	"github.com/SuzumiyaAoba/via/internal/history"
+	"github.com/SuzumiyaAoba/via/internal/sync"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Files", func() {
+	Describe("Selected", func() {
+		It("should sync profiles and scripts by default", func() {
+			cfg := &config.SyncConfig{}
+			Expect(sync.Selected(cfg, "profiles/work.yml")).To(BeTrue())
+			Expect(sync.Selected(cfg, "scripts/tools/open.sh")).To(BeTrue())
+			Expect(sync.Selected(cfg, sync.HistoryFileName)).To(BeFalse())
+		})
+
+		It("should apply include and exclude patterns", func() {
+			cfg := &config.SyncConfig{
+				Include: []string{"profiles/", sync.HistoryFileName},
+				Exclude: []string{"profiles/*-local.yml"},
+			}
+			Expect(sync.Selected(cfg, "profiles/work.yml")).To(BeTrue())
+			Expect(sync.Selected(cfg, "profiles/home-local.yml")).To(BeFalse())
+			Expect(sync.Selected(cfg, "scripts/open.sh")).To(BeFalse())
+			Expect(sync.Selected(cfg, sync.HistoryFileName)).To(BeTrue())
+		})
+	})
+
+	Describe("LocalFiles", func() {
+		var (
+			root  string
+			local *sync.LocalFiles
+		)
+
+		write := func(path, content string) {
+			Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+			Expect(os.WriteFile(path, []byte(content), 0644)).To(Succeed())
+		}
+
+		BeforeEach(func() {
+			root = GinkgoT().TempDir()
+			local = &sync.LocalFiles{Root: root, HistoryPath: filepath.Join(root, "history", "history.json")}
+		})
+
+		It("should collect the selected files", func() {
+			write(filepath.Join(root, "config.yml"), "version: '1'")
+			write(filepath.Join(root, "profiles", "work.yml"), "work")
+			write(filepath.Join(root, "profiles", "credentials", "work.yml"), "secret")
+			write(filepath.Join(root, "profiles", "sync", "work.state.json"), "{}")
+			write(filepath.Join(root, "scripts", "open.sh"), "script")
+			write(local.HistoryPath, "[]")
+
+			files, err := local.Collect(&config.SyncConfig{Include: []string{"profiles", sync.HistoryFileName}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(files).To(Equal(map[string][]byte{
+				"profiles/work.yml":  []byte("work"),
+				sync.HistoryFileName: []byte("[]"),
+			}))
+		})
+
+		It("should write and delete files", func() {
+			write(filepath.Join(root, "scripts", "old.sh"), "old")
+
+			Expect(local.Apply(map[string][]byte{
+				"scripts/old.sh":     nil,
+				"profiles/work.yml":  []byte("work"),
+				sync.HistoryFileName: []byte("[]"),
+			})).To(Succeed())
+
+			Expect(filepath.Join(root, "scripts", "old.sh")).NotTo(BeAnExistingFile())
+			Expect(filepath.Join(root, "profiles", "work.yml")).To(BeAnExistingFile())
+			Expect(local.HistoryPath).To(BeAnExistingFile())
+		})
+
+		It("should refuse files outside the synced directories", func() {
+			Expect(local.Apply(map[string][]byte{"../evil.sh": []byte("x")})).To(HaveOccurred())
+			Expect(local.Apply(map[string][]byte{"profiles/../../evil.sh": []byte("x")})).To(HaveOccurred())
+			Expect(local.Apply(map[string][]byte{"config.yml": []byte("x")})).To(HaveOccurred())
+		})
+	})
+
+	Describe("Snapshot", func() {
+		It("should restore the config, sync state and changed files", func() {
+			root := GinkgoT().TempDir()
+			configPath := filepath.Join(root, "config.yml")
+			local := &sync.LocalFiles{Root: root, HistoryPath: filepath.Join(root, "history.json")}
+
+			Expect(os.WriteFile(configPath, []byte("version: '1'\n"), 0644)).To(Succeed())
+			Expect(local.Apply(map[string][]byte{"profiles/work.yml": []byte("old")})).To(Succeed())
+			Expect(sync.SaveState(configPath, &sync.State{Revision: "rev1"})).To(Succeed())
+
+			Expect(sync.TakeSnapshot(configPath, "pull", local, []string{"profiles/work.yml", "scripts/new.sh"})).To(Succeed())
+
+			// Simulate the pull
+			Expect(os.WriteFile(configPath, []byte("version: '2'\n"), 0644)).To(Succeed())
+			Expect(local.Apply(map[string][]byte{"profiles/work.yml": []byte("new"), "scripts/new.sh": []byte("x")})).To(Succeed())
+			Expect(sync.SaveState(configPath, &sync.State{Revision: "rev2"})).To(Succeed())
+
+			snapshot, err := sync.LatestSnapshot(configPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(snapshot.Reason).To(Equal("pull"))
+			Expect(snapshot.Restore(configPath, local)).To(Succeed())
+
+			Expect(os.ReadFile(configPath)).To(Equal([]byte("version: '1'\n")))
+			Expect(os.ReadFile(filepath.Join(root, "profiles", "work.yml"))).To(Equal([]byte("old")))
+			Expect(filepath.Join(root, "scripts", "new.sh")).NotTo(BeAnExistingFile())
+			state, err := sync.LoadState(configPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(state.Revision).To(Equal("rev1"))
+
+			// A restored snapshot is consumed
+			Expect(sync.LatestSnapshot(configPath)).To(BeNil())
+		})
+
+		It("should keep only the newest snapshots", func() {
+			root := GinkgoT().TempDir()
+			configPath := filepath.Join(root, "config.yml")
+			Expect(os.WriteFile(configPath, []byte("version: '1'\n"), 0644)).To(Succeed())
+
+			for i := 0; i < sync.MaxSnapshots+2; i++ {
+				Expect(sync.TakeSnapshot(configPath, "pull", &sync.LocalFiles{Root: root}, nil)).To(Succeed())
+			}
+			entries, err := os.ReadDir(sync.SnapshotDir(configPath))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(HaveLen(sync.MaxSnapshots))
+		})
+	})
+
+	Describe("PlanFiles", func() {
+		var base map[string]string
+
+		BeforeEach(func() {
+			base = sync.FileHashes(map[string][]byte{
+				"profiles/a.yml": []byte("a"),
+				"profiles/b.yml": []byte("b"),
+			})
+		})
+
+		It("should copy one-sided changes in the sync direction only", func() {
+			local := map[string][]byte{"profiles/a.yml": []byte("a2"), "profiles/b.yml": []byte("b")}
+			remote := map[string][]byte{"profiles/a.yml": []byte("a"), "profiles/b.yml": []byte("b2"), "profiles/c.yml": []byte("c")}
+
+			push, err := sync.PlanFiles(base, local, remote, sync.DirectionPush, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(push.Remote).To(Equal(map[string][]byte{"profiles/a.yml": []byte("a2")}))
+			Expect(push.Local).To(BeEmpty())
+			// Files still differing keep their old base
+			Expect(push.Base["profiles/b.yml"]).To(Equal(base["profiles/b.yml"]))
+			Expect(push.Base).NotTo(HaveKey("profiles/c.yml"))
+
+			pull, err := sync.PlanFiles(base, local, remote, sync.DirectionPull, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pull.Local).To(Equal(map[string][]byte{"profiles/b.yml": []byte("b2"), "profiles/c.yml": []byte("c")}))
+			Expect(pull.Remote).To(BeEmpty())
+		})
+
+		It("should propagate deletions", func() {
+			local := map[string][]byte{"profiles/b.yml": []byte("b")}
+			remote := map[string][]byte{"profiles/a.yml": []byte("a"), "profiles/b.yml": []byte("b")}
+
+			push, err := sync.PlanFiles(base, local, remote, sync.DirectionPush, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(push.Remote).To(HaveKeyWithValue("profiles/a.yml", BeNil()))
+			Expect(push.Base).NotTo(HaveKey("profiles/a.yml"))
+		})
+
+		It("should report conflicts without a resolver", func() {
+			local := map[string][]byte{"profiles/a.yml": []byte("local"), "profiles/b.yml": []byte("b")}
+			remote := map[string][]byte{"profiles/a.yml": []byte("remote"), "profiles/b.yml": []byte("b")}
+
+			_, err := sync.PlanFiles(base, local, remote, sync.DirectionPull, nil)
+			var conflictErr *sync.ConflictError
+			Expect(errors.As(err, &conflictErr)).To(BeTrue())
+			Expect(conflictErr.Conflicts[0].Kind).To(Equal("file"))
+			Expect(conflictErr.Conflicts[0].Key).To(Equal("profiles/a.yml"))
+
+			plan, err := sync.PlanFiles(base, local, remote, sync.DirectionPull, func(c sync.Conflict) (sync.Side, error) {
+				return sync.SideLocal, nil
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(plan.Remote).To(Equal(map[string][]byte{"profiles/a.yml": []byte("local")}))
+			Expect(plan.Local).To(BeEmpty())
+		})
+
+		It("should merge history instead of reporting a conflict", func() {
+			now := time.Now().Truncate(time.Second)
+			encode := func(entries ...history.HistoryEntry) []byte {
+				data, err := json.Marshal(entries)
+				Expect(err).NotTo(HaveOccurred())
+				return data
+			}
+			shared := history.HistoryEntry{Timestamp: now.Add(-time.Hour), Command: "shared.txt"}
+			base := sync.FileHashes(map[string][]byte{sync.HistoryFileName: encode(shared)})
+			local := map[string][]byte{sync.HistoryFileName: encode(history.HistoryEntry{Timestamp: now, Command: "local.txt"}, shared)}
+			remote := map[string][]byte{sync.HistoryFileName: encode(history.HistoryEntry{Timestamp: now.Add(-time.Minute), Command: "remote.txt"}, shared)}
+
+			plan, err := sync.PlanFiles(base, local, remote, sync.DirectionPull, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			var merged []history.HistoryEntry
+			Expect(json.Unmarshal(plan.Local[sync.HistoryFileName], &merged)).To(Succeed())
+			Expect(merged).To(HaveLen(3))
+			Expect(merged[0].Command).To(Equal("local.txt"))
+			Expect(merged[1].Command).To(Equal("remote.txt"))
+			Expect(plan.Remote).To(HaveKey(sync.HistoryFileName))
+		})
+	})
+
+	Describe("Host sections", func() {
+		var local *config.Config
+
+		BeforeEach(func() {
+			local = &config.Config{
+				Version:        "1",
+				DefaultCommand: "xdg-open {{.File}}",
+				Aliases:        map[string]string{"x": "A"},
+				Rules:          []config.Rule{rule("A", "a")},
+				Sync:           &config.SyncConfig{Path: "/mnt", HostSections: []string{"default_command", "aliases"}},
+			}
+		})
+
+		It("should strip the sync settings and host sections", func() {
+			shared, err := sync.Shareable(local, local.Sync)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(shared.Sync).To(BeNil())
+			Expect(shared.DefaultCommand).To(BeEmpty())
+			Expect(shared.Aliases).To(BeNil())
+			Expect(shared.Rules).To(HaveLen(1))
+			Expect(local.DefaultCommand).NotTo(BeEmpty())
+		})
+
+		It("should restore the local host sections", func() {
+			remote := &config.Config{Version: "1", DefaultCommand: "open {{.File}}", Rules: []config.Rule{rule("B", "b")}}
+
+			result := sync.WithHostSections(remote, local)
+			Expect(result.DefaultCommand).To(Equal("xdg-open {{.File}}"))
+			Expect(result.Aliases).To(Equal(local.Aliases))
+			Expect(result.Rules[0].Name).To(Equal("B"))
+			Expect(result.Sync).To(Equal(local.Sync))
+		})
+
+		It("should reject unknown sections", func() {
+			_, err := sync.Shareable(local, &config.SyncConfig{HostSections: []string{"colors"}})
+			Expect(err).To(MatchError(ContainSubstring("unknown host section")))
+		})
+	})
+})
@@ -0,0 +1,359 @@
+package sync
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/SuzumiyaAoba/via/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultGitBranch is used when no branch is configured
+const DefaultGitBranch = "main"
+
+// GitBackend syncs the configuration with a git repository.
+// Repo can be any URL or path git understands, including a local bare repository.
+type GitBackend struct {
+	Repo   string
+	Branch string
+}
+
+// NewGitBackend creates a backend for repo. Git runs inside a temporary clone,
+// so a relative local path is made absolute first; use ResolveRepo to resolve
+// it against another directory than the working directory.
+func NewGitBackend(repo, branch string) *GitBackend {
+	if branch == "" {
+		branch = DefaultGitBranch
+	}
+	if isLocalRepo(repo) {
+		if abs, err := filepath.Abs(repo); err == nil {
+			repo = abs
+		}
+	}
+	return &GitBackend{Repo: repo, Branch: branch}
+}
+
+// ResolveRepo resolves a relative local repository path against baseDir and
+// "~/" against the home directory. URLs and scp-like addresses (host:path) are
+// returned unchanged.
+func ResolveRepo(repo, baseDir string) string {
+	if !isLocalRepo(repo) || filepath.IsAbs(repo) {
+		return repo
+	}
+	if strings.HasPrefix(repo, "~/") {
+		if home, err := config.UserHomeDir(); err == nil {
+			return filepath.Join(home, repo[2:])
+		}
+		return repo
+	}
+	return filepath.Join(baseDir, repo)
+}
+
+// isLocalRepo reports whether git treats repo as a local path: it is not a URL
+// and has no colon before the first slash.
+func isLocalRepo(repo string) bool {
+	if repo == "" || strings.Contains(repo, "://") {
+		return false
+	}
+	colon := strings.Index(repo, ":")
+	return colon < 0 || strings.Contains(repo[:colon], "/")
+}
+
+func (b *GitBackend) Name() string { return "git repository" }
+
+func (b *GitBackend) Pull() (*config.Config, error) {
+	dir, err := os.MkdirTemp("", "via-sync-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	found, err := b.fetch(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("%w: branch %s does not exist in %s", ErrRemoteNotFound, b.Branch, b.Repo)
+	}
+
+	data, err := runGit(dir, "show", "FETCH_HEAD:"+ConfigFileName)
+	if err != nil {
+		return nil, fmt.Errorf("%w in %s", ErrRemoteNotFound, b.Repo)
+	}
+	return parseConfig(data, "git repository")
+}
+
+func (b *GitBackend) Push(cfg *config.Config) error {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+
+	return b.commit(func(dir string) error {
+		if err := os.WriteFile(filepath.Join(dir, ConfigFileName), data, 0644); err != nil {
+			return fmt.Errorf("failed to write config: %w", err)
+		}
+		return nil
+	})
+}
+
+func (b *GitBackend) PullFiles() (map[string][]byte, error) {
+	dir, err := os.MkdirTemp("", "via-sync-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string][]byte{}
+	found, err := b.fetch(dir)
+	if err != nil || !found {
+		return files, err
+	}
+
+	out, err := runGit(dir, "ls-tree", "-r", "-z", "--name-only", "FETCH_HEAD")
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range strings.Split(string(out), "\x00") {
+		if key == "" || key == ConfigFileName {
+			continue
+		}
+		data, err := runGit(dir, "show", "FETCH_HEAD:"+key)
+		if err != nil {
+			return nil, err
+		}
+		files[key] = data
+	}
+	return files, nil
+}
+
+func (b *GitBackend) PushFiles(files map[string][]byte) error {
+	if len(files) == 0 {
+		return nil
+	}
+
+	return b.commit(func(dir string) error {
+		for key, data := range files {
+			p := filepath.Join(dir, filepath.FromSlash(key))
+			if data == nil {
+				if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+					return fmt.Errorf("failed to delete %s: %w", key, err)
+				}
+				continue
+			}
+			if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+				return fmt.Errorf("failed to create directory for %s: %w", key, err)
+			}
+			if err := os.WriteFile(p, data, 0644); err != nil {
+				return fmt.Errorf("failed to write %s: %w", key, err)
+			}
+		}
+		return nil
+	})
+}
+
+// commit checks out the sync branch in a scratch repository, lets update change
+// the work tree and pushes the result as a new commit
+func (b *GitBackend) commit(update func(dir string) error) error {
+	dir, err := os.MkdirTemp("", "via-sync-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	found, err := b.fetch(dir)
+	if err != nil {
+		return err
+	}
+	if found {
+		if _, err := runGit(dir, "checkout", "-q", "-B", b.Branch, "FETCH_HEAD"); err != nil {
+			return err
+		}
+	} else {
+		if _, err := runGit(dir, "checkout", "-q", "-b", b.Branch); err != nil {
+			return err
+		}
+	}
+
+	if err := update(dir); err != nil {
+		return err
+	}
+	if _, err := runGit(dir, "add", "-A"); err != nil {
+		return err
+	}
+
+	// Nothing to do if the remote already has this exact content
+	changes, err := runGit(dir, "status", "--porcelain")
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(changes)) == 0 {
+		return nil
+	}
+
+	commitArgs := []string{"commit", "-q", "-m", "Update via configuration (" + time.Now().Format(time.RFC3339) + ")"}
+	if _, err := runGit(dir, "config", "user.email"); err != nil {
+		// Fall back to a neutral identity when git is not configured on this machine
+		commitArgs = append([]string{"-c", "user.name=via", "-c", "user.email=via@localhost"}, commitArgs...)
+	}
+	if _, err := runGit(dir, commitArgs...); err != nil {
+		return err
+	}
+
+	if _, err := runGit(dir, "push", "-q", b.Repo, "HEAD:refs/heads/"+b.Branch); err != nil {
+		return fmt.Errorf("failed to push to %s: %w", b.Repo, err)
+	}
+	return nil
+}
+
+func (b *GitBackend) Status() (*Status, error) {
+	status := &Status{
+		Backend:  BackendGit,
+		Location: b.Repo + "#" + b.Branch,
+	}
+
+	dir, err := os.MkdirTemp("", "via-sync-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	found, err := b.fetch(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return status, nil
+	}
+
+	out, err := runGit(dir, "log", "-1", "--format=%H %cI", "FETCH_HEAD")
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) == 2 {
+		status.Revision = fields[0]
+		status.UpdatedAt, _ = time.Parse(time.RFC3339, fields[1])
+	}
+
+	if _, err := runGit(dir, "cat-file", "-e", "FETCH_HEAD:"+ConfigFileName); err == nil {
+		status.Exists = true
+	}
+	return status, nil
+}
+
+func (b *GitBackend) Revisions() ([]Revision, error) {
+	dir, err := os.MkdirTemp("", "via-sync-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	found, err := b.fetchHistory(dir)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	out, err := runGit(dir, "log", "--format=%H%x00%cI%x00%s", "FETCH_HEAD", "--", ConfigFileName)
+	if err != nil {
+		return nil, err
+	}
+
+	var revisions []Revision
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.SplitN(line, "\x00", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		createdAt, _ := time.Parse(time.RFC3339, fields[1])
+		revisions = append(revisions, Revision{ID: fields[0], CreatedAt: createdAt, Message: fields[2]})
+	}
+	return revisions, nil
+}
+
+func (b *GitBackend) PullRevision(id string) (*config.Config, error) {
+	dir, err := os.MkdirTemp("", "via-sync-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	found, err := b.fetchHistory(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("%w: branch %s does not exist in %s", ErrRemoteNotFound, b.Branch, b.Repo)
+	}
+
+	// Only commits of the sync branch are accepted, not arbitrary refs
+	commit, err := runGit(dir, "rev-parse", "--verify", "--quiet", id+"^{commit}")
+	if err != nil {
+		return nil, fmt.Errorf("revision %s not found", id)
+	}
+	rev := strings.TrimSpace(string(commit))
+	if _, err := runGit(dir, "merge-base", "--is-ancestor", rev, "FETCH_HEAD"); err != nil {
+		return nil, fmt.Errorf("revision %s is not on branch %s", id, b.Branch)
+	}
+
+	data, err := runGit(dir, "show", rev+":"+ConfigFileName)
+	if err != nil {
+		return nil, fmt.Errorf("%w in revision %s", ErrRemoteNotFound, id)
+	}
+	return parseConfig(data, "git repository")
+}
+
+// fetch initializes a scratch repository in dir and fetches the sync branch into FETCH_HEAD.
+// It returns false if the remote does not have the branch yet.
+func (b *GitBackend) fetch(dir string) (bool, error) {
+	return b.fetchBranch(dir, "--depth", "1")
+}
+
+// fetchHistory is like fetch, but fetches the full history of the sync branch
+func (b *GitBackend) fetchHistory(dir string) (bool, error) {
+	return b.fetchBranch(dir)
+}
+
+// fetchBranch implements fetch; args are passed on to git fetch
+func (b *GitBackend) fetchBranch(dir string, args ...string) (bool, error) {
+	if _, err := runGit(dir, "init", "-q"); err != nil {
+		return false, err
+	}
+
+	refs, err := runGit(dir, "ls-remote", "--heads", b.Repo, b.Branch)
+	if err != nil {
+		return false, fmt.Errorf("failed to reach %s: %w", b.Repo, err)
+	}
+	if len(bytes.TrimSpace(refs)) == 0 {
+		return false, nil
+	}
+
+	fetchArgs := append(append([]string{"fetch", "-q"}, args...), b.Repo, "refs/heads/"+b.Branch)
+	if _, err := runGit(dir, fetchArgs...); err != nil {
+		return false, fmt.Errorf("failed to fetch from %s: %w", b.Repo, err)
+	}
+	return true, nil
+}
+
+// runGit runs a git command in dir and returns its standard output
+func runGit(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			return nil, fmt.Errorf("git %s failed: %w", args[0], err)
+		}
+		return nil, fmt.Errorf("git %s failed: %s", args[0], msg)
+	}
+	return out, nil
+}
@@ -0,0 +1,62 @@
+package sync
+
+import (
+	"fmt"
+
+	"github.com/SuzumiyaAoba/via/internal/config"
+)
+
+// hostSections are the top-level config sections that can be kept on one machine.
+// The sync section itself is always host specific.
+var hostSections = map[string]func(dst, src *config.Config){
+	"default_command": func(dst, src *config.Config) { dst.DefaultCommand = src.DefaultCommand },
+	"default":         func(dst, src *config.Config) { dst.Default = src.Default },
+	"aliases":         func(dst, src *config.Config) { dst.Aliases = src.Aliases },
+	"rules":           func(dst, src *config.Config) { dst.Rules = src.Rules },
+	"history":         func(dst, src *config.Config) { dst.History = src.History },
+	"profiles":        func(dst, src *config.Config) { dst.Profiles = src.Profiles },
+}
+
+// Shareable returns the part of cfg that is synced: a copy without the sync
+// settings and the host sections listed in syncCfg.
+func Shareable(cfg *config.Config, syncCfg *config.SyncConfig) (*config.Config, error) {
+	shared := *cfg
+	shared.Sync = nil
+	if syncCfg == nil {
+		return &shared, nil
+	}
+
+	empty := &config.Config{}
+	for _, name := range syncCfg.HostSections {
+		reset, ok := hostSections[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown host section in sync settings: %s", name)
+		}
+		reset(&shared, empty)
+	}
+	return &shared, nil
+}
+
+// WithHostSections returns a copy of shared with the sync settings and host
+// sections of local restored, ready to be saved on this machine.
+func WithHostSections(shared, local *config.Config) *config.Config {
+	var sections []string
+	if local.Sync != nil {
+		sections = local.Sync.HostSections
+	}
+	result := CopySections(shared, local, sections)
+	result.Sync = local.Sync
+	return result
+}
+
+// CopySections returns a copy of dst with the given sections taken from src.
+// Pushing uses it to leave the remote's version of host sections untouched.
+func CopySections(dst, src *config.Config, sections []string) *config.Config {
+	result := *dst
+	for _, name := range sections {
+		if restore, ok := hostSections[name]; ok {
+			restore(&result, src)
+		}
+	}
+	return &result
+}
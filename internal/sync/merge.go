@@ -0,0 +1,366 @@
+package sync
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/SuzumiyaAoba/via/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// Relation describes how the local config relates to the remote one
+type Relation int
+
+const (
+	InSync   Relation = iota // Neither side changed since the last sync
+	Ahead                    // Only the local config changed
+	Behind                   // Only the remote config changed
+	Diverged                 // Both sides changed
+)
+
+func (r Relation) String() string {
+	switch r {
+	case InSync:
+		return "up to date"
+	case Ahead:
+		return "ahead"
+	case Behind:
+		return "behind"
+	case Diverged:
+		return "diverged"
+	}
+	return "unknown"
+}
+
+// Compare determines the relation between local and remote relative to base (the last synced config)
+func Compare(base, local, remote *config.Config) (Relation, error) {
+	baseHash, err := Hash(base)
+	if err != nil {
+		return InSync, err
+	}
+	localHash, err := Hash(local)
+	if err != nil {
+		return InSync, err
+	}
+	remoteHash, err := Hash(remote)
+	if err != nil {
+		return InSync, err
+	}
+
+	localChanged := localHash != baseHash
+	remoteChanged := remoteHash != baseHash
+
+	switch {
+	case localHash == remoteHash:
+		return InSync, nil
+	case localChanged && remoteChanged:
+		return Diverged, nil
+	case remoteChanged:
+		return Behind, nil
+	default:
+		return Ahead, nil
+	}
+}
+
+// Side selects which version wins a conflict
+type Side int
+
+const (
+	SideLocal Side = iota
+	SideRemote
+)
+
+// Conflict is an item changed differently on both sides since the last sync.
+// Values are rendered as YAML; an empty string means the item does not exist on that side.
+type Conflict struct {
+	Kind   string // "rule", "alias" or "setting"
+	Key    string // Rule name, alias key or setting name
+	Base   string
+	Local  string
+	Remote string
+}
+
+// String renders the conflict as a diff from local to remote
+func (c Conflict) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %q:\n", c.Kind, c.Key)
+	switch {
+	case c.Local == "":
+		b.WriteString("  deleted locally, changed remotely\n")
+	case c.Remote == "":
+		b.WriteString("  changed locally, deleted remotely\n")
+	}
+	b.WriteString("  --- local\n  +++ remote\n")
+	for _, line := range diffLines(c.Local, c.Remote) {
+		b.WriteString("  " + line + "\n")
+	}
+	return b.String()
+}
+
+// ConflictError is returned by Merge when conflicts remain unresolved
+type ConflictError struct {
+	Conflicts []Conflict
+}
+
+func (e *ConflictError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "sync conflict: %d item(s) changed both locally and remotely\n", len(e.Conflicts))
+	for _, c := range e.Conflicts {
+		b.WriteString("\n" + c.String())
+	}
+	b.WriteString("\nRun the command in a terminal to resolve conflicts interactively")
+	return b.String()
+}
+
+// Resolver decides which side wins a conflict. Returning an error aborts the merge.
+type Resolver func(c Conflict) (Side, error)
+
+// Merge performs a three-way merge of local and remote against base.
+// Rules are matched by name and aliases by key; changes made on only one side
+// are applied, and changes made on both sides are passed to resolve. If resolve
+// is nil, all conflicts are reported in a *ConflictError.
+// The result keeps the local sync settings.
+func Merge(base, local, remote *config.Config, resolve Resolver) (*config.Config, error) {
+	m := &merger{resolve: resolve}
+
+	result := &config.Config{Sync: local.Sync}
+	if err := m.mergeSettings(result, base, local, remote); err != nil {
+		return nil, err
+	}
+	if err := m.mergeAliases(result, base, local, remote); err != nil {
+		return nil, err
+	}
+	if err := m.mergeRules(result, base, local, remote); err != nil {
+		return nil, err
+	}
+
+	if len(m.conflicts) > 0 {
+		return nil, &ConflictError{Conflicts: m.conflicts}
+	}
+	return result, nil
+}
+
+type merger struct {
+	resolve   Resolver
+	conflicts []Conflict
+}
+
+// pick chooses between the rendered versions of an item. It returns the winning side,
+// or reports the conflict to the resolver (collecting it if there is none).
+func (m *merger) pick(kind, key, base, local, remote string) (Side, error) {
+	switch {
+	case local == remote, remote == base:
+		return SideLocal, nil
+	case local == base:
+		return SideRemote, nil
+	}
+
+	c := Conflict{Kind: kind, Key: key, Base: base, Local: local, Remote: remote}
+	if m.resolve == nil {
+		m.conflicts = append(m.conflicts, c)
+		return SideLocal, nil
+	}
+	return m.resolve(c)
+}
+
+// setting is a top-level scalar of config.Config taking part in the merge
+type setting struct {
+	name string
+	get  func(c *config.Config) any
+	set  func(dst, src *config.Config)
+}
+
+var settings = []setting{
+	{
+		name: "version",
+		get:  func(c *config.Config) any { return c.Version },
+		set:  func(dst, src *config.Config) { dst.Version = src.Version },
+	},
+	{
+		name: "extends",
+		get:  func(c *config.Config) any { return c.Extends },
+		set:  func(dst, src *config.Config) { dst.Extends = src.Extends },
+	},
+	{
+		name: "include",
+		get:  func(c *config.Config) any { return c.Include },
+		set:  func(dst, src *config.Config) { dst.Include = src.Include },
+	},
+	{
+		name: "default_command",
+		get:  func(c *config.Config) any { return c.DefaultCommand },
+		set:  func(dst, src *config.Config) { dst.DefaultCommand = src.DefaultCommand },
+	},
+	{
+		name: "default",
+		get:  func(c *config.Config) any { return c.Default },
+		set:  func(dst, src *config.Config) { dst.Default = src.Default },
+	},
+	{
+		name: "history",
+		get:  func(c *config.Config) any { return c.History },
+		set:  func(dst, src *config.Config) { dst.History = src.History },
+	},
+	{
+		name: "profiles",
+		get:  func(c *config.Config) any { return c.Profiles },
+		set:  func(dst, src *config.Config) { dst.Profiles = src.Profiles },
+	},
+}
+
+func (m *merger) mergeSettings(result, base, local, remote *config.Config) error {
+	for _, s := range settings {
+		side, err := m.pick("setting", s.name, render(s.get(base)), render(s.get(local)), render(s.get(remote)))
+		if err != nil {
+			return err
+		}
+		if side == SideRemote {
+			s.set(result, remote)
+		} else {
+			s.set(result, local)
+		}
+	}
+	return nil
+}
+
+func (m *merger) mergeAliases(result, base, local, remote *config.Config) error {
+	for _, k := range aliasKeys(base, local, remote) {
+		side, err := m.pick("alias", k, renderAlias(base.Aliases, k), renderAlias(local.Aliases, k), renderAlias(remote.Aliases, k))
+		if err != nil {
+			return err
+		}
+		from := local.Aliases
+		if side == SideRemote {
+			from = remote.Aliases
+		}
+		if v, ok := from[k]; ok {
+			if result.Aliases == nil {
+				result.Aliases = map[string]string{}
+			}
+			result.Aliases[k] = v
+		}
+	}
+	return nil
+}
+
+func (m *merger) mergeRules(result, base, local, remote *config.Config) error {
+	baseKeys, baseRules := keyRules(base.Rules)
+	localKeys, localRules := keyRules(local.Rules)
+	remoteKeys, remoteRules := keyRules(remote.Rules)
+
+	// Keep the local order unless only the remote side reordered rules
+	order, other := localKeys, remoteKeys
+	if !orderChanged(baseKeys, localKeys) && orderChanged(baseKeys, remoteKeys) {
+		order, other = remoteKeys, localKeys
+	}
+	order = insertMissing(slices.Clone(order), other)
+
+	for _, k := range order {
+		side, err := m.pick("rule", k, renderRule(baseRules[k]), renderRule(localRules[k]), renderRule(remoteRules[k]))
+		if err != nil {
+			return err
+		}
+		rule := localRules[k]
+		if side == SideRemote {
+			rule = remoteRules[k]
+		}
+		if rule != nil {
+			result.Rules = append(result.Rules, *rule)
+		}
+	}
+	return nil
+}
+
+// aliasKeys returns the sorted union of the alias keys of all configs
+func aliasKeys(configs ...*config.Config) []string {
+	var keys []string
+	for _, cfg := range configs {
+		for k := range cfg.Aliases {
+			if !slices.Contains(keys, k) {
+				keys = append(keys, k)
+			}
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// keyRules indexes rules by name. Unnamed rules are keyed by command and
+// duplicates get a "#n" suffix so every rule has a stable, unique key.
+func keyRules(rules []config.Rule) ([]string, map[string]*config.Rule) {
+	keys := make([]string, 0, len(rules))
+	byKey := make(map[string]*config.Rule, len(rules))
+	for i := range rules {
+		key := rules[i].Name
+		if key == "" {
+			key = rules[i].Command
+		}
+		unique := key
+		for n := 2; byKey[unique] != nil; n++ {
+			unique = fmt.Sprintf("%s#%d", key, n)
+		}
+		keys = append(keys, unique)
+		byKey[unique] = &rules[i]
+	}
+	return keys, byKey
+}
+
+// orderChanged reports whether the keys shared with base appear in a different order
+func orderChanged(base, keys []string) bool {
+	common := intersect(keys, base)
+	return !slices.Equal(intersect(base, keys), common)
+}
+
+// intersect returns the keys of a that also exist in b, in the order of a
+func intersect(a, b []string) []string {
+	var out []string
+	for _, k := range a {
+		if slices.Contains(b, k) {
+			out = append(out, k)
+		}
+	}
+	return out
+}
+
+// insertMissing adds the keys of other not yet in order, each after its predecessor in other
+func insertMissing(order, other []string) []string {
+	for i, k := range other {
+		if slices.Contains(order, k) {
+			continue
+		}
+		pos := 0
+		for j := i - 1; j >= 0; j-- {
+			if idx := slices.Index(order, other[j]); idx >= 0 {
+				pos = idx + 1
+				break
+			}
+		}
+		order = slices.Insert(order, pos, k)
+	}
+	return order
+}
+
+func renderRule(rule *config.Rule) string {
+	if rule == nil {
+		return ""
+	}
+	return render(rule)
+}
+
+func renderAlias(aliases map[string]string, key string) string {
+	v, ok := aliases[key]
+	if !ok {
+		return ""
+	}
+	return render(v)
+}
+
+// render marshals a value to YAML for comparison and display
+func render(v any) string {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(data)
+}
@@ -0,0 +1,223 @@
+package sync_test
+
+import (
+	"errors"
+	"path/filepath"
+	"time"
+
+	"github.com/SuzumiyaAoba/via/internal/config"
+	"github.com/SuzumiyaAoba/via/internal/sync"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func rule(name, command string) config.Rule {
+	return config.Rule{Name: name, Command: command}
+}
+
+func ruleNames(cfg *config.Config) []string {
+	var names []string
+	for _, r := range cfg.Rules {
+		names = append(names, r.Name)
+	}
+	return names
+}
+
+var _ = Describe("Merge", func() {
+	var base *config.Config
+
+	BeforeEach(func() {
+		base = &config.Config{
+			Version: "1",
+			Rules:   []config.Rule{rule("A", "a"), rule("B", "b"), rule("C", "c")},
+			Aliases: map[string]string{"x": "A"},
+		}
+	})
+
+	clone := func(cfg *config.Config) *config.Config {
+		c := *cfg
+		c.Rules = append([]config.Rule(nil), cfg.Rules...)
+		c.Aliases = map[string]string{}
+		for k, v := range cfg.Aliases {
+			c.Aliases[k] = v
+		}
+		return &c
+	}
+
+	Describe("Compare", func() {
+		It("should detect each relation", func() {
+			changed := clone(base)
+			changed.DefaultCommand = "vim"
+			other := clone(base)
+			other.DefaultCommand = "less"
+
+			Expect(sync.Compare(base, clone(base), clone(base))).To(Equal(sync.InSync))
+			Expect(sync.Compare(base, changed, base)).To(Equal(sync.Ahead))
+			Expect(sync.Compare(base, base, changed)).To(Equal(sync.Behind))
+			Expect(sync.Compare(base, changed, other)).To(Equal(sync.Diverged))
+			Expect(sync.Compare(base, changed, changed)).To(Equal(sync.InSync))
+		})
+
+		It("should ignore sync settings", func() {
+			local := clone(base)
+			local.Sync = &config.SyncConfig{GistID: "123"}
+			Expect(sync.Compare(base, local, base)).To(Equal(sync.InSync))
+		})
+	})
+
+	It("should combine non-overlapping changes", func() {
+		local := clone(base)
+		local.Rules[0].Command = "a2"
+		local.Rules = append(local.Rules, rule("D", "d"))
+
+		remote := clone(base)
+		remote.Rules = append(remote.Rules[:1], remote.Rules[2:]...) // delete B
+		remote.Aliases["y"] = "C"
+		remote.DefaultCommand = "vim {{.File}}"
+
+		merged, err := sync.Merge(base, local, remote, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ruleNames(merged)).To(Equal([]string{"A", "C", "D"}))
+		Expect(merged.Rules[0].Command).To(Equal("a2"))
+		Expect(merged.Aliases).To(Equal(map[string]string{"x": "A", "y": "C"}))
+		Expect(merged.DefaultCommand).To(Equal("vim {{.File}}"))
+	})
+
+	It("should insert remote rules after their predecessor", func() {
+		local := clone(base)
+		local.Rules = append(local.Rules, rule("D", "d"))
+
+		remote := clone(base)
+		remote.Rules = []config.Rule{rule("A", "a"), rule("E", "e"), rule("B", "b"), rule("C", "c")}
+
+		merged, err := sync.Merge(base, local, remote, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ruleNames(merged)).To(Equal([]string{"A", "E", "B", "C", "D"}))
+	})
+
+	It("should keep a remote reorder when local order is unchanged", func() {
+		local := clone(base)
+		local.Rules[1].Command = "b2"
+
+		remote := clone(base)
+		remote.Rules = []config.Rule{rule("C", "c"), rule("A", "a"), rule("B", "b")}
+
+		merged, err := sync.Merge(base, local, remote, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ruleNames(merged)).To(Equal([]string{"C", "A", "B"}))
+		Expect(merged.Rules[2].Command).To(Equal("b2"))
+	})
+
+	It("should keep the local sync settings", func() {
+		local := clone(base)
+		local.Sync = &config.SyncConfig{Backend: "dir", Path: "/mnt/shared"}
+		remote := clone(base)
+		remote.Rules[0].Command = "a2"
+
+		merged, err := sync.Merge(base, local, remote, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(merged.Sync).To(Equal(local.Sync))
+	})
+
+	Context("with conflicts", func() {
+		var local, remote *config.Config
+
+		BeforeEach(func() {
+			local = clone(base)
+			local.Rules[0].Command = "local-a"
+			remote = clone(base)
+			remote.Rules[0].Command = "remote-a"
+			remote.Rules = remote.Rules[:2] // delete C
+			local.Rules[2].Command = "local-c"
+		})
+
+		It("should report all conflicts with a diff without a resolver", func() {
+			_, err := sync.Merge(base, local, remote, nil)
+
+			var conflictErr *sync.ConflictError
+			Expect(errors.As(err, &conflictErr)).To(BeTrue())
+			Expect(conflictErr.Conflicts).To(HaveLen(2))
+			Expect(conflictErr.Conflicts[0].Key).To(Equal("A"))
+			Expect(err.Error()).To(ContainSubstring("- command: local-a"))
+			Expect(err.Error()).To(ContainSubstring("+ command: remote-a"))
+			Expect(err.Error()).To(ContainSubstring("changed locally, deleted remotely"))
+		})
+
+		It("should apply the resolver decisions", func() {
+			var seen []string
+			merged, err := sync.Merge(base, local, remote, func(c sync.Conflict) (sync.Side, error) {
+				seen = append(seen, c.Key)
+				return sync.SideRemote, nil
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(seen).To(Equal([]string{"A", "C"}))
+			Expect(ruleNames(merged)).To(Equal([]string{"A", "B"}))
+			Expect(merged.Rules[0].Command).To(Equal("remote-a"))
+		})
+
+		It("should abort when the resolver fails", func() {
+			_, err := sync.Merge(base, local, remote, func(c sync.Conflict) (sync.Side, error) {
+				return sync.SideLocal, errors.New("aborted")
+			})
+			Expect(err).To(MatchError("aborted"))
+		})
+	})
+})
+
+var _ = Describe("State", func() {
+	It("should round-trip the synced config", func() {
+		configPath := filepath.Join(GinkgoT().TempDir(), "config.yml")
+
+		state, err := sync.LoadState(configPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(state).To(BeNil())
+
+		cfg := &config.Config{
+			Version: "1",
+			Rules:   []config.Rule{rule("A", "a")},
+			Sync:    &config.SyncConfig{GistID: "123", Token: "secret"},
+		}
+		state, err = sync.NewState(cfg, "rev1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(state.Base).NotTo(ContainSubstring("secret"))
+		Expect(sync.SaveState(configPath, state)).To(Succeed())
+
+		loaded, err := sync.LoadState(configPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(loaded.Revision).To(Equal("rev1"))
+
+		base, err := loaded.BaseConfig()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ruleNames(base)).To(Equal([]string{"A"}))
+		Expect(sync.Compare(base, cfg, cfg)).To(Equal(sync.InSync))
+	})
+})
+
+var _ = Describe("AutoState", func() {
+	It("should rate limit remote checks", func() {
+		configPath := filepath.Join(GinkgoT().TempDir(), "config.yml")
+
+		state, err := sync.LoadAutoState(configPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(state.Due(sync.DefaultAutoInterval, time.Now())).To(BeTrue())
+
+		checkedAt := time.Now()
+		state.CheckedAt = checkedAt
+		state.Revision = "rev1"
+		Expect(sync.SaveAutoState(configPath, state)).To(Succeed())
+
+		loaded, err := sync.LoadAutoState(configPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(loaded.Revision).To(Equal("rev1"))
+
+		interval := sync.AutoInterval(&config.SyncConfig{Interval: 5})
+		Expect(interval).To(Equal(5 * time.Minute))
+		Expect(loaded.Due(interval, checkedAt.Add(time.Minute))).To(BeFalse())
+		Expect(loaded.Due(interval, checkedAt.Add(5*time.Minute))).To(BeTrue())
+	})
+
+	It("should validate the automatic sync modes", func() {
+		Expect(sync.ValidateAuto(&config.SyncConfig{Auto: "pull-on-start,push-on-change"})).To(Succeed())
+		Expect(sync.ValidateAuto(&config.SyncConfig{Auto: "pull"})).To(MatchError(ContainSubstring("unknown automatic sync mode")))
+	})
+})
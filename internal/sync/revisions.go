@@ -0,0 +1,54 @@
+package sync
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/SuzumiyaAoba/via/internal/config"
+)
+
+// Revision is a past version of the remote config
+type Revision struct {
+	ID        string    // Commit or gist version
+	CreatedAt time.Time // When the revision was pushed
+	Message   string    // Commit message, empty if the backend has none
+}
+
+// ShortID returns an abbreviated revision id for display
+func (r Revision) ShortID() string {
+	if len(r.ID) > 8 {
+		return r.ID[:8]
+	}
+	return r.ID
+}
+
+// HistoryBackend is a backend that keeps past revisions of the config
+type HistoryBackend interface {
+	// Revisions lists the revisions of the config, newest first
+	Revisions() ([]Revision, error)
+	// PullRevision fetches the config as of the given revision. Unique prefixes of a revision id are accepted.
+	PullRevision(id string) (*config.Config, error)
+}
+
+// findRevision resolves a revision id or a unique prefix of one
+func findRevision(revisions []Revision, id string) (Revision, error) {
+	var found []Revision
+	for _, r := range revisions {
+		if r.ID == id {
+			return r, nil
+		}
+		if strings.HasPrefix(r.ID, id) {
+			found = append(found, r)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return Revision{}, fmt.Errorf("revision %s not found", id)
+	case 1:
+		return found[0], nil
+	default:
+		return Revision{}, fmt.Errorf("revision %s is ambiguous", id)
+	}
+}
@@ -0,0 +1,167 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/SuzumiyaAoba/via/internal/config"
+)
+
+// MaxSnapshots is the number of snapshots kept per config file
+const MaxSnapshots = 10
+
+// Snapshot is a copy of the local config taken before a pull, so the pull can be undone
+type Snapshot struct {
+	TakenAt time.Time         `json:"taken_at"`
+	Reason  string            `json:"reason"`          // What was about to change the config, e.g. "pull"
+	Config  string            `json:"config"`          // Config file content
+	State   *State            `json:"state,omitempty"` // Sync state, nil if the config had never been synced
+	Files   map[string][]byte `json:"files,omitempty"` // Previous content of the synced files about to change, nil if they did not exist
+
+	path string
+}
+
+// SnapshotDir returns the directory holding the snapshots of the given config file
+func SnapshotDir(configPath string) string {
+	name := strings.TrimSuffix(filepath.Base(configPath), filepath.Ext(configPath))
+	return filepath.Join(StateDir(configPath), "snapshots", name)
+}
+
+// TakeSnapshot saves the current config, its sync state and the synced files
+// listed in changed, so they can be restored with Restore. Only the newest
+// MaxSnapshots snapshots are kept.
+func TakeSnapshot(configPath, reason string, local *LocalFiles, changed []string) error {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to read config for snapshot: %w", err)
+	}
+
+	state, err := LoadState(configPath)
+	if err != nil {
+		return err
+	}
+
+	snapshot := &Snapshot{
+		TakenAt: time.Now(),
+		Reason:  reason,
+		Config:  string(data),
+		State:   state,
+	}
+	for _, key := range changed {
+		p, err := local.localPath(key)
+		if err != nil {
+			return err
+		}
+		content, err := os.ReadFile(p)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read %s for snapshot: %w", key, err)
+		}
+		if snapshot.Files == nil {
+			snapshot.Files = map[string][]byte{}
+		}
+		snapshot.Files[key] = content
+	}
+
+	encoded, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := SnapshotDir(configPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	name := snapshot.TakenAt.UTC().Format("20060102T150405.000000000") + ".json"
+	if err := os.WriteFile(filepath.Join(dir, name), encoded, 0644); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	return pruneSnapshots(dir)
+}
+
+// LatestSnapshot returns the newest snapshot of a config file, or nil if there is none
+func LatestSnapshot(configPath string) (*Snapshot, error) {
+	dir := SnapshotDir(configPath)
+	names, err := snapshotNames(dir)
+	if err != nil || len(names) == 0 {
+		return nil, err
+	}
+
+	path := filepath.Join(dir, names[len(names)-1])
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot: %w", err)
+	}
+	snapshot.path = path
+	return &snapshot, nil
+}
+
+// Restore writes the snapshot back and removes it, so the next undo goes one step further back
+func (s *Snapshot) Restore(configPath string, local *LocalFiles) error {
+	if err := config.WriteConfigFile(configPath, []byte(s.Config)); err != nil {
+		return fmt.Errorf("failed to restore config: %w", err)
+	}
+
+	if s.State != nil {
+		if err := SaveState(configPath, s.State); err != nil {
+			return err
+		}
+	} else if err := os.Remove(StatePath(configPath)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to reset sync state: %w", err)
+	}
+
+	if err := local.Apply(s.Files); err != nil {
+		return err
+	}
+
+	if s.path != "" {
+		if err := os.Remove(s.path); err != nil {
+			return fmt.Errorf("failed to remove snapshot: %w", err)
+		}
+	}
+	return nil
+}
+
+// snapshotNames lists the snapshot files in dir, oldest first
+func snapshotNames(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && filepath.Ext(e.Name()) == ".json" {
+			names = append(names, e.Name())
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+func pruneSnapshots(dir string) error {
+	names, err := snapshotNames(dir)
+	if err != nil {
+		return err
+	}
+	for len(names) > MaxSnapshots {
+		if err := os.Remove(filepath.Join(dir, names[0])); err != nil {
+			return fmt.Errorf("failed to remove old snapshot: %w", err)
+		}
+		names = names[1:]
+	}
+	return nil
+}
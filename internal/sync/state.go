@@ -0,0 +1,104 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/SuzumiyaAoba/via/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// State records the outcome of the last successful sync of a config file
+type State struct {
+	Revision string            `json:"revision,omitempty"` // Backend revision after the last sync
+	Hash     string            `json:"hash"`               // Hash of the synced config
+	Base     string            `json:"base"`               // Synced config, used as merge base for the next sync
+	Files    map[string]string `json:"files,omitempty"`    // Hashes of the synced extra files
+	SyncedAt time.Time         `json:"synced_at"`
+}
+
+// StateDir returns the directory holding sync state for the given config file
+func StateDir(configPath string) string {
+	return filepath.Join(filepath.Dir(configPath), "sync")
+}
+
+// StatePath returns the state file for the given config file.
+// Profiles live next to each other, so the file is named after the config.
+func StatePath(configPath string) string {
+	name := strings.TrimSuffix(filepath.Base(configPath), filepath.Ext(configPath))
+	return filepath.Join(StateDir(configPath), name+".state.json")
+}
+
+// LoadState reads the sync state for a config file. It returns nil if the config has never been synced.
+func LoadState(configPath string) (*State, error) {
+	data, err := os.ReadFile(StatePath(configPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read sync state: %w", err)
+	}
+
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse sync state: %w", err)
+	}
+	return &state, nil
+}
+
+// SaveState writes the sync state for a config file
+func SaveState(configPath string, state *State) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(StateDir(configPath), 0755); err != nil {
+		return fmt.Errorf("failed to create sync state directory: %w", err)
+	}
+	return os.WriteFile(StatePath(configPath), data, 0644)
+}
+
+// NewState records cfg as the synced config at the given backend revision
+func NewState(cfg *config.Config, revision string) (*State, error) {
+	data, err := canonicalYAML(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &State{
+		Revision: revision,
+		Hash:     contentHash(data),
+		Base:     string(data),
+		SyncedAt: time.Now(),
+	}, nil
+}
+
+// BaseConfig returns the config recorded at the last sync
+func (s *State) BaseConfig() (*config.Config, error) {
+	return parseConfig([]byte(s.Base), "sync state")
+}
+
+// Hash returns a hash of the synced parts of cfg. Sync settings are machine
+// specific and excluded, so two machines with the same rules hash equally.
+func Hash(cfg *config.Config) (string, error) {
+	data, err := canonicalYAML(cfg)
+	if err != nil {
+		return "", err
+	}
+	return contentHash(data), nil
+}
+
+// canonicalYAML marshals cfg without its sync settings
+func canonicalYAML(cfg *config.Config) ([]byte, error) {
+	stripped := *cfg
+	stripped.Sync = nil
+	data, err := yaml.Marshal(&stripped)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	return data, nil
+}
@@ -2,6 +2,7 @@ package sync
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/SuzumiyaAoba/via/internal/config"
@@ -21,6 +22,19 @@ type Gist struct {
 	Public      bool                `json:"public"`
 }
 
+// GistRevision is an entry in a gist's revision history
+type GistRevision struct {
+	Version     string    `json:"version"`
+	CommittedAt time.Time `json:"committed_at"`
+}
+
+// gistResponse is a gist as returned by the API, including metadata
+type gistResponse struct {
+	Gist
+	UpdatedAt time.Time      `json:"updated_at"`
+	History   []GistRevision `json:"history"`
+}
+
 type Client struct {
 	Token  string
 	client *resty.Client
@@ -42,8 +56,8 @@ func NewClient(token string) *Client {
 	}
 }
 
-func (c *Client) GetGist(gistID string) (*config.Config, error) {
-	var gist Gist
+func (c *Client) fetchGist(gistID string) (*gistResponse, error) {
+	var gist gistResponse
 	resp, err := c.client.R().
 		SetResult(&gist).
 		Get("/gists/" + gistID)
@@ -56,17 +70,122 @@ func (c *Client) GetGist(gistID string) (*config.Config, error) {
 		return nil, fmt.Errorf("failed to get gist: %s", resp.Status())
 	}
 
-	file, ok := gist.Files["config.yml"]
+	return &gist, nil
+}
+
+func (c *Client) GetGist(gistID string) (*config.Config, error) {
+	gist, err := c.fetchGist(gistID)
+	if err != nil {
+		return nil, err
+	}
+
+	file, ok := gist.Files[ConfigFileName]
 	if !ok {
-		return nil, fmt.Errorf("config.yml not found in gist")
+		return nil, fmt.Errorf("%w in gist", ErrRemoteNotFound)
+	}
+
+	return parseConfig([]byte(file.Content), "gist")
+}
+
+// gistFileName maps a synced file to a gist file name. Gists have no directories,
+// so "profiles/work.yml" is stored as "profiles__work.yml".
+func gistFileName(key string) string {
+	return strings.ReplaceAll(key, "/", gistPathSeparator)
+}
+
+func gistFileKey(name string) string {
+	return strings.ReplaceAll(name, gistPathSeparator, "/")
+}
+
+const gistPathSeparator = "__"
+
+// GetGistFiles returns all files of a gist except the config, keyed by their synced path
+func (c *Client) GetGistFiles(gistID string) (map[string][]byte, error) {
+	gist, err := c.fetchGist(gistID)
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string][]byte{}
+	for name, file := range gist.Files {
+		if name == ConfigFileName {
+			continue
+		}
+		files[gistFileKey(name)] = []byte(file.Content)
+	}
+	return files, nil
+}
+
+// UpdateGistFiles writes the given files to a gist. A nil content deletes the file.
+func (c *Client) UpdateGistFiles(gistID string, files map[string][]byte) error {
+	// A null file deletes it from the gist
+	body := map[string]map[string]*GistFile{"files": {}}
+	for key, data := range files {
+		if data == nil {
+			body["files"][gistFileName(key)] = nil
+		} else {
+			body["files"][gistFileName(key)] = &GistFile{Content: string(data)}
+		}
+	}
+
+	resp, err := c.client.R().
+		SetBody(body).
+		Patch("/gists/" + gistID)
+
+	if err != nil {
+		return err
+	}
+
+	if resp.IsError() {
+		return fmt.Errorf("failed to update gist files: %s - %s", resp.Status(), resp.String())
+	}
+
+	return nil
+}
+
+// GetGistStatus returns the latest revision of a gist and when it was updated
+func (c *Client) GetGistStatus(gistID string) (*Status, error) {
+	gist, err := c.fetchGist(gistID)
+	if err != nil {
+		return nil, err
 	}
 
-	var cfg config.Config
-	if err := yaml.Unmarshal([]byte(file.Content), &cfg); err != nil {
-		return nil, fmt.Errorf("failed to parse config from gist: %w", err)
+	_, exists := gist.Files[ConfigFileName]
+	status := &Status{
+		Backend:   BackendGist,
+		Location:  gistID,
+		Exists:    exists,
+		UpdatedAt: gist.UpdatedAt,
+	}
+	if len(gist.History) > 0 {
+		status.Revision = gist.History[0].Version
+	}
+
+	return status, nil
+}
+
+// GetGistRevisions returns the revision history of a gist, newest first
+func (c *Client) GetGistRevisions(gistID string) ([]GistRevision, error) {
+	gist, err := c.fetchGist(gistID)
+	if err != nil {
+		return nil, err
+	}
+	return gist.History, nil
+}
+
+// GetGistRevision returns the config stored in a gist at the given version
+func (c *Client) GetGistRevision(gistID, version string) (*config.Config, error) {
+	gist, err := c.fetchGist(gistID + "/" + version)
+	if err != nil {
+		return nil, err
+	}
+
+	file, ok := gist.Files[ConfigFileName]
+	if !ok {
+		return nil, fmt.Errorf("%w in gist revision %s", ErrRemoteNotFound, version)
 	}
 
-	return &cfg, nil
+	return parseConfig([]byte(file.Content), "gist")
 }
 
 func (c *Client) UpdateGist(gistID string, cfg *config.Config) error {
@@ -77,7 +196,7 @@ func (c *Client) UpdateGist(gistID string, cfg *config.Config) error {
 
 	gist := Gist{
 		Files: map[string]GistFile{
-			"config.yml": {Content: string(data)},
+			ConfigFileName: {Content: string(data)},
 		},
 		Description: "Entry Configuration (Updated " + time.Now().Format(time.RFC3339) + ")",
 	}
@@ -105,7 +224,7 @@ func (c *Client) CreateGist(cfg *config.Config, public bool) (string, error) {
 
 	gist := Gist{
 		Files: map[string]GistFile{
-			"config.yml": {Content: string(data)},
+			ConfigFileName: {Content: string(data)},
 		},
 		Description: "Entry Configuration",
 		Public:      public,
@@ -1,8 +1,10 @@
 package sync_test
 
 import (
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"testing"
 
 	"github.com/SuzumiyaAoba/via/internal/config"
@@ -61,7 +63,7 @@ var _ = Describe("Client", func() {
 	It("should get gist", func() {
 		cfg, err := client.GetGist("gist123")
 		Expect(err).NotTo(HaveOccurred())
-		Expect(cfg.Version).To(Equal("1"))
+		Expect(cfg.Version).To(Equal(config.CurrentVersion))
 	})
 
 	It("should update gist", func() {
@@ -77,6 +79,32 @@ var _ = Describe("Client", func() {
 		Expect(id).To(Equal("newgist123"))
 	})
 
+	It("should never upload the sync token", func() {
+		var bodies []string
+		server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			data, _ := io.ReadAll(r.Body)
+			bodies = append(bodies, string(data))
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"id": "newgist123"}`))
+		})
+
+		cfg := &config.Config{
+			Version: "1",
+			Sync:    &config.SyncConfig{GistID: "gist123", Token: "secret-token"},
+		}
+		Expect(client.UpdateGist("gist123", cfg)).To(Succeed())
+		_, err := client.CreateGist(cfg, false)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(bodies).To(HaveLen(2))
+		for _, body := range bodies {
+			Expect(body).To(ContainSubstring("gist_id"))
+			Expect(body).NotTo(ContainSubstring("secret-token"))
+		}
+		Expect(cfg.Sync.Token).To(Equal("secret-token"))
+	})
+
 	It("should return error on 404", func() {
 		_, err := client.GetGist("unknown")
 		Expect(err).To(HaveOccurred())
@@ -138,3 +166,38 @@ var _ = Describe("Client", func() {
 		Expect(err.Error()).To(ContainSubstring("failed to create gist"))
 	})
 })
+
+var _ = Describe("ResolveToken", func() {
+	AfterEach(func() {
+		os.Unsetenv(sync.TokenEnvVar)
+	})
+
+	It("should prefer token_command", func() {
+		token, err := sync.ResolveToken(&config.SyncConfig{
+			Token:        "stored",
+			TokenCommand: "printf 'from-command\\nlogin: alice\\n'",
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(token).To(Equal("from-command"))
+	})
+
+	It("should fall back to the stored token and the environment", func() {
+		os.Setenv(sync.TokenEnvVar, "from-env")
+
+		token, err := sync.ResolveToken(&config.SyncConfig{Token: "stored"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(token).To(Equal("stored"))
+
+		token, err = sync.ResolveToken(&config.SyncConfig{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(token).To(Equal("from-env"))
+	})
+
+	It("should fail when token_command fails", func() {
+		_, err := sync.ResolveToken(&config.SyncConfig{TokenCommand: "echo locked >&2; exit 1"})
+		Expect(err).To(MatchError(ContainSubstring("token_command failed: locked")))
+
+		_, err = sync.ResolveToken(&config.SyncConfig{TokenCommand: "true"})
+		Expect(err).To(MatchError(ContainSubstring("printed no token")))
+	})
+})
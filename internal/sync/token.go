@@ -0,0 +1,43 @@
+package sync
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/SuzumiyaAoba/via/internal/config"
+)
+
+// TokenEnvVar provides the sync token when none is configured
+const TokenEnvVar = "ENTRY_GITHUB_TOKEN"
+
+// ResolveToken returns the token for the sync settings. token_command takes
+// precedence, followed by the token from the credentials file and TokenEnvVar.
+func ResolveToken(cfg *config.SyncConfig) (string, error) {
+	if cfg.TokenCommand != "" {
+		cmd := exec.Command("sh", "-c", cfg.TokenCommand)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return "", fmt.Errorf("token_command failed: %s", msg)
+			}
+			return "", fmt.Errorf("token_command failed: %w", err)
+		}
+		// Password managers print the secret on the first line, possibly followed by metadata
+		token, _, _ := strings.Cut(string(out), "\n")
+		token = strings.TrimSpace(token)
+		if token == "" {
+			return "", fmt.Errorf("token_command printed no token")
+		}
+		return token, nil
+	}
+
+	if cfg.Token != "" {
+		return cfg.Token, nil
+	}
+	return os.Getenv(TokenEnvVar), nil
+}
@@ -0,0 +1,166 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/SuzumiyaAoba/via/internal/config"
+	"github.com/go-resty/resty/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// WebDAVBackend syncs the configuration with a file on a WebDAV server
+type WebDAVBackend struct {
+	URL    string
+	client *resty.Client
+}
+
+// NewWebDAVBackend creates a WebDAV backend. If url ends with "/", it is treated
+// as a collection and config.yml is stored inside it. With a username, the token is
+// sent as the basic auth password; otherwise it is sent as a bearer token.
+func NewWebDAVBackend(url, username, token string) *WebDAVBackend {
+	if strings.HasSuffix(url, "/") {
+		url += ConfigFileName
+	}
+
+	client := resty.New().SetTimeout(10 * time.Second)
+	if username != "" {
+		client.SetBasicAuth(username, token)
+	} else if token != "" {
+		client.SetAuthToken(token)
+	}
+
+	return &WebDAVBackend{
+		URL:    url,
+		client: client,
+	}
+}
+
+func (b *WebDAVBackend) Name() string { return "WebDAV" }
+
+func (b *WebDAVBackend) Pull() (*config.Config, error) {
+	resp, err := b.client.R().Get(b.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode() == http.StatusNotFound {
+		return nil, fmt.Errorf("%w at %s", ErrRemoteNotFound, b.URL)
+	}
+	if resp.IsError() {
+		return nil, fmt.Errorf("failed to get config: %s", resp.Status())
+	}
+
+	return parseConfig(resp.Body(), "WebDAV")
+}
+
+func (b *WebDAVBackend) Push(cfg *config.Config) error {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+
+	resp, err := b.client.R().
+		SetHeader("Content-Type", "application/yaml").
+		SetBody(data).
+		Put(b.URL)
+	if err != nil {
+		return err
+	}
+
+	if resp.IsError() {
+		return fmt.Errorf("failed to upload config: %s - %s", resp.Status(), resp.String())
+	}
+	return nil
+}
+
+// filesURL is the bundle holding the extra files. WebDAV servers differ in how they
+// list and create collections, so the files are stored together next to the config.
+func (b *WebDAVBackend) filesURL() string {
+	return b.URL[:strings.LastIndex(b.URL, "/")+1] + FilesBundleName
+}
+
+func (b *WebDAVBackend) PullFiles() (map[string][]byte, error) {
+	files := map[string][]byte{}
+	resp, err := b.client.R().Get(b.filesURL())
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode() == http.StatusNotFound {
+		return files, nil
+	}
+	if resp.IsError() {
+		return nil, fmt.Errorf("failed to get files: %s", resp.Status())
+	}
+
+	if err := json.Unmarshal(resp.Body(), &files); err != nil {
+		return nil, fmt.Errorf("failed to parse files from WebDAV: %w", err)
+	}
+	return files, nil
+}
+
+func (b *WebDAVBackend) PushFiles(files map[string][]byte) error {
+	if len(files) == 0 {
+		return nil
+	}
+
+	bundle, err := b.PullFiles()
+	if err != nil {
+		return err
+	}
+	for key, data := range files {
+		if data == nil {
+			delete(bundle, key)
+		} else {
+			bundle[key] = data
+		}
+	}
+
+	data, err := json.Marshal(bundle)
+	if err != nil {
+		return err
+	}
+
+	resp, err := b.client.R().
+		SetHeader("Content-Type", "application/json").
+		SetBody(data).
+		Put(b.filesURL())
+	if err != nil {
+		return err
+	}
+
+	if resp.IsError() {
+		return fmt.Errorf("failed to upload files: %s - %s", resp.Status(), resp.String())
+	}
+	return nil
+}
+
+func (b *WebDAVBackend) Status() (*Status, error) {
+	resp, err := b.client.R().Head(b.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &Status{
+		Backend:  BackendWebDAV,
+		Location: b.URL,
+	}
+
+	if resp.StatusCode() == http.StatusNotFound {
+		return status, nil
+	}
+	if resp.IsError() {
+		return nil, fmt.Errorf("failed to get config status: %s", resp.Status())
+	}
+
+	status.Exists = true
+	status.Revision = strings.Trim(resp.Header().Get("ETag"), `"`)
+	if lastModified := resp.Header().Get("Last-Modified"); lastModified != "" {
+		status.UpdatedAt, _ = http.ParseTime(lastModified)
+	}
+	return status, nil
+}
@@ -129,15 +129,16 @@ type keyMap struct {
 	Quit     key.Binding
 	Up       key.Binding
 	Down     key.Binding
+	Profile  key.Binding
 }
 
 func (k keyMap) ShortHelp() []key.Binding {
-	return []key.Binding{k.Tab, k.Add, k.Edit, k.Delete, k.Enter, k.Quit}
+	return []key.Binding{k.Tab, k.Add, k.Edit, k.Delete, k.Enter, k.Profile, k.Quit}
 }
 
 func (k keyMap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
-		{k.Tab, k.ShiftTab, k.Quit},
+		{k.Tab, k.ShiftTab, k.Profile, k.Quit},
 		{k.Up, k.Down, k.Add, k.Edit, k.Delete},
 		{k.MoveUp, k.MoveDown, k.Enter},
 	}
@@ -188,11 +189,16 @@ var keys = keyMap{
 		key.WithKeys("enter"),
 		key.WithHelp("enter", "show details"),
 	),
+	Profile: key.NewBinding(
+		key.WithKeys("p"),
+		key.WithHelp("p", "switch profile"),
+	),
 }
 
 type Model struct {
 	Cfg         *config.Config
 	ConfigPath  string
+	Loaded      config.Fingerprint // Content of the file Cfg was read from, saving fails if it changed since
 	History     []history.HistoryEntry
 	Active      Tab
 	Width       int
@@ -211,6 +217,11 @@ type Model struct {
 	// Detail State
 	ShowDetail bool
 	DetailRule config.Rule
+
+	// Profile State
+	Profile  string   // Active profile, "default" for the main config
+	Profiles []string // Profiles to cycle through, including "default"
+	Status   string   // Message shown above the help, e.g. a failed profile switch
 }
 
 func NewModel(cfg *config.Config, configPath string) (Model, error) {
@@ -244,9 +255,64 @@ func NewModel(cfg *config.Config, configPath string) (Model, error) {
 		RulesList:   rulesList,
 		HistoryList: historyList,
 		Help:        help.New(),
+		Profile:     config.DefaultProfile,
 	}, nil
 }
 
+// save writes the config, showing why in the status line if it could not, e.g.
+// because another vv process changed the file in the meantime
+func (m *Model) save() {
+	saved, err := config.SaveConfigFingerprint(m.ConfigPath, m.Cfg, m.Loaded)
+	if err != nil {
+		m.Status = fmt.Sprintf("Failed to save: %v", err)
+		return
+	}
+	m.Loaded = saved
+}
+
+// switchProfile loads the next profile, makes it the default active profile and shows its rules
+func (m Model) switchProfile() Model {
+	if len(m.Profiles) < 2 {
+		m.Status = "No other profiles. Create one with 'vv :config profile create <name>'"
+		return m
+	}
+
+	next := m.Profiles[0]
+	for i, name := range m.Profiles {
+		if name == m.Profile {
+			next = m.Profiles[(i+1)%len(m.Profiles)]
+			break
+		}
+	}
+
+	path, err := config.ProfilePath(next)
+	if err != nil {
+		m.Status = err.Error()
+		return m
+	}
+	cfg, loaded, err := config.LoadConfigFingerprint(path)
+	if err != nil {
+		m.Status = fmt.Sprintf("Failed to load profile '%s': %v", next, err)
+		return m
+	}
+	if err := config.SaveActiveProfile(next); err != nil {
+		m.Status = err.Error()
+		return m
+	}
+
+	m.Cfg = cfg
+	m.ConfigPath = path
+	m.Loaded = loaded
+	m.Profile = next
+	m.Status = fmt.Sprintf("Switched to profile '%s'", next)
+	m.RulesList.ResetFilter()
+	m.RulesList.SetItems(lo.Map(cfg.Rules, func(r config.Rule, _ int) list.Item {
+		return RuleItem{Rule: r}
+	}))
+	m.RulesList.Select(0)
+	return m
+}
+
 func (m Model) Init() tea.Cmd {
 	return nil
 }
@@ -294,6 +360,8 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.Active--
 				}
 				return m, nil
+			case key.Matches(msg, keys.Profile):
+				return m.switchProfile(), nil
 			}
 		}
 
@@ -308,9 +376,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 						m.Cfg.Rules = append(m.Cfg.Rules[:index], m.Cfg.Rules[index+1:]...)
 						
 						// Save config
-						if err := config.SaveConfig(m.ConfigPath, m.Cfg); err != nil {
-							// Handle error
-						}
+						m.save()
 
 						// Remove from list
 						m.RulesList.RemoveItem(index)
@@ -323,7 +389,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.Cfg.Rules[index], m.Cfg.Rules[index-1] = m.Cfg.Rules[index-1], m.Cfg.Rules[index]
 					
 					// Save config
-					config.SaveConfig(m.ConfigPath, m.Cfg)
+					m.save()
 					
 					// Update list items
 					m.RulesList.SetItem(index, RuleItem{Rule: m.Cfg.Rules[index]})
@@ -339,7 +405,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.Cfg.Rules[index], m.Cfg.Rules[index+1] = m.Cfg.Rules[index+1], m.Cfg.Rules[index]
 					
 					// Save config
-					config.SaveConfig(m.ConfigPath, m.Cfg)
+					m.save()
 					
 					// Update list items
 					m.RulesList.SetItem(index, RuleItem{Rule: m.Cfg.Rules[index]})
@@ -461,7 +527,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.Cfg.Rules = append(m.Cfg.Rules, m.NewRule)
 					
 					// Save config
-					config.SaveConfig(m.ConfigPath, m.Cfg)
+					m.save()
 					
 					// Add to list
 					m.RulesList.InsertItem(len(m.Cfg.Rules)-1, RuleItem{Rule: m.NewRule})
@@ -478,9 +544,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					}
 
 					// Save config
-					if err := config.SaveConfig(m.ConfigPath, m.Cfg); err != nil {
-						// Handle error
-					}
+					m.save()
 					
 					// Update list item
 					m.RulesList.SetItem(m.SelectedRuleIndex, RuleItem{Rule: *rule})
@@ -520,7 +584,8 @@ func (m Model) View() string {
 			tabs = append(tabs, tabStyle.Render(t))
 		}
 	}
-	row := lipgloss.JoinHorizontal(lipgloss.Top, tabs...)
+	tabs = append(tabs, "  Profile: "+m.Profile)
+	row := lipgloss.JoinHorizontal(lipgloss.Bottom, tabs...)
 	doc.WriteString(row)
 	doc.WriteString("\n")
 
@@ -537,6 +602,9 @@ func (m Model) View() string {
 
 	doc.WriteString(windowStyle.Width(m.Width - windowStyle.GetHorizontalFrameSize()).Render(content))
 	doc.WriteString("\n")
+	if m.Status != "" {
+		doc.WriteString(statusMessageStyle(m.Status) + "\n")
+	}
 	
 	// Help
 	doc.WriteString(m.Help.View(keys))
@@ -572,9 +640,12 @@ func (m Model) renderSync() string {
 	s.WriteString(titleStyle.Render("Sync Status"))
 	s.WriteString("\n\n")
 	
-	if m.Cfg.Sync != nil && m.Cfg.Sync.GistID != "" {
-		s.WriteString(fmt.Sprintf("Gist ID: %s\n", m.Cfg.Sync.GistID))
-		if m.Cfg.Sync.Token != "" {
+	if location := syncLocation(m.Cfg.Sync); location != "" {
+		s.WriteString(fmt.Sprintf("Backend: %s\n", m.Cfg.Sync.BackendName()))
+		s.WriteString(location + "\n")
+		if m.Cfg.Sync.TokenCommand != "" {
+			s.WriteString("Token: (From command)\n")
+		} else if m.Cfg.Sync.Token != "" {
 			s.WriteString("Token: (Stored)\n")
 		} else {
 			s.WriteString("Token: (Not stored)\n")
@@ -585,3 +656,33 @@ func (m Model) renderSync() string {
 	
 	return s.String()
 }
+
+// syncLocation describes where the configured backend stores the config, or "" if sync is not set up
+func syncLocation(syncCfg *config.SyncConfig) string {
+	if syncCfg == nil {
+		return ""
+	}
+	switch syncCfg.BackendName() {
+	case "gist":
+		if syncCfg.GistID != "" {
+			return "Gist ID: " + syncCfg.GistID
+		}
+	case "git":
+		if syncCfg.Repo != "" {
+			branch := syncCfg.Branch
+			if branch == "" {
+				branch = "main"
+			}
+			return fmt.Sprintf("Repository: %s (%s)", syncCfg.Repo, branch)
+		}
+	case "dir":
+		if syncCfg.Path != "" {
+			return "Directory: " + syncCfg.Path
+		}
+	case "webdav":
+		if syncCfg.URL != "" {
+			return "URL: " + syncCfg.URL
+		}
+	}
+	return ""
+}
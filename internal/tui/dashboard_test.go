@@ -1,6 +1,7 @@
 package tui_test
 
 import (
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -19,9 +20,10 @@ func TestTui(t *testing.T) {
 
 var _ = Describe("Dashboard", func() {
 	var (
-		cfg *config.Config
-		m   tui.Model
-		err error
+		cfg        *config.Config
+		m          tui.Model
+		err        error
+		configPath string
 	)
 
 	BeforeEach(func() {
@@ -31,7 +33,8 @@ var _ = Describe("Dashboard", func() {
 				{Name: "Rule 2", Command: "cmd2"},
 			},
 		}
-		m, err = tui.NewModel(cfg, "config.yml")
+		configPath = filepath.Join(GinkgoT().TempDir(), "config.yml")
+		m, err = tui.NewModel(cfg, configPath)
 		Expect(err).NotTo(HaveOccurred())
 	})
 
@@ -218,6 +221,22 @@ var _ = Describe("Dashboard", func() {
 	})
 
 	Describe("Update interactions", func() {
+		It("should not overwrite changes made by another process", func() {
+			Expect(config.SaveConfig(configPath, cfg)).To(Succeed())
+			m.Width = 100
+			m.Height = 100
+			m.RulesList.SetSize(100, 100)
+
+			newM, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'d'}})
+			m = newM.(tui.Model)
+			Expect(m.Status).To(ContainSubstring("Failed to save"))
+			Expect(m.Status).To(ContainSubstring("changed by another process"))
+
+			saved, err := config.LoadConfig(configPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(saved.Rules).To(HaveLen(2))
+		})
+
 		It("should handle move up/down", func() {
 			cfg := &config.Config{
 				Rules: []config.Rule{
@@ -225,7 +244,7 @@ var _ = Describe("Dashboard", func() {
 					{Name: "Rule 2", Command: "cmd2"},
 				},
 			}
-			m, _ = tui.NewModel(cfg, "config.yml")
+			m, _ = tui.NewModel(cfg, configPath)
 			m.Width = 100
 			m.Height = 100
 			m.RulesList.SetSize(100, 100)
@@ -250,7 +269,7 @@ var _ = Describe("Dashboard", func() {
 
 		It("should handle edit form submission", func() {
 			cfg := &config.Config{Rules: []config.Rule{}}
-			m, _ = tui.NewModel(cfg, "config.yml")
+			m, _ = tui.NewModel(cfg, configPath)
 			
 			// Enter add mode
 			msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'a'}}
@@ -267,4 +286,55 @@ var _ = Describe("Dashboard", func() {
 			Expect(m.EditForm).To(BeNil())
 		})
 	})
+
+	Describe("Profiles", func() {
+		var home string
+
+		BeforeEach(func() {
+			home = GinkgoT().TempDir()
+			origHome := config.UserHomeDir
+			config.UserHomeDir = func() (string, error) { return home, nil }
+			DeferCleanup(func() { config.UserHomeDir = origHome })
+
+			work := &config.Config{Version: "1", Rules: []config.Rule{{Name: "Work rule", Command: "code {{.File}}"}}}
+			Expect(config.SaveConfig(filepath.Join(home, ".config", "via", "profiles", "work.yml"), work)).To(Succeed())
+			Expect(config.SaveConfig(filepath.Join(home, ".config", "via", "config.yml"), cfg)).To(Succeed())
+		})
+
+		press := func() {
+			newM, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("p")})
+			m = newM.(tui.Model)
+		}
+
+		It("should show the active profile", func() {
+			Expect(m.View()).To(ContainSubstring("Profile: default"))
+		})
+
+		It("should switch to the next profile and remember it", func() {
+			m.Profiles = []string{config.DefaultProfile, "work"}
+
+			press()
+			Expect(m.Profile).To(Equal("work"))
+			Expect(m.ConfigPath).To(HaveSuffix(filepath.Join("profiles", "work.yml")))
+			Expect(m.RulesList.Items()).To(HaveLen(1))
+			Expect(m.View()).To(ContainSubstring("Switched to profile 'work'"))
+
+			active, err := config.LoadActiveProfile()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(active).To(Equal("work"))
+
+			press()
+			Expect(m.Profile).To(Equal(config.DefaultProfile))
+			Expect(m.RulesList.Items()).To(HaveLen(2))
+			active, err = config.LoadActiveProfile()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(active).To(BeEmpty())
+		})
+
+		It("should explain when there is nothing to switch to", func() {
+			press()
+			Expect(m.Profile).To(Equal(config.DefaultProfile))
+			Expect(m.Status).To(ContainSubstring("No other profiles"))
+		})
+	})
 })